@@ -14,7 +14,6 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/app"
-	"github.com/vvjke314/itk-courses/loyalityhub/internal/client/accrual"
 	_ "github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/logx"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/metrics"
@@ -34,6 +33,9 @@ import (
 // @securityDefinitions.apikey BearerAuth
 // @in header
 // @name Authorization
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-Api-Key
 func main() {
 	// подгружаем переменные окружения
 	err := godotenv.Load(".env")
@@ -88,11 +90,15 @@ func main() {
 	appCtx, cancelAppCtx := context.WithCancel(ctx)
 	defer cancelAppCtx()
 
-	// инициализация клиента
-	accrualClient := accrual.NewAccrualClient(100, os.Getenv("ACCRUAL_SERVICE"))
+	// источник начислений: внешний сервис или встроенные правила
+	accrualProvider, err := services.NewAccrualProvider(services.NewAccrualConfig(), repos, logger)
+	if err != nil {
+		logger.Fatal("can't init accrual provider", zap.Error(err))
+		panic(err)
+	}
 
 	// инициализация сервиса worker'a
-	accrualWorkerService := services.NewAccrualWorkerService(repos, logger, accrualClient)
+	accrualWorkerService := services.NewAccrualWorkerService(repos, logger, accrualProvider)
 
 	// настройка фонового воркера
 	worker := accrualWorker.NewAccrualWorker(1*time.Second, accrualWorkerService)
@@ -100,7 +106,10 @@ func main() {
 
 	// инициализация приложения
 	app := app.NewApp(logger)
-	app.Init(appCtx, repos)
+	if err := app.Init(appCtx, repos); err != nil {
+		logger.Fatal("can't init app", zap.Error(err))
+		panic(err)
+	}
 
 	// запуск приложения
 	errGrp.Go(func() error {
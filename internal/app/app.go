@@ -2,12 +2,21 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"os"
 
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/handlers"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/notifier"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/router"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/services"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/denylist"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/identity"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/ordernumber"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passpolicy"
+	tokenmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/tokenManager"
 	"go.uber.org/zap"
 )
 
@@ -22,20 +31,83 @@ func NewApp(logger *zap.Logger) *App {
 	}
 }
 
-func (a *App) Init(ctx context.Context, repos *repository.Repositories) {
+func (a *App) Init(ctx context.Context, repos *repository.Repositories) error {
+	// инициализация token manager
+	tm, err := tokenmanager.NewTokenManager(tokenmanager.NewTokenManagerConfig())
+	if err != nil {
+		return fmt.Errorf("can't init token manager: %w", err)
+	}
+
+	// ротация ключей подписи без перезапуска
+	go tm.WatchKeys(ctx, func(err error) {
+		a.logger.Error("can't reload signing keys", zap.Error(err))
+	})
+
+	// отозванные access токены, общие для всех реплик через Postgres
+	revokedTokens := denylist.New(tm.GetAccessTokenTTL())
+	revocations := services.NewTokenRevocationService(repos, revokedTokens, a.logger)
+	if err := revocations.Start(ctx); err != nil {
+		return fmt.Errorf("can't load token denylist: %w", err)
+	}
+
+	// политика паролей
+	policy, err := passpolicy.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("can't init password policy: %w", err)
+	}
+
+	// доставка сообщений пользователям
+	n, err := notifier.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("can't init notifier: %w", err)
+	}
+
+	// внешние провайдеры входа (OIDC)
+	providers, err := identity.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("can't init identity providers: %w", err)
+	}
+
+	// правила проверки номеров заказов, общие и по мерчантам
+	validators, err := ordernumber.NewFromEnv()
+	if err != nil {
+		return fmt.Errorf("can't init order number validators: %w", err)
+	}
+
 	// инициализация сервисов
-	userService := services.NewUserService(a.logger, repos)
-	orderService := services.NewOrderService(repos, a.logger)
+	userService := services.NewUserService(a.logger, repos, tm, loginguard.NewConfig(), policy,
+		services.NewPasswordResetConfig(), services.NewEmailVerificationConfig(), n, providers)
+	orderService := services.NewOrderService(repos, validators, a.logger)
 	balanceService := services.NewBalanceService(repos, a.logger)
+	apiKeyService := services.NewAPIKeyService(repos, a.logger)
+	merchantService := services.NewMerchantService(repos, a.logger)
+	partnerService := services.NewPartnerService(repos, orderService, balanceService, a.logger)
+	accountService := services.NewAccountService(repos, services.NewAccountDeletionConfig(), a.logger)
+	idempotencyService := services.NewIdempotencyService(repos, services.NewIdempotencyConfig(), a.logger)
+
+	// обезличивание аккаунтов, у которых истек срок на отмену удаления
+	go accountService.RunDeletionWorker(ctx)
+	// удаление истекших ключей идемпотентности
+	go idempotencyService.RunCleanupWorker(ctx)
 
 	// инициализация хендлеров
-	userHandler := handlers.NewUserHandler(os.Getenv("APP_HOST"), userService)
+	userHandler := handlers.NewUserHandler(os.Getenv("APP_HOST"),
+		authcookie.NewConfigFromEnv(tm.GetRefreshTokenTTL()), userService)
 	orderHandler := handlers.NewOrderHandler(os.Getenv("APP_HOST"), orderService)
 	balanceHandler := handlers.NewBalanceHandler(os.Getenv("APP_HOST"), balanceService)
+	jwksHandler := handlers.NewJWKSHandler(tm)
+	adminHandler := handlers.NewAdminHandler(userService)
+	merchantAdminHandler := handlers.NewMerchantAdminHandler(merchantService)
+	merchantHandler := handlers.NewMerchantHandler(apiKeyService)
+	partnerHandler := handlers.NewPartnerHandler(partnerService)
+	accountHandler := handlers.NewAccountHandler(accountService)
 
 	// настройка роутера
-	router := router.NewRouter(ctx, a.logger, userHandler, orderHandler, balanceHandler)
+	router := router.NewRouter(ctx, a.logger, tm, revokedTokens, userHandler, orderHandler, balanceHandler,
+		jwksHandler, adminHandler, merchantAdminHandler, merchantHandler, partnerHandler, accountHandler,
+		apiKeyService, idempotencyService)
 	a.router = router
+	return nil
 }
 
 func (a *App) Run() error {
@@ -3,3 +3,11 @@ package contextkeys
 type contextKey string
 
 const UserKeyID contextKey = "userID"
+const UserRoleKey contextKey = "userRole"
+
+// jti и sid access токена запроса
+const TokenIDKey contextKey = "tokenID"
+const SessionIDKey contextKey = "sessionID"
+
+// APIKey *model.APIKey, которым аутентифицирован запрос партнера
+const APIKey contextKey = "apiKey"
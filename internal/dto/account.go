@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DeleteAccountRequest пароль подтверждает, что удаление запрашивает владелец.
+// Аккаунт без пароля подтверждает удаление недавним входом через провайдер
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type DeleteAccountResponse struct {
+	// после этого момента аккаунт будет обезличен. До него удаление
+	// отменяется обычным входом
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// ExportManifest описание архива выгрузки данных пользователя
+type ExportManifest struct {
+	Format      string    `json:"format"`
+	UserID      string    `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+type ExportOrder struct {
+	Number     string          `json:"number"`
+	Status     string          `json:"status"`
+	Accrual    decimal.Decimal `json:"accrual"`
+	UploadedAt time.Time       `json:"uploaded_at"`
+}
@@ -1,7 +1,11 @@
 package dto
 
+import "github.com/shopspring/decimal"
+
+// AccrualServiceResponse расчет начисления по заказу. Сумма - decimal, чтобы
+// не терять копейки на пути от расчетной системы до баланса
 type AccrualServiceResponse struct {
-	OrderNumber string  `json:"order"`
-	Status      string  `json:"status"`
-	Accrual     float64 `json:"accrual"`
+	OrderNumber string          `json:"order"`
+	Status      string          `json:"status"`
+	Accrual     decimal.Decimal `json:"accrual"`
 }
@@ -0,0 +1,32 @@
+package dto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAccrualServiceResponseDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		accrual string
+	}{
+		{name: "processed", body: `{"order":"2377225624","status":"PROCESSED","accrual":729.98}`, accrual: "729.98"},
+		// сумма, которую float64 хранит неточно
+		{name: "inexact in float", body: `{"order":"2377225624","status":"PROCESSED","accrual":0.30000000000000001}`,
+			accrual: "0.30000000000000001"},
+		{name: "without accrual", body: `{"order":"2377225624","status":"REGISTERED"}`, accrual: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp AccrualServiceResponse
+			if err := json.Unmarshal([]byte(tt.body), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Accrual.String() != tt.accrual {
+				t.Errorf("expected accrual %s, got %s", tt.accrual, resp.Accrual)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+package dto
+
+type SetRoleRequest struct {
+	Role string `json:"role" example:"support"`
+}
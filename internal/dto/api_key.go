@@ -0,0 +1,43 @@
+package dto
+
+import "time"
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" example:"pos-terminal-1"`
+	Scopes []string `json:"scopes" example:"orders:write,redemptions:write"`
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse содержит сам ключ - он показывается только один раз
+type CreateAPIKeyResponse struct {
+	APIKeyResponse
+	Key string `json:"key"`
+}
+
+type GetAPIKeysResponse struct {
+	Keys []APIKeyResponse `json:"keys"`
+}
+
+// PartnerOrderRequest заказ покупателя, загруженный кассой партнера
+type PartnerOrderRequest struct {
+	Login string `json:"login"`
+	Order string `json:"order"`
+}
+
+// PartnerRedemptionRequest списание баллов покупателя на кассе партнера
+type PartnerRedemptionRequest struct {
+	Login string  `json:"login"`
+	Order string  `json:"order"`
+	Sum   float64 `json:"sum"`
+	// одноразовый код из приложения покупателя
+	Code string `json:"code" example:"40817263"`
+}
@@ -1,19 +1,39 @@
 package dto
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	passmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passpolicy"
 )
 
+var ErrEmptyLogin = errors.New("login is required")
+var ErrEmptyPassword = errors.New("password is required")
+
 type RegisterRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 }
 
+// Validate проверяет логин и пароль по политике паролей
+func (r *RegisterRequest) Validate(policy *passpolicy.Policy) error {
+	if strings.TrimSpace(r.Login) == "" {
+		return ErrEmptyLogin
+	}
+	// логин с @ считается email и должен быть корректным адресом
+	if strings.Contains(r.Login, "@") {
+		if _, err := ParseEmail(r.Login); err != nil {
+			return err
+		}
+	}
+	return policy.Validate(r.Password)
+}
+
 func (r *RegisterRequest) ToModel() (*model.User, error) {
 	hashedPass, err := passmanager.HashPassword(r.Password)
 	if err != nil {
@@ -34,11 +54,64 @@ type AuthRequest struct {
 	Password string `json:"password"`
 }
 
+// AuthResponse при включенной 2FA вместо токенов содержит mfa_required и
+// mfa_token, который обменивается на токены в /api/v1/auth/2fa
 type AuthResponse struct {
-	AccessToken  string `json:"access_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+	// refresh токен текущей сессии - она останется активной
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func (r *ChangePasswordRequest) Validate(policy *passpolicy.Policy) error {
+	if r.OldPassword == "" {
+		return ErrEmptyPassword
+	}
+	return policy.Validate(r.NewPassword)
+}
+
+// VerifyEmailRequest token - одноразовый токен из письма подтверждения email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Login string `json:"login"`
+}
+
+func (r *ForgotPasswordRequest) Validate() error {
+	if strings.TrimSpace(r.Login) == "" {
+		return ErrEmptyLogin
+	}
+	return nil
+}
+
+// ResetPasswordRequest token - одноразовый токен из письма о сбросе пароля
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (r *ResetPasswordRequest) Validate(policy *passpolicy.Policy) error {
+	if r.Token == "" {
+		return model.ErrInvalidResetToken
+	}
+	return policy.Validate(r.NewPassword)
+}
+
+type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// RefreshResponse в режиме cookie refresh_token не возвращается
 type RefreshResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
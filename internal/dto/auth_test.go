@@ -4,7 +4,9 @@ import (
 	"errors"
 	"testing"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passpolicy"
 )
 
 func TestRegisterRequestModel(t *testing.T) {
@@ -31,10 +33,57 @@ func TestRegisterRequestModel(t *testing.T) {
 				t.Errorf("expected no error")
 			}
 			if result.Login != tt.req.Login ||
-				!errors.Is(bcrypt.CompareHashAndPassword([]byte(result.Password),
-					[]byte(tt.req.Password)), nil) {
+				!passmanager.CheckPass(tt.req.Password, result.Password) {
 				t.Errorf("bad result")
 			}
 		})
 	}
 }
+
+func TestRegisterRequestValidate(t *testing.T) {
+	policy, err := passpolicy.New(8, 64, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  RegisterRequest
+		err  error
+	}{
+		{
+			name: "successfully case",
+			req:  RegisterRequest{Login: "hello", Password: "superpass"},
+			err:  nil,
+		},
+		{
+			name: "empty login",
+			req:  RegisterRequest{Login: "  ", Password: "superpass"},
+			err:  ErrEmptyLogin,
+		},
+		{
+			name: "short password",
+			req:  RegisterRequest{Login: "hello", Password: "pass"},
+			err:  model.ErrWeakPassword,
+		},
+		{
+			name: "email login",
+			req:  RegisterRequest{Login: "Ivan@Example.com", Password: "superpass"},
+			err:  nil,
+		},
+		{
+			name: "malformed email login",
+			req:  RegisterRequest{Login: "ivan@", Password: "superpass"},
+			err:  ErrInvalidEmail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate(policy)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
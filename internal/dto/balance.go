@@ -9,17 +9,30 @@ import (
 type GetBalanceResponse struct {
 	Current   float64 `json:"current"`
 	Withdrawn float64 `json:"withdrawn"`
+	// долг после возврата заказа, баллы за который уже потрачены
+	Debt float64 `json:"debt"`
 }
 
 type NewWithdrawnRequest struct {
 	Order string  `json:"order"`
 	Sum   float64 `json:"sum"`
+	// магазин, в котором списаны баллы; для кассы по API ключу берется из ключа
+	MerchantID string `json:"merchant_id,omitempty"`
+	// код покупателя, без него касса партнера не может списать баллы
+	RedemptionCode string `json:"-"`
+}
+
+// RedemptionCodeResponse одноразовый код для списания баллов на кассе партнера
+type RedemptionCodeResponse struct {
+	Code      string    `json:"code" example:"40817263"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 type Withdrawn struct {
 	Order       string          `json:"order"`
 	Sum         decimal.Decimal `json:"sum"`
 	ProcessedAt time.Time       `json:"processed_at"`
+	MerchantID  *string         `json:"merchant_id,omitempty"`
 }
 
 type GetAllWithdrawalsResponse struct {
@@ -0,0 +1,15 @@
+package dto
+
+// ExternalLoginResponse адрес страницы входа внешнего провайдера
+type ExternalLoginResponse struct {
+	AuthURL string `json:"auth_url"`
+	// state входа, обработчик привязывает его к браузеру через cookie
+	State string `json:"-"`
+}
+
+// ExternalCallbackRequest параметры, с которыми провайдер вернул браузер
+// на redirect url. Принимаются и в query, и в теле запроса
+type ExternalCallbackRequest struct {
+	Code  string `json:"code" form:"code"`
+	State string `json:"state" form:"state"`
+}
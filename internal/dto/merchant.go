@@ -0,0 +1,181 @@
+package dto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/ordernumber"
+)
+
+var ErrInvalidMerchantName = errors.New("merchant name must be 1 to 128 characters")
+var ErrInvalidMerchantOwnerID = errors.New("owner_id must be a UUID")
+var ErrInvalidMerchantValidator = errors.New("invalid order number validator")
+
+const merchantNameMaxLen = 128
+
+type CreateMerchantRequest struct {
+	Name string `json:"name" example:"Coffee Point"`
+	// пользователь с ролью merchant, чьи API ключи работают от имени магазина
+	OwnerID string `json:"owner_id,omitempty"`
+	// правила проверки номеров, пусто - из настроек сервиса
+	OrderNumberValidator string `json:"order_number_validator,omitempty" example:"prefix:77+length:12+luhn"`
+}
+
+// Validate проверяет поля и собирает мерчанта
+func (r *CreateMerchantRequest) Validate(now time.Time) (*model.Merchant, error) {
+	m := &model.Merchant{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	update := UpdateMerchantRequest{
+		Name:                 &r.Name,
+		OwnerID:              &r.OwnerID,
+		OrderNumberValidator: &r.OrderNumberValidator,
+	}
+	if err := update.Validate(); err != nil {
+		return nil, err
+	}
+	update.Apply(m, now)
+	return m, nil
+}
+
+// UpdateMerchantRequest частичное обновление мерчанта: меняются только
+// переданные поля, пустые owner_id и order_number_validator очищают поле
+type UpdateMerchantRequest struct {
+	Name                 *string `json:"name,omitempty"`
+	OwnerID              *string `json:"owner_id,omitempty"`
+	OrderNumberValidator *string `json:"order_number_validator,omitempty"`
+
+	ownerID *uuid.UUID
+}
+
+// Validate проверяет и нормализует переданные поля
+func (r *UpdateMerchantRequest) Validate() error {
+	if r.Name != nil {
+		name := strings.TrimSpace(*r.Name)
+		if name == "" || utf8.RuneCountInString(name) > merchantNameMaxLen {
+			return ErrInvalidMerchantName
+		}
+		r.Name = &name
+	}
+
+	if r.OwnerID != nil && strings.TrimSpace(*r.OwnerID) != "" {
+		id, err := uuid.Parse(strings.TrimSpace(*r.OwnerID))
+		if err != nil {
+			return ErrInvalidMerchantOwnerID
+		}
+		r.ownerID = &id
+	}
+
+	if r.OrderNumberValidator != nil {
+		spec := strings.TrimSpace(*r.OrderNumberValidator)
+		if spec != "" {
+			if _, err := ordernumber.Parse(spec); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidMerchantValidator, err)
+			}
+		}
+		r.OrderNumberValidator = &spec
+	}
+	return nil
+}
+
+// Apply переносит переданные поля в мерчанта. Вызывать после Validate
+func (r *UpdateMerchantRequest) Apply(m *model.Merchant, now time.Time) {
+	if r.Name != nil {
+		m.Name = *r.Name
+	}
+	if r.OwnerID != nil {
+		m.OwnerID = r.ownerID
+	}
+	if r.OrderNumberValidator != nil {
+		m.OrderNumberValidator = *r.OrderNumberValidator
+	}
+	m.UpdatedAt = now
+}
+
+type MerchantResponse struct {
+	ID                   string    `json:"id"`
+	Name                 string    `json:"name"`
+	OwnerID              *string   `json:"owner_id"`
+	OrderNumberValidator string    `json:"order_number_validator,omitempty"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+func NewMerchantResponse(m *model.Merchant) MerchantResponse {
+	resp := MerchantResponse{
+		ID:                   m.ID.String(),
+		Name:                 m.Name,
+		OrderNumberValidator: m.OrderNumberValidator,
+		CreatedAt:            m.CreatedAt,
+		UpdatedAt:            m.UpdatedAt,
+	}
+	if m.OwnerID != nil {
+		owner := m.OwnerID.String()
+		resp.OwnerID = &owner
+	}
+	return resp
+}
+
+type GetMerchantsResponse struct {
+	Merchants []MerchantResponse `json:"merchants"`
+}
+
+// MerchantReportRequest период отчета из query, границы необязательны
+type MerchantReportRequest struct {
+	From string `form:"from" example:"2025-08-01T00:00:00Z"`
+	To   string `form:"to" example:"2025-09-01T00:00:00Z"`
+}
+
+// Range проверяет границы периода
+func (r *MerchantReportRequest) Range() (from, to *time.Time, err error) {
+	if from, err = parseTime(r.From); err != nil {
+		return nil, nil, ErrInvalidTimeRange
+	}
+	if to, err = parseTime(r.To); err != nil {
+		return nil, nil, ErrInvalidTimeRange
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, ErrInvalidTimeRange
+	}
+	return from, to, nil
+}
+
+// MerchantReportResponse сводка по мерчанту: заказы за период по времени
+// загрузки, списания - по времени списания
+type MerchantReportResponse struct {
+	MerchantID      string          `json:"merchant_id"`
+	From            *time.Time      `json:"from,omitempty"`
+	To              *time.Time      `json:"to,omitempty"`
+	Orders          int             `json:"orders"`
+	ProcessedOrders int             `json:"processed_orders"`
+	ReturnedOrders  int             `json:"returned_orders"`
+	Accrued         decimal.Decimal `json:"accrued"`
+	// баллы, списанные обратно за возвраты
+	ClawedBack  decimal.Decimal `json:"clawed_back"`
+	Withdrawals int             `json:"withdrawals"`
+	Withdrawn   decimal.Decimal `json:"withdrawn"`
+}
+
+func NewMerchantReportResponse(merchantID string, from, to *time.Time,
+	r *model.MerchantReport) MerchantReportResponse {
+	return MerchantReportResponse{
+		MerchantID:      merchantID,
+		From:            from,
+		To:              to,
+		Orders:          r.Orders,
+		ProcessedOrders: r.ProcessedOrders,
+		ReturnedOrders:  r.ReturnedOrders,
+		Accrued:         r.Accrued,
+		ClawedBack:      r.ClawedBack,
+		Withdrawals:     r.Withdrawals,
+		Withdrawn:       r.Withdrawn,
+	}
+}
@@ -0,0 +1,131 @@
+package dto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestUpdateMerchantRequestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  UpdateMerchantRequest
+		err  error
+	}{
+		{name: "empty update", req: UpdateMerchantRequest{}},
+		{
+			name: "all fields",
+			req: UpdateMerchantRequest{Name: strPtr(" Coffee Point "),
+				OwnerID:              strPtr("5f0c8e7a-3b7d-4c52-9a51-2f4f0f6b9d10"),
+				OrderNumberValidator: strPtr("prefix:77+luhn")},
+		},
+		{name: "clear owner and validator", req: UpdateMerchantRequest{OwnerID: strPtr(""), OrderNumberValidator: strPtr(" ")}},
+		{name: "blank name", req: UpdateMerchantRequest{Name: strPtr("  ")}, err: ErrInvalidMerchantName},
+		{name: "long name", req: UpdateMerchantRequest{Name: strPtr(strings.Repeat("я", merchantNameMaxLen+1))}, err: ErrInvalidMerchantName},
+		{name: "bad owner", req: UpdateMerchantRequest{OwnerID: strPtr("shop-1")}, err: ErrInvalidMerchantOwnerID},
+		{name: "bad validator", req: UpdateMerchantRequest{OrderNumberValidator: strPtr("crc32")}, err: ErrInvalidMerchantValidator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestUpdateMerchantRequestApply(t *testing.T) {
+	owner := uuid.New()
+	created := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	now := created.Add(time.Hour)
+	merchant := func() *model.Merchant {
+		return &model.Merchant{ID: uuid.New(), Name: "Old", OwnerID: &owner,
+			OrderNumberValidator: "luhn", CreatedAt: created, UpdatedAt: created}
+	}
+
+	// непереданные поля не меняются
+	m := merchant()
+	req := UpdateMerchantRequest{Name: strPtr(" New ")}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	req.Apply(m, now)
+	if m.Name != "New" || m.OwnerID == nil || *m.OwnerID != owner || m.OrderNumberValidator != "luhn" {
+		t.Errorf("unexpected partial update: %+v", m)
+	}
+	if !m.UpdatedAt.Equal(now) || !m.CreatedAt.Equal(created) {
+		t.Errorf("expected only updated_at to move, got %v %v", m.CreatedAt, m.UpdatedAt)
+	}
+
+	// пустые строки очищают владельца и правила
+	m = merchant()
+	req = UpdateMerchantRequest{OwnerID: strPtr(""), OrderNumberValidator: strPtr("")}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	req.Apply(m, now)
+	if m.OwnerID != nil || m.OrderNumberValidator != "" || m.Name != "Old" {
+		t.Errorf("expected owner and validator to be cleared: %+v", m)
+	}
+
+	// новый владелец
+	other := uuid.New()
+	m = merchant()
+	req = UpdateMerchantRequest{OwnerID: strPtr(other.String())}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	req.Apply(m, now)
+	if m.OwnerID == nil || *m.OwnerID != other {
+		t.Errorf("expected owner %s, got %v", other, m.OwnerID)
+	}
+}
+
+func TestMerchantReportRequestRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		req      MerchantReportRequest
+		from, to bool
+		err      error
+	}{
+		{name: "open range", req: MerchantReportRequest{}},
+		{name: "from only", req: MerchantReportRequest{From: "2025-08-01T00:00:00Z"}, from: true},
+		{name: "to only", req: MerchantReportRequest{To: "2025-09-01T00:00:00Z"}, to: true},
+		{
+			name: "both",
+			req:  MerchantReportRequest{From: "2025-08-01T00:00:00Z", To: "2025-09-01T00:00:00Z"},
+			from: true, to: true,
+		},
+		{name: "bad time", req: MerchantReportRequest{From: "2025-08-01"}, err: ErrInvalidTimeRange},
+		{
+			name: "empty range",
+			req:  MerchantReportRequest{From: "2025-09-01T00:00:00Z", To: "2025-09-01T00:00:00Z"},
+			err:  ErrInvalidTimeRange,
+		},
+		{
+			name: "reversed range",
+			req:  MerchantReportRequest{From: "2025-09-01T00:00:00Z", To: "2025-08-01T00:00:00Z"},
+			err:  ErrInvalidTimeRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := tt.req.Range()
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if (from != nil) != tt.from || (to != nil) != tt.to {
+				t.Errorf("unexpected range %v - %v", from, to)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package dto
+
+type MFAEnrollResponse struct {
+	Secret string `json:"secret"`
+	// otpauth:// ссылка для QR кода
+	URI string `json:"uri"`
+	// одноразовые коды восстановления, показываются только один раз
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type MFAConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password"`
+	// код из приложения или код восстановления
+	Code string `json:"code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token"`
+	// код из приложения или код восстановления
+	Code string `json:"code"`
+}
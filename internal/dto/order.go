@@ -1,6 +1,28 @@
 package dto
 
-import "github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+var ErrInvalidOrderStatus = errors.New("status must be one of NEW, REGISTERED, PROCESSING, INVALID, PROCESSED, CANCELLED, RETURNED")
+var ErrInvalidTimeRange = errors.New("from and to must be RFC 3339 timestamps, from before to")
+var ErrInvalidSort = errors.New("sort must be one of uploaded_at, -uploaded_at, accrual, -accrual")
+var ErrInvalidLimit = errors.New("limit must be between 1 and 100")
+var ErrInvalidCursor = errors.New("invalid cursor")
+var ErrInvalidMerchantID = errors.New("merchant_id must be a UUID")
+
+const (
+	defaultOrdersLimit = 50
+	maxOrdersLimit     = 100
+)
 
 type AddOrderResponse struct {
 	OrderNumber string `json:"orders"`
@@ -8,4 +30,180 @@ type AddOrderResponse struct {
 
 type GetAllOrdersResponse struct {
 	Orders []model.Order `json:"orders"`
+	// курсор следующей страницы, пустой на последней
+	NextCursor string `json:"next_cursor,omitempty"`
+	// число заказов по фильтрам без учета страницы
+	TotalCount int `json:"total_count"`
+}
+
+// OrderStatusChange шаг истории заказа
+type OrderStatusChange struct {
+	Status  model.OrderStatus `json:"status"`
+	Accrual decimal.Decimal   `json:"accrual"`
+	// изменение начисления относительно предыдущего шага
+	AccrualChange decimal.Decimal `json:"accrual_change"`
+	ChangedAt     time.Time       `json:"changed_at"`
+}
+
+// OrderDetailResponse заказ вместе с историей от загрузки к текущему состоянию
+type OrderDetailResponse struct {
+	Order   model.Order         `json:"order"`
+	History []OrderStatusChange `json:"history"`
+	// чек покупки, если его передал магазин
+	Receipt *ReceiptResponse `json:"receipt,omitempty"`
+}
+
+// NewOrderDetailResponse собирает ответ и считает изменения начисления по шагам,
+// receipt может быть nil
+func NewOrderDetailResponse(order model.Order, history []model.OrderStatusChange,
+	receipt *model.Receipt) OrderDetailResponse {
+	resp := OrderDetailResponse{
+		Order:   order,
+		History: make([]OrderStatusChange, 0, len(history)),
+	}
+	if receipt != nil {
+		r := NewReceiptResponse(receipt)
+		resp.Receipt = &r
+	}
+	prev := decimal.Zero
+	for _, h := range history {
+		resp.History = append(resp.History, OrderStatusChange{
+			Status:        h.Status,
+			Accrual:       h.Accrual,
+			AccrualChange: h.Accrual.Sub(prev),
+			ChangedAt:     h.ChangedAt,
+		})
+		prev = h.Accrual
+	}
+	return resp
+}
+
+// CancelOrderResponse итог отмены или возврата заказа
+type CancelOrderResponse struct {
+	Number string            `json:"number"`
+	Status model.OrderStatus `json:"status"`
+	// баллы, списанные обратно за возвращенный заказ
+	Clawback decimal.Decimal `json:"clawback"`
+	// долг покупателя, если списанные баллы уже были потрачены
+	Debt float64 `json:"debt"`
+}
+
+// ListOrdersRequest параметры списка заказов из query. Статусы можно
+// повторять или перечислять через запятую
+type ListOrdersRequest struct {
+	Status []string `form:"status"`
+	From   string   `form:"from" example:"2025-08-01T00:00:00Z"`
+	To     string   `form:"to" example:"2025-09-01T00:00:00Z"`
+	Sort   string   `form:"sort" example:"-uploaded_at"`
+	Limit  int      `form:"limit"`
+	Cursor string   `form:"cursor"`
+	// только заказы этого мерчанта
+	MerchantID string `form:"merchant_id"`
+}
+
+// ToQuery проверяет параметры и собирает запрос к репозиторию
+func (r *ListOrdersRequest) ToQuery() (model.OrderListQuery, error) {
+	q := model.OrderListQuery{
+		Sort:  model.OrderSortUploadedDesc,
+		Limit: defaultOrdersLimit,
+	}
+
+	for _, raw := range r.Status {
+		for _, s := range strings.Split(raw, ",") {
+			status := model.OrderStatus(strings.ToUpper(strings.TrimSpace(s)))
+			if !status.Valid() {
+				return q, ErrInvalidOrderStatus
+			}
+			q.Statuses = append(q.Statuses, status)
+		}
+	}
+
+	var err error
+	if q.UploadedFrom, err = parseTime(r.From); err != nil {
+		return q, ErrInvalidTimeRange
+	}
+	if q.UploadedTo, err = parseTime(r.To); err != nil {
+		return q, ErrInvalidTimeRange
+	}
+	if q.UploadedFrom != nil && q.UploadedTo != nil && !q.UploadedFrom.Before(*q.UploadedTo) {
+		return q, ErrInvalidTimeRange
+	}
+
+	switch sort := model.OrderSort(r.Sort); sort {
+	case "":
+	case model.OrderSortUploadedDesc, model.OrderSortUploadedAsc,
+		model.OrderSortAccrualDesc, model.OrderSortAccrualAsc:
+		q.Sort = sort
+	default:
+		return q, ErrInvalidSort
+	}
+
+	if r.Limit != 0 {
+		if r.Limit < 0 || r.Limit > maxOrdersLimit {
+			return q, ErrInvalidLimit
+		}
+		q.Limit = r.Limit
+	}
+
+	if r.MerchantID != "" {
+		id, err := uuid.Parse(r.MerchantID)
+		if err != nil {
+			return q, ErrInvalidMerchantID
+		}
+		q.MerchantID = &id
+	}
+
+	if r.Cursor != "" {
+		if q.After, err = decodeOrderCursor(r.Cursor, q.Sort); err != nil {
+			return q, err
+		}
+	}
+	return q, nil
+}
+
+func parseTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// orderCursor содержимое курсора. Сортировка сохраняется, чтобы курсор
+// нельзя было применить к списку в другом порядке
+type orderCursor struct {
+	Sort       model.OrderSort `json:"s"`
+	UploadedAt time.Time       `json:"t"`
+	Accrual    decimal.Decimal `json:"a"`
+	Number     string          `json:"n"`
+}
+
+// EncodeOrderCursor курсор страницы, которая начинается после order
+func EncodeOrderCursor(sort model.OrderSort, order model.Order) string {
+	raw, _ := json.Marshal(orderCursor{
+		Sort:       sort,
+		UploadedAt: order.UploadedAt,
+		Accrual:    order.Accrual,
+		Number:     order.Number,
+	})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeOrderCursor(s string, sort model.OrderSort) (*model.OrderCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c orderCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort || c.Number == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &model.OrderCursor{
+		UploadedAt: c.UploadedAt,
+		Accrual:    c.Accrual,
+		Number:     c.Number,
+	}, nil
 }
@@ -0,0 +1,133 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+var ErrEmptyBatch = errors.New("batch contains no order numbers")
+var ErrBatchTooLarge = errors.New("batch contains more than 1000 order numbers")
+var ErrInvalidBatch = errors.New("batch must be a JSON array of strings or CSV with one order number per row")
+
+const MaxBatchOrders = 1000
+
+// MaxBatchBodySize предел тела пакетной загрузки: с запасом на 1000 номеров
+const MaxBatchBodySize = 256 << 10
+
+// BatchOrderStatus результат загрузки одного заказа из пакета
+type BatchOrderStatus string
+
+const (
+	BatchOrderAccepted BatchOrderStatus = "accepted"
+	// заказ уже загружен этим пользователем или повторяется в пакете
+	BatchOrderDuplicate BatchOrderStatus = "duplicate"
+	// заказ загружен другим пользователем
+	BatchOrderConflict BatchOrderStatus = "conflict"
+	// номер не прошел проверку
+	BatchOrderInvalid BatchOrderStatus = "invalid"
+)
+
+type BatchOrderResult struct {
+	Number string           `json:"number" example:"2377225624"`
+	Status BatchOrderStatus `json:"status" example:"accepted"`
+	// какое правило не прошел номер со статусом invalid
+	Reason string `json:"reason,omitempty" example:"bad order number: luhn: invalid check digit"`
+}
+
+// BatchOrdersResponse результаты в порядке номеров запроса и их сводка
+type BatchOrdersResponse struct {
+	Results   []BatchOrderResult `json:"results"`
+	Accepted  int                `json:"accepted"`
+	Duplicate int                `json:"duplicate"`
+	Conflict  int                `json:"conflict"`
+	Invalid   int                `json:"invalid"`
+}
+
+// Add добавляет результат заказа и учитывает его в сводке
+func (r *BatchOrdersResponse) Add(number string, status BatchOrderStatus, reason string) {
+	r.Results = append(r.Results, BatchOrderResult{Number: number, Status: status, Reason: reason})
+	switch status {
+	case BatchOrderAccepted:
+		r.Accepted++
+	case BatchOrderDuplicate:
+		r.Duplicate++
+	case BatchOrderConflict:
+		r.Conflict++
+	case BatchOrderInvalid:
+		r.Invalid++
+	}
+}
+
+// ParseBatchOrders разбирает номера заказов из JSON массива строк или CSV,
+// где номер - первая колонка строки. Строка заголовка CSV пропускается
+func ParseBatchOrders(contentType string, body []byte) ([]string, error) {
+	var numbers []string
+	var err error
+	if contentType == "application/json" {
+		numbers, err = parseBatchJSON(body)
+	} else {
+		numbers, err = parseBatchCSV(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(numbers) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(numbers) > MaxBatchOrders {
+		return nil, ErrBatchTooLarge
+	}
+	return numbers, nil
+}
+
+func parseBatchJSON(body []byte) ([]string, error) {
+	var raw []string
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, ErrInvalidBatch
+	}
+	numbers := make([]string, 0, len(raw))
+	for _, n := range raw {
+		numbers = append(numbers, strings.TrimSpace(n))
+	}
+	return numbers, nil
+}
+
+func parseBatchCSV(body []byte) ([]string, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	numbers := make([]string, 0)
+	for first := true; ; first = false {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalidBatch
+		}
+
+		number := strings.TrimSpace(record[0])
+		if first && isBatchHeader(number) {
+			continue
+		}
+		if number == "" && len(record) == 1 {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+func isBatchHeader(s string) bool {
+	switch strings.ToLower(s) {
+	case "number", "order", "order_number":
+		return true
+	}
+	return false
+}
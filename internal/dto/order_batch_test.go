@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseBatchOrders(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+		wantErr     error
+	}{
+		{"json", "application/json", `["12345678903", " 79927398713 "]`, []string{"12345678903", "79927398713"}, nil},
+		{"json not array", "application/json", `{"orders": []}`, nil, ErrInvalidBatch},
+		{"json numbers", "application/json", `[12345678903]`, nil, ErrInvalidBatch},
+		{"json empty", "application/json", `[]`, nil, ErrEmptyBatch},
+		{"csv", "text/csv", "12345678903\n79927398713\n", []string{"12345678903", "79927398713"}, nil},
+		{"csv header and columns", "text/csv", "number,comment\n12345678903,first\n\n79927398713,second\n",
+			[]string{"12345678903", "79927398713"}, nil},
+		{"csv broken quote", "text/csv", "\"12345678903\n", nil, ErrInvalidBatch},
+		{"csv empty", "text/csv", "number\n", nil, ErrEmptyBatch},
+		{"too large", "text/csv", strings.Repeat("12345678903\n", MaxBatchOrders+1), nil, ErrBatchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBatchOrders(tt.contentType, []byte(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestListOrdersRequestToQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		req  ListOrdersRequest
+		err  error
+	}{
+		{name: "defaults", req: ListOrdersRequest{}},
+		{
+			name: "filters",
+			req: ListOrdersRequest{Status: []string{"new,processing", "PROCESSED"},
+				From: "2025-08-01T00:00:00Z", To: "2025-09-01T00:00:00Z", Sort: "accrual", Limit: 10},
+		},
+		{name: "unknown status", req: ListOrdersRequest{Status: []string{"DONE"}}, err: ErrInvalidOrderStatus},
+		{name: "bad time", req: ListOrdersRequest{From: "2025-08-01"}, err: ErrInvalidTimeRange},
+		{
+			name: "reversed range",
+			req:  ListOrdersRequest{From: "2025-09-01T00:00:00Z", To: "2025-08-01T00:00:00Z"},
+			err:  ErrInvalidTimeRange,
+		},
+		{name: "unknown sort", req: ListOrdersRequest{Sort: "number"}, err: ErrInvalidSort},
+		{name: "limit too big", req: ListOrdersRequest{Limit: 1000}, err: ErrInvalidLimit},
+		{name: "garbage cursor", req: ListOrdersRequest{Cursor: "%%%"}, err: ErrInvalidCursor},
+		{name: "merchant", req: ListOrdersRequest{MerchantID: "5f0c8e7a-3b7d-4c52-9a51-2f4f0f6b9d10"}},
+		{name: "bad merchant", req: ListOrdersRequest{MerchantID: "shop-1"}, err: ErrInvalidMerchantID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.req.ToQuery()
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestOrderCursor(t *testing.T) {
+	order := model.Order{
+		Number:     "12345678903",
+		Accrual:    decimal.RequireFromString("729.98"),
+		UploadedAt: time.Date(2025, 8, 13, 12, 0, 0, 0, time.UTC),
+	}
+	cursor := EncodeOrderCursor(model.OrderSortAccrualDesc, order)
+
+	req := ListOrdersRequest{Sort: "-accrual", Cursor: cursor}
+	q, err := req.ToQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.After == nil || q.After.Number != order.Number || !q.After.Accrual.Equal(order.Accrual) ||
+		!q.After.UploadedAt.Equal(order.UploadedAt) {
+		t.Errorf("cursor round trip mismatch: %+v", q.After)
+	}
+
+	// курсор другой сортировки не принимается
+	req = ListOrdersRequest{Sort: "uploaded_at", Cursor: cursor}
+	if _, err := req.ToQuery(); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestNewOrderDetailResponse(t *testing.T) {
+	start := time.Date(2025, 8, 14, 12, 0, 0, 0, time.UTC)
+	history := []model.OrderStatusChange{
+		{Status: model.OrderStatusNew, Accrual: decimal.Zero, ChangedAt: start},
+		{Status: model.OrderStatusProcessing, Accrual: decimal.Zero, ChangedAt: start.Add(time.Minute)},
+		{Status: model.OrderStatusProcessed, Accrual: decimal.RequireFromString("500"), ChangedAt: start.Add(2 * time.Minute)},
+		{Status: model.OrderStatusProcessed, Accrual: decimal.RequireFromString("450.5"), ChangedAt: start.Add(time.Hour)},
+	}
+
+	resp := NewOrderDetailResponse(model.Order{Number: "12345678903"}, history, nil)
+	if len(resp.History) != len(history) {
+		t.Fatalf("expected %d history entries, got %d", len(history), len(resp.History))
+	}
+	if resp.Receipt != nil {
+		t.Errorf("expected no receipt, got %+v", resp.Receipt)
+	}
+
+	changes := []string{"0", "0", "500", "-49.5"}
+	for i, want := range changes {
+		if !resp.History[i].AccrualChange.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("entry %d: expected accrual change %s, got %s", i, want, resp.History[i].AccrualChange)
+		}
+	}
+}
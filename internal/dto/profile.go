@@ -0,0 +1,190 @@
+package dto
+
+import (
+	"errors"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+var ErrInvalidDisplayName = errors.New("display name must be at most 64 printable characters")
+var ErrInvalidEmail = errors.New("invalid email")
+var ErrInvalidPhone = errors.New("phone must be in international format, e.g. +79991234567")
+var ErrInvalidBirthday = errors.New("birthday must be a past date in YYYY-MM-DD format")
+
+const (
+	displayNameMaxLen = 64
+	emailMaxLen       = 254
+	// формат даты рождения в запросах и ответах
+	birthdayLayout = "2006-01-02"
+	// старше считаем опечаткой
+	maxAgeYears = 130
+)
+
+// E.164: + и от 8 до 15 цифр
+var phoneRe = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+type MarketingConsents struct {
+	Email     bool       `json:"email"`
+	SMS       bool       `json:"sms"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type ProfileResponse struct {
+	ID          string            `json:"id"`
+	Login       string            `json:"login"`
+	Role        string            `json:"role"`
+	DisplayName *string           `json:"display_name"`
+	Email       *string           `json:"email"`
+	Phone       *string           `json:"phone"`
+	Birthday    *string           `json:"birthday" example:"1990-05-17"`
+	Marketing   MarketingConsents `json:"marketing"`
+	// подтвержден ли адрес для писем: email профиля, а без него логин
+	EmailVerified bool `json:"email_verified"`
+}
+
+// NewProfileResponse собирает ответ; profile может быть nil, если профиль не заполнен
+func NewProfileResponse(user *model.User, profile *model.UserProfile) ProfileResponse {
+	resp := ProfileResponse{
+		ID:    user.ID.String(),
+		Login: user.Login,
+		Role:  string(user.Role),
+		// без подтверждения недоступно списание баллов
+		EmailVerified: user.EmailVerifiedAt != nil,
+	}
+	if profile == nil {
+		return resp
+	}
+
+	resp.DisplayName = profile.DisplayName
+	resp.Email = profile.Email
+	resp.Phone = profile.Phone
+	if profile.Birthday != nil {
+		birthday := profile.Birthday.Format(birthdayLayout)
+		resp.Birthday = &birthday
+	}
+	resp.Marketing = MarketingConsents{
+		Email:     profile.MarketingEmail,
+		SMS:       profile.MarketingSMS,
+		UpdatedAt: profile.ConsentsUpdatedAt,
+	}
+	return resp
+}
+
+type UpdateConsentsRequest struct {
+	Email *bool `json:"email,omitempty"`
+	SMS   *bool `json:"sms,omitempty"`
+}
+
+// UpdateProfileRequest частичное обновление профиля: меняются только
+// переданные поля, пустая строка очищает поле
+type UpdateProfileRequest struct {
+	DisplayName *string                `json:"display_name,omitempty"`
+	Email       *string                `json:"email,omitempty"`
+	Phone       *string                `json:"phone,omitempty"`
+	Birthday    *string                `json:"birthday,omitempty" example:"1990-05-17"`
+	Marketing   *UpdateConsentsRequest `json:"marketing,omitempty"`
+
+	birthday *time.Time
+}
+
+// Validate проверяет и нормализует переданные поля
+func (r *UpdateProfileRequest) Validate(now time.Time) error {
+	if r.DisplayName != nil {
+		name := strings.TrimSpace(*r.DisplayName)
+		if utf8.RuneCountInString(name) > displayNameMaxLen ||
+			strings.IndexFunc(name, func(c rune) bool { return !unicode.IsPrint(c) }) >= 0 {
+			return ErrInvalidDisplayName
+		}
+		r.DisplayName = &name
+	}
+
+	if r.Email != nil {
+		email := strings.TrimSpace(*r.Email)
+		if email != "" {
+			var err error
+			if email, err = ParseEmail(email); err != nil {
+				return err
+			}
+		}
+		r.Email = &email
+	}
+
+	if r.Phone != nil {
+		phone := strings.Map(func(c rune) rune {
+			if c == ' ' || c == '-' || c == '(' || c == ')' {
+				return -1
+			}
+			return c
+		}, *r.Phone)
+		if phone != "" && !phoneRe.MatchString(phone) {
+			return ErrInvalidPhone
+		}
+		r.Phone = &phone
+	}
+
+	if r.Birthday != nil && *r.Birthday != "" {
+		birthday, err := time.Parse(birthdayLayout, *r.Birthday)
+		if err != nil || birthday.After(now) || birthday.Before(now.AddDate(-maxAgeYears, 0, 0)) {
+			return ErrInvalidBirthday
+		}
+		r.birthday = &birthday
+	}
+	return nil
+}
+
+// Apply переносит переданные поля в профиль. Вызывать после Validate
+func (r *UpdateProfileRequest) Apply(p *model.UserProfile, now time.Time) {
+	if r.DisplayName != nil {
+		p.DisplayName = nullable(*r.DisplayName)
+	}
+	if r.Email != nil {
+		p.Email = nullable(*r.Email)
+	}
+	if r.Phone != nil {
+		p.Phone = nullable(*r.Phone)
+	}
+	if r.Birthday != nil {
+		p.Birthday = r.birthday
+	}
+
+	if r.Marketing != nil {
+		changed := false
+		if r.Marketing.Email != nil && *r.Marketing.Email != p.MarketingEmail {
+			p.MarketingEmail = *r.Marketing.Email
+			changed = true
+		}
+		if r.Marketing.SMS != nil && *r.Marketing.SMS != p.MarketingSMS {
+			p.MarketingSMS = *r.Marketing.SMS
+			changed = true
+		}
+		// время согласия храним для подтверждения при проверках
+		if changed {
+			p.ConsentsUpdatedAt = &now
+		}
+	}
+	p.UpdatedAt = now
+}
+
+// ParseEmail проверяет адрес и приводит его к нижнему регистру
+func ParseEmail(s string) (string, error) {
+	email := strings.ToLower(s)
+	addr, err := mail.ParseAddress(email)
+	// отбрасываем формы вида "Name <a@b>"
+	if err != nil || addr.Address != email || len(email) > emailMaxLen {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,122 @@
+package dto
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestUpdateProfileRequestValidate(t *testing.T) {
+	now := time.Date(2025, 8, 9, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		req  UpdateProfileRequest
+		err  error
+	}{
+		{
+			name: "full profile",
+			req: UpdateProfileRequest{
+				DisplayName: ptr(" Иван "),
+				Email:       ptr("Ivan@Example.com"),
+				Phone:       ptr("+7 (999) 123-45-67"),
+				Birthday:    ptr("1990-05-17"),
+			},
+		},
+		{
+			name: "clear fields",
+			req:  UpdateProfileRequest{Email: ptr(""), Phone: ptr(""), Birthday: ptr("")},
+		},
+		{
+			name: "long display name",
+			req:  UpdateProfileRequest{DisplayName: ptr(strings.Repeat("я", 65))},
+			err:  ErrInvalidDisplayName,
+		},
+		{
+			name: "control characters in display name",
+			req:  UpdateProfileRequest{DisplayName: ptr("Iv\x00an")},
+			err:  ErrInvalidDisplayName,
+		},
+		{
+			name: "bad email",
+			req:  UpdateProfileRequest{Email: ptr("ivan@")},
+			err:  ErrInvalidEmail,
+		},
+		{
+			name: "email with name",
+			req:  UpdateProfileRequest{Email: ptr("Ivan <ivan@example.com>")},
+			err:  ErrInvalidEmail,
+		},
+		{
+			name: "local phone",
+			req:  UpdateProfileRequest{Phone: ptr("89991234567")},
+			err:  ErrInvalidPhone,
+		},
+		{
+			name: "bad birthday format",
+			req:  UpdateProfileRequest{Birthday: ptr("17.05.1990")},
+			err:  ErrInvalidBirthday,
+		},
+		{
+			name: "birthday in future",
+			req:  UpdateProfileRequest{Birthday: ptr("2025-08-10")},
+			err:  ErrInvalidBirthday,
+		},
+		{
+			name: "too old",
+			req:  UpdateProfileRequest{Birthday: ptr("1890-01-01")},
+			err:  ErrInvalidBirthday,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(now); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestUpdateProfileRequestApply(t *testing.T) {
+	now := time.Date(2025, 8, 9, 12, 0, 0, 0, time.UTC)
+	profile := &model.UserProfile{
+		DisplayName:  ptr("Иван"),
+		Phone:        ptr("+79991234567"),
+		MarketingSMS: true,
+	}
+
+	req := UpdateProfileRequest{
+		Email:     ptr("Ivan@Example.com"),
+		Phone:     ptr(""),
+		Birthday:  ptr("1990-05-17"),
+		Marketing: &UpdateConsentsRequest{Email: ptr(true), SMS: ptr(true)},
+	}
+	if err := req.Validate(now); err != nil {
+		t.Fatal(err)
+	}
+	req.Apply(profile, now)
+
+	if profile.DisplayName == nil || *profile.DisplayName != "Иван" {
+		t.Errorf("display name must stay unchanged, got %v", profile.DisplayName)
+	}
+	if profile.Email == nil || *profile.Email != "ivan@example.com" {
+		t.Errorf("expected normalized email, got %v", profile.Email)
+	}
+	if profile.Phone != nil {
+		t.Errorf("expected phone to be cleared, got %v", *profile.Phone)
+	}
+	if profile.Birthday == nil || profile.Birthday.Format(birthdayLayout) != "1990-05-17" {
+		t.Errorf("unexpected birthday %v", profile.Birthday)
+	}
+	if !profile.MarketingEmail || profile.ConsentsUpdatedAt == nil || !profile.ConsentsUpdatedAt.Equal(now) {
+		t.Errorf("expected email consent to be recorded at %v", now)
+	}
+}
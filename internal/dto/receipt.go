@@ -0,0 +1,130 @@
+package dto
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+var ErrEmptyReceipt = errors.New("receipt must contain at least one item")
+var ErrReceiptTooLarge = errors.New("receipt contains too many items")
+var ErrInvalidCurrency = errors.New("currency must be an ISO 4217 code, e.g. RUB")
+var ErrInvalidReceiptItem = errors.New("item must have sku, positive quantity and non-negative price")
+var ErrReceiptTotalMismatch = errors.New("total must be equal to the sum of items")
+
+const (
+	// MaxReceiptItems предел позиций в одном чеке
+	MaxReceiptItems    = 500
+	receiptFieldMaxLen = 128
+)
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type ReceiptItemRequest struct {
+	SKU      string          `json:"sku" example:"4607001771425"`
+	Category string          `json:"category,omitempty" example:"coffee"`
+	Quantity decimal.Decimal `json:"quantity" example:"2"`
+	// цена за единицу с учетом скидок
+	Price decimal.Decimal `json:"price" example:"149.50"`
+}
+
+// ReceiptRequest чек покупки от кассы партнера
+type ReceiptRequest struct {
+	Total    decimal.Decimal      `json:"total" example:"299"`
+	Currency string               `json:"currency" example:"RUB"`
+	Items    []ReceiptItemRequest `json:"items"`
+}
+
+// Validate проверяет чек и собирает модель. Сумма чека должна совпадать
+// с суммой позиций с точностью до копейки
+func (r *ReceiptRequest) Validate(orderNumber string, now time.Time) (*model.Receipt, error) {
+	if len(r.Items) == 0 {
+		return nil, ErrEmptyReceipt
+	}
+	if len(r.Items) > MaxReceiptItems {
+		return nil, ErrReceiptTooLarge
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(r.Currency))
+	if !currencyRe.MatchString(currency) {
+		return nil, ErrInvalidCurrency
+	}
+
+	receipt := &model.Receipt{
+		OrderNumber: orderNumber,
+		Total:       r.Total,
+		Currency:    currency,
+		Items:       make([]model.ReceiptItem, 0, len(r.Items)),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	sum := decimal.Zero
+	for _, item := range r.Items {
+		sku := strings.TrimSpace(item.SKU)
+		category := strings.ToLower(strings.TrimSpace(item.Category))
+		if sku == "" || utf8.RuneCountInString(sku) > receiptFieldMaxLen ||
+			utf8.RuneCountInString(category) > receiptFieldMaxLen ||
+			!item.Quantity.IsPositive() || item.Price.IsNegative() {
+			return nil, ErrInvalidReceiptItem
+		}
+		it := model.ReceiptItem{
+			SKU:      sku,
+			Category: category,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		}
+		sum = sum.Add(it.Amount())
+		receipt.Items = append(receipt.Items, it)
+	}
+
+	if r.Total.IsNegative() || !sum.Round(2).Equal(r.Total.Round(2)) {
+		return nil, ErrReceiptTotalMismatch
+	}
+	return receipt, nil
+}
+
+type ReceiptItemResponse struct {
+	SKU      string          `json:"sku"`
+	Category string          `json:"category,omitempty"`
+	Quantity decimal.Decimal `json:"quantity"`
+	Price    decimal.Decimal `json:"price"`
+}
+
+type ReceiptResponse struct {
+	OrderNumber string                `json:"order_number"`
+	MerchantID  *string               `json:"merchant_id,omitempty"`
+	Total       decimal.Decimal       `json:"total"`
+	Currency    string                `json:"currency"`
+	Items       []ReceiptItemResponse `json:"items"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+func NewReceiptResponse(r *model.Receipt) ReceiptResponse {
+	resp := ReceiptResponse{
+		OrderNumber: r.OrderNumber,
+		Total:       r.Total,
+		Currency:    r.Currency,
+		Items:       make([]ReceiptItemResponse, 0, len(r.Items)),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if r.MerchantID != nil {
+		id := r.MerchantID.String()
+		resp.MerchantID = &id
+	}
+	for _, item := range r.Items {
+		resp.Items = append(resp.Items, ReceiptItemResponse{
+			SKU:      item.SKU,
+			Category: item.Category,
+			Quantity: item.Quantity,
+			Price:    item.Price,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,71 @@
+package dto
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestReceiptRequestValidate(t *testing.T) {
+	item := func(sku, quantity, price string) ReceiptItemRequest {
+		return ReceiptItemRequest{SKU: sku, Category: " Coffee ",
+			Quantity: decimal.RequireFromString(quantity), Price: decimal.RequireFromString(price)}
+	}
+	tests := []struct {
+		name string
+		req  ReceiptRequest
+		err  error
+	}{
+		{
+			name: "valid",
+			req: ReceiptRequest{Total: decimal.RequireFromString("349.25"), Currency: "rub",
+				Items: []ReceiptItemRequest{item("A-1", "2", "149.5"), item("B-2", "0.5", "100.5")}},
+		},
+		{name: "no items", req: ReceiptRequest{Currency: "RUB"}, err: ErrEmptyReceipt},
+		{
+			name: "bad currency",
+			req:  ReceiptRequest{Total: decimal.RequireFromString("1"), Currency: "RUBL", Items: []ReceiptItemRequest{item("A", "1", "1")}},
+			err:  ErrInvalidCurrency,
+		},
+		{
+			name: "no sku",
+			req:  ReceiptRequest{Total: decimal.RequireFromString("1"), Currency: "RUB", Items: []ReceiptItemRequest{item(" ", "1", "1")}},
+			err:  ErrInvalidReceiptItem,
+		},
+		{
+			name: "zero quantity",
+			req:  ReceiptRequest{Total: decimal.Zero, Currency: "RUB", Items: []ReceiptItemRequest{item("A", "0", "1")}},
+			err:  ErrInvalidReceiptItem,
+		},
+		{
+			name: "negative price",
+			req:  ReceiptRequest{Total: decimal.Zero, Currency: "RUB", Items: []ReceiptItemRequest{item("A", "1", "-1")}},
+			err:  ErrInvalidReceiptItem,
+		},
+		{
+			name: "total mismatch",
+			req:  ReceiptRequest{Total: decimal.RequireFromString("300"), Currency: "RUB", Items: []ReceiptItemRequest{item("A", "2", "149.5")}},
+			err:  ErrReceiptTotalMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receipt, err := tt.req.Validate("12345678903", time.Now())
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if receipt.Currency != "RUB" || len(receipt.Items) != len(tt.req.Items) {
+				t.Errorf("unexpected receipt %+v", receipt)
+			}
+			if receipt.Items[0].Category != "coffee" {
+				t.Errorf("expected normalized category, got %q", receipt.Items[0].Category)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+// ClientInfo данные клиента, с которого открыта сессия
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+type SessionResponse struct {
+	ID        string    `json:"id"`
+	UserAgent string    `json:"device"`
+	IP        string    `json:"ip"`
+	AuthAt    time.Time `json:"auth_at"`
+	ExpireAt  time.Time `json:"expire_at"`
+}
+
+type GetSessionsResponse struct {
+	Sessions []SessionResponse `json:"sessions"`
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+)
+
+type AccountHandler struct {
+	serv interfaces.AccountServiceInterface
+}
+
+// фабрика
+func NewAccountHandler(accountService interfaces.AccountServiceInterface) *AccountHandler {
+	return &AccountHandler{
+		serv: accountService,
+	}
+}
+
+// Export godoc
+// @Summary      Выгрузка данных пользователя
+// @Description  Возвращает zip архив с профилем, заказами, списаниями и сессиями пользователя в json. Состав архива описан в manifest.json
+// @Security     BearerAuth
+// @Tags         account
+// @Produce      application/zip
+// @Success      200    {file}    file
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/export [get]
+func (h *AccountHandler) Export(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "AccountHandler.Export")
+	defer span.End()
+
+	archive, err := h.serv.Export(ctx)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to export data"))
+		return
+	}
+
+	filename := fmt.Sprintf("loyalityhub-export-%s.zip", time.Now().UTC().Format("20060102"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// DeleteMe godoc
+// @Summary      Удаление аккаунта
+// @Description  Планирует удаление аккаунта и завершает все сессии. По истечении grace периода логин и персональные данные обезличиваются, финансовые записи сохраняются. Вход в аккаунт до этого срока отменяет удаление. Аккаунт без пароля (вход через OIDC провайдер) вместо пароля подтверждается входом через провайдер не более 5 минут назад
+// @Security     BearerAuth
+// @Tags         account
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.DeleteAccountRequest  true  "Текущий пароль, пусто для аккаунта без пароля"
+// @Success      202    {object}  dto.DeleteAccountResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "неверный пароль или нужен повторный вход через провайдер"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/me [delete]
+func (h *AccountHandler) DeleteMe(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "AccountHandler.DeleteMe")
+	defer span.End()
+
+	var req dto.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	resp, err := h.serv.RequestDeletion(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, dto.ErrEmptyPassword):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse("invalid password"))
+		case errors.Is(err, model.ErrReauthRequired):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to delete account"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, resp)
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type AdminHandler struct {
+	userService interfaces.UserServiceInterface
+}
+
+func NewAdminHandler(userService interfaces.UserServiceInterface) *AdminHandler {
+	return &AdminHandler{
+		userService: userService,
+	}
+}
+
+// UnlockAccount godoc
+// @Summary      Разблокировка входа
+// @Description  Сбрасывает счетчик неудачных попыток входа и снимает блокировку для логина
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        login  path      string  true  "Логин пользователя"
+// @Success      200    {string}  string  "блокировка снята"
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/lockouts/{login} [delete]
+func (h *AdminHandler) UnlockAccount(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "AdminHandler.UnlockAccount")
+	defer span.End()
+
+	login := c.Param("login")
+	err := h.userService.UnlockAccount(ctx, login)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrNotLocked) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to unlock account"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("user.login", login))
+	c.JSON(http.StatusOK, "account unlocked")
+}
+
+// SetUserRole godoc
+// @Summary      Смена роли пользователя
+// @Description  Назначает пользователю роль: customer, support, admin или merchant и завершает все его сессии. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string              true  "ID пользователя"
+// @Param        input  body      dto.SetRoleRequest  true  "Новая роль"
+// @Success      200    {string}  string  "роль изменена"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/users/{id}/role [put]
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "AdminHandler.SetUserRole")
+	defer span.End()
+
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid user id"))
+		return
+	}
+
+	var req dto.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	err := h.userService.SetUserRole(ctx, userID, model.Role(req.Role))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to set role"))
+		}
+		return
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.String("user.role", req.Role))
+	c.JSON(http.StatusOK, "role updated")
+}
+
+// BanUser godoc
+// @Summary      Блокировка пользователя
+// @Description  Блокирует пользователя: вход запрещается, все сессии завершаются, выданные access токены сразу перестают действовать. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        id     path      string  true  "ID пользователя"
+// @Success      200    {string}  string  "пользователь заблокирован"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/users/{id}/ban [put]
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	h.setUserBanned(c, true)
+}
+
+// UnbanUser godoc
+// @Summary      Разблокировка пользователя
+// @Description  Снимает блокировку пользователя. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        id     path      string  true  "ID пользователя"
+// @Success      200    {string}  string  "пользователь разблокирован"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/users/{id}/ban [delete]
+func (h *AdminHandler) UnbanUser(c *gin.Context) {
+	h.setUserBanned(c, false)
+}
+
+func (h *AdminHandler) setUserBanned(c *gin.Context, banned bool) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "AdminHandler.SetUserBanned")
+	defer span.End()
+
+	userID := c.Param("id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid user id"))
+		return
+	}
+
+	err := h.userService.SetUserBanned(ctx, userID, banned)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to update user ban"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.Bool("user.banned", banned))
+	if banned {
+		c.JSON(http.StatusOK, "user banned")
+		return
+	}
+	c.JSON(http.StatusOK, "user unbanned")
+}
@@ -51,16 +51,20 @@ func (h *BalanceHandler) GetBalance(c *gin.Context) {
 
 // Withdraw godoc
 // @Summary      Списание средств с баланса
-// @Description  Позволяет списать средства на заказ
+// @Description  Позволяет списать средства на заказ. Доступно только пользователям с подтвержденным email
 // @Security     BearerAuth
 // @Tags         balance
 // @Accept       json
 // @Produce      json
 // @Param        input  body      dto.NewWithdrawnRequest  true  "Данные списания"
+// @Param        Idempotency-Key  header  string  false  "Ключ для безопасного повтора запроса"
 // @Success      200    {string}  string  "успешное списание"
 // @Failure      400    {object}  dto.ErrorResponse
 // @Failure      401    {object}  dto.ErrorResponse
 // @Failure      402    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "email не подтвержден"
+// @Failure      404    {object}  dto.ErrorResponse  "магазин не найден"
+// @Failure      409    {object}  dto.ErrorResponse  "Idempotency-Key уже использован с другим запросом"
 // @Failure      500    {object}  dto.ErrorResponse
 // @Router       /api/v1/user/balance/withdraw [post]
 func (h *BalanceHandler) Withdraw(c *gin.Context) {
@@ -79,6 +83,12 @@ func (h *BalanceHandler) Withdraw(c *gin.Context) {
 		switch {
 		case errors.Is(err, model.ErrInsufficientFunds):
 			c.JSON(http.StatusPaymentRequired, dto.NewErrorResponse("not enough funds"))
+		case errors.Is(err, model.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, dto.ErrInvalidMerchantID):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrMerchantNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
 		default:
 			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("withdraw failed"))
 		}
@@ -88,13 +98,39 @@ func (h *BalanceHandler) Withdraw(c *gin.Context) {
 	c.JSON(http.StatusOK, "withdraw completed")
 }
 
+// IssueRedemptionCode godoc
+// @Summary      Код списания на кассе партнера
+// @Description  Выпускает одноразовый код на 5 минут. Покупатель показывает его кассиру, без кода касса не может списать баллы. Прежние коды перестают действовать
+// @Security     BearerAuth
+// @Tags         balance
+// @Produce      json
+// @Success      201  {object}  dto.RedemptionCodeResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api/v1/user/balance/redemption-code [post]
+func (h *BalanceHandler) IssueRedemptionCode(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "BalanceHandler.IssueRedemptionCode")
+	defer span.End()
+
+	resp, err := h.serv.IssueRedemptionCode(ctx)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to issue redemption code"))
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
 // GetWithdrawals godoc
 // @Summary      История выводов средств
 // @Description  Получение всех транзакций списания пользователя
 // @Security     BearerAuth
 // @Tags         balance
 // @Produce      json
+// @Param        merchant_id  query  string  false  "Только списания в этом магазине"
 // @Success      200  {object}  dto.GetAllWithdrawalsResponse
+// @Failure      400  {object}  dto.ErrorResponse
 // @Failure      401  {object}  dto.ErrorResponse
 // @Failure      500  {object}  dto.ErrorResponse
 // @Router       /api/v1/user/withdrawals [get]
@@ -102,9 +138,13 @@ func (h *BalanceHandler) GetWithdrawals(c *gin.Context) {
 	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "BalanceHandler.GetWithdrawals")
 	defer span.End()
 
-	res, err := h.serv.GetWithdrawals(ctx)
+	res, err := h.serv.GetWithdrawals(ctx, c.Query("merchant_id"))
 	if err != nil {
 		span.RecordError(err)
+		if errors.Is(err, dto.ErrInvalidMerchantID) {
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to get withdrawals"))
 		return
 	}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+)
+
+// VerifyEmail godoc
+// @Summary      Подтверждение email
+// @Description  Подтверждает адрес по одноразовому токену из письма. После подтверждения становится доступно списание баллов
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.VerifyEmailRequest  true  "Токен подтверждения"
+// @Success      200    {string}  string  "email подтвержден"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/email/verify [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.VerifyEmail")
+	defer span.End()
+
+	var req dto.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	err := h.UserService.VerifyEmail(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrInvalidVerificationToken):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to verify email"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "email verified")
+}
+
+// ResendVerification godoc
+// @Summary      Повторная отправка письма подтверждения
+// @Description  Отправляет новый токен подтверждения на email профиля, а если он не заполнен - на логин-email. Не чаще раза в EMAIL_VERIFICATION_INTERVAL
+// @Security     BearerAuth
+// @Tags         user
+// @Produce      json
+// @Success      202    {string}  string  "письмо отправлено"
+// @Failure      400    {object}  dto.ErrorResponse  "нет адреса для подтверждения"
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "email уже подтвержден"
+// @Failure      429    {object}  dto.ErrorResponse  "письмо уже отправлялось недавно"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/email/verify/resend [post]
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ResendVerification")
+	defer span.End()
+
+	err := h.UserService.ResendVerification(ctx)
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrNoEmail):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrVerificationThrottled):
+			c.JSON(http.StatusTooManyRequests, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to send verification"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, "verification email sent")
+}
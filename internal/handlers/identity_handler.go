@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ExternalLogin godoc
+// @Summary      Вход через внешний провайдер
+// @Description  Перенаправляет браузер на страницу входа OIDC провайдера (authorization code + PKCE). Провайдер вернет пользователя на redirect url с параметрами code и state. State кладется в HttpOnly cookie oidc_state, callback без нее отклоняется
+// @Tags         user
+// @Param        provider  path  string  true  "Имя провайдера из OIDC_PROVIDERS"
+// @Success      302
+// @Failure      404    {object}  dto.ErrorResponse  "провайдер не настроен"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/auth/oidc/{provider}/login [get]
+func (h *UserHandler) ExternalLogin(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ExternalLogin")
+	defer span.End()
+
+	resp, err := h.UserService.StartExternalLogin(ctx, c.Param("provider"))
+	if err != nil {
+		span.RecordError(err)
+		respondExternalError(c, err)
+		return
+	}
+
+	http.SetCookie(c.Writer, h.cookies.LoginState(resp.State))
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, resp.AuthURL)
+}
+
+// ExternalCallback godoc
+// @Summary      Завершение входа через внешний провайдер
+// @Description  Меняет code и state от провайдера на access/refresh токены. Новая учетная запись провайдера получает аккаунт, логин которого - подтвержденный провайдером email. Параметры принимаются в query (GET) или в теле (POST). State должен совпадать с cookie oidc_state браузера, начавшего вход
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        provider     path    string  true   "Имя провайдера из OIDC_PROVIDERS"
+// @Param        X-Auth-Mode  header  string  false  "cookie - refresh токен возвращается в HttpOnly cookie"
+// @Param        input  body      dto.ExternalCallbackRequest  false  "code и state от провайдера"
+// @Success      200    {object}  dto.AuthResponse
+// @Failure      400    {object}  dto.ErrorResponse  "state не найден или истек"
+// @Failure      401    {object}  dto.ErrorResponse  "провайдер не подтвердил вход"
+// @Failure      403    {object}  dto.ErrorResponse  "пользователь заблокирован"
+// @Failure      404    {object}  dto.ErrorResponse  "провайдер не настроен"
+// @Failure      409    {object}  dto.ErrorResponse  "логин занят или учетная запись привязана к другому аккаунту"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/auth/oidc/{provider}/callback [get]
+// @Router       /api/v1/auth/oidc/{provider}/callback [post]
+func (h *UserHandler) ExternalCallback(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ExternalCallback")
+	defer span.End()
+
+	var req dto.ExternalCallbackRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	// state одноразовый, cookie больше не нужна
+	cookie, cookieErr := c.Cookie(authcookie.LoginStateCookie)
+	http.SetCookie(c.Writer, h.cookies.ClearLoginState())
+	if cookieErr != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(req.State)) != 1 {
+		span.RecordError(model.ErrInvalidLoginState)
+		respondExternalError(c, model.ErrInvalidLoginState)
+		return
+	}
+
+	provider := c.Param("provider")
+	resp, err := h.UserService.CompleteExternalLogin(ctx, provider, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		respondExternalError(c, err)
+		return
+	}
+	span.SetAttributes(attribute.String("identity.provider", provider))
+	if !resp.MFARequired && cookieMode(c) {
+		if err := h.setSessionCookies(c, resp.RefreshToken); err != nil {
+			span.RecordError(err)
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			return
+		}
+		resp.RefreshToken = ""
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// LinkIdentity godoc
+// @Summary      Привязка внешнего провайдера
+// @Description  Возвращает адрес страницы входа провайдера и кладет state в HttpOnly cookie oidc_state. После возврата на callback в том же браузере учетная запись провайдера привязывается к текущему аккаунту
+// @Security     BearerAuth
+// @Tags         user
+// @Produce      json
+// @Param        provider  path  string  true  "Имя провайдера из OIDC_PROVIDERS"
+// @Success      200    {object}  dto.ExternalLoginResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse  "провайдер не настроен"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/identities/{provider} [post]
+func (h *UserHandler) LinkIdentity(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.LinkIdentity")
+	defer span.End()
+
+	resp, err := h.UserService.StartIdentityLink(ctx, c.Param("provider"))
+	if err != nil {
+		span.RecordError(err)
+		respondExternalError(c, err)
+		return
+	}
+
+	http.SetCookie(c.Writer, h.cookies.LoginState(resp.State))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondExternalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrUnknownProvider):
+		c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+	case errors.Is(err, model.ErrInvalidLoginState):
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+	case errors.Is(err, model.ErrExternalAuthFailed):
+		c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(model.ErrExternalAuthFailed.Error()))
+	case errors.Is(err, model.ErrUserBanned):
+		c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+	case errors.Is(err, model.ErrIdentityConflict), errors.Is(err, model.ErrIdentityLinked):
+		c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+	}
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	tokenmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/tokenManager"
+)
+
+// JWKSProvider источник публичных ключей подписи токенов
+type JWKSProvider interface {
+	JWKS() tokenmanager.JWKS
+}
+
+type JWKSHandler struct {
+	keys JWKSProvider
+}
+
+func NewJWKSHandler(keys JWKSProvider) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+// GetJWKS godoc
+// @Summary      Публичные ключи подписи токенов
+// @Description  Возвращает JWKS (RFC 7517) для офлайн проверки access токенов партнерскими сервисами
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  tokenmanager.JWKS
+// @Router       /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// MerchantAdminHandler управление магазинами партнеров из админки
+type MerchantAdminHandler struct {
+	merchants interfaces.MerchantServiceInterface
+}
+
+func NewMerchantAdminHandler(merchants interfaces.MerchantServiceInterface) *MerchantAdminHandler {
+	return &MerchantAdminHandler{
+		merchants: merchants,
+	}
+}
+
+// CreateMerchant godoc
+// @Summary      Создание магазина
+// @Description  Создает магазин партнера. Владелец должен иметь роль merchant, его API ключи работают от имени магазина. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.CreateMerchantRequest  true  "Магазин"
+// @Success      201    {object}  dto.MerchantResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "имя или владелец уже заняты"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants [post]
+func (h *MerchantAdminHandler) CreateMerchant(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.CreateMerchant")
+	defer span.End()
+
+	var req dto.CreateMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	resp, err := h.merchants.Create(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		respondMerchantError(c, err, "failed to create merchant")
+		return
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", resp.ID))
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetMerchants godoc
+// @Summary      Список магазинов
+// @Description  Возвращает все магазины партнеров
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Success      200    {object}  dto.GetMerchantsResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants [get]
+func (h *MerchantAdminHandler) GetMerchants(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.GetMerchants")
+	defer span.End()
+
+	resp, err := h.merchants.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to get merchants"))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetMerchant godoc
+// @Summary      Магазин
+// @Description  Возвращает магазин партнера
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        id     path      string  true  "ID магазина"
+// @Success      200    {object}  dto.MerchantResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants/{id} [get]
+func (h *MerchantAdminHandler) GetMerchant(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.GetMerchant")
+	defer span.End()
+
+	id, ok := merchantIDParam(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.merchants.Get(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		respondMerchantError(c, err, "failed to get merchant")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateMerchant godoc
+// @Summary      Изменение магазина
+// @Description  Меняет только переданные поля. Пустые owner_id и order_number_validator очищают поле. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param        id     path      string                     true  "ID магазина"
+// @Param        input  body      dto.UpdateMerchantRequest  true  "Изменяемые поля"
+// @Success      200    {object}  dto.MerchantResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "имя или владелец уже заняты"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants/{id} [patch]
+func (h *MerchantAdminHandler) UpdateMerchant(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.UpdateMerchant")
+	defer span.End()
+
+	id, ok := merchantIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.UpdateMerchantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	resp, err := h.merchants.Update(ctx, id, req)
+	if err != nil {
+		span.RecordError(err)
+		respondMerchantError(c, err, "failed to update merchant")
+		return
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id))
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteMerchant godoc
+// @Summary      Удаление магазина
+// @Description  Удаляет магазин. Заказы и списания магазина остаются, но теряют привязку к нему. Доступно только администраторам
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        id     path      string  true  "ID магазина"
+// @Success      200    {string}  string  "магазин удален"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants/{id} [delete]
+func (h *MerchantAdminHandler) DeleteMerchant(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.DeleteMerchant")
+	defer span.End()
+
+	id, ok := merchantIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.merchants.Delete(ctx, id); err != nil {
+		span.RecordError(err)
+		respondMerchantError(c, err, "failed to delete merchant")
+		return
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id))
+	c.JSON(http.StatusOK, "merchant deleted")
+}
+
+// GetMerchantReport godoc
+// @Summary      Отчет по магазину
+// @Description  Число заказов, начисления, возвраты и списания магазина за период. Заказы попадают в период по времени загрузки, списания - по времени списания
+// @Security     BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        id     path      string  true   "ID магазина"
+// @Param        from   query     string  false  "Начало периода (RFC 3339)"
+// @Param        to     query     string  false  "Конец периода, не включительно (RFC 3339)"
+// @Success      200    {object}  dto.MerchantReportResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/merchants/{id}/report [get]
+func (h *MerchantAdminHandler) GetMerchantReport(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantAdminHandler.GetMerchantReport")
+	defer span.End()
+
+	id, ok := merchantIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req dto.MerchantReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid query"))
+		return
+	}
+
+	resp, err := h.merchants.Report(ctx, id, req)
+	if err != nil {
+		span.RecordError(err)
+		respondMerchantError(c, err, "failed to build merchant report")
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func merchantIDParam(c *gin.Context) (string, bool) {
+	id := c.Param("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid merchant id"))
+		return "", false
+	}
+	return id, true
+}
+
+func respondMerchantError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, dto.ErrInvalidMerchantName), errors.Is(err, dto.ErrInvalidMerchantOwnerID),
+		errors.Is(err, dto.ErrInvalidMerchantValidator), errors.Is(err, dto.ErrInvalidTimeRange),
+		errors.Is(err, model.ErrInvalidMerchantOwner):
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+	case errors.Is(err, model.ErrMerchantNotFound):
+		c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+	case errors.Is(err, model.ErrMerchantNameTaken), errors.Is(err, model.ErrMerchantOwnerTaken):
+		c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse(fallback))
+	}
+}
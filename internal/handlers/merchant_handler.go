@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type MerchantHandler struct {
+	apiKeys interfaces.APIKeyServiceInterface
+}
+
+func NewMerchantHandler(apiKeys interfaces.APIKeyServiceInterface) *MerchantHandler {
+	return &MerchantHandler{
+		apiKeys: apiKeys,
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary      Выпуск API ключа
+// @Description  Выпускает API ключ для кассы партнера. Ключ показывается только в этом ответе. Доступные права: orders:write, redemptions:write
+// @Security     BearerAuth
+// @Tags         merchant
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.CreateAPIKeyRequest  true  "Название и права ключа"
+// @Success      201    {object}  dto.CreateAPIKeyResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/merchant/keys [post]
+func (h *MerchantHandler) CreateAPIKey(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantHandler.CreateAPIKey")
+	defer span.End()
+
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	resp, err := h.apiKeys.Create(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrInvalidScope) {
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to create api key"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", resp.Prefix))
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetAPIKeys godoc
+// @Summary      Список API ключей
+// @Description  Возвращает действующие API ключи мерчанта без самих ключей
+// @Security     BearerAuth
+// @Tags         merchant
+// @Produce      json
+// @Success      200    {object}  dto.GetAPIKeysResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/merchant/keys [get]
+func (h *MerchantHandler) GetAPIKeys(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantHandler.GetAPIKeys")
+	defer span.End()
+
+	resp, err := h.apiKeys.List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to get api keys"))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RotateAPIKey godoc
+// @Summary      Ротация API ключа
+// @Description  Выпускает новый ключ с теми же правами. Старый ключ продолжает работать 24 часа
+// @Security     BearerAuth
+// @Tags         merchant
+// @Produce      json
+// @Param        id     path      string  true  "ID ключа"
+// @Success      201    {object}  dto.CreateAPIKeyResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/merchant/keys/{id}/rotate [post]
+func (h *MerchantHandler) RotateAPIKey(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantHandler.RotateAPIKey")
+	defer span.End()
+
+	keyID := c.Param("id")
+	if _, err := uuid.Parse(keyID); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid key id"))
+		return
+	}
+
+	resp, err := h.apiKeys.Rotate(ctx, keyID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to rotate api key"))
+		return
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", resp.Prefix))
+	c.JSON(http.StatusCreated, resp)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Отзыв API ключа
+// @Description  Немедленно отзывает API ключ
+// @Security     BearerAuth
+// @Tags         merchant
+// @Produce      json
+// @Param        id     path      string  true  "ID ключа"
+// @Success      200    {string}  string  "ключ отозван"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/merchant/keys/{id} [delete]
+func (h *MerchantHandler) RevokeAPIKey(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "MerchantHandler.RevokeAPIKey")
+	defer span.End()
+
+	keyID := c.Param("id")
+	if _, err := uuid.Parse(keyID); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid key id"))
+		return
+	}
+
+	err := h.apiKeys.Revoke(ctx, keyID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to revoke api key"))
+		return
+	}
+
+	c.JSON(http.StatusOK, "api key revoked")
+}
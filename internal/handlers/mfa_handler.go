@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+)
+
+// EnrollMFA godoc
+// @Summary      Подключение 2FA
+// @Description  Выдает секрет TOTP, otpauth ссылку для QR кода и одноразовые коды восстановления. 2FA включается после подтверждения кодом
+// @Security     BearerAuth
+// @Tags         2fa
+// @Produce      json
+// @Success      200    {object}  dto.MFAEnrollResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/2fa/enroll [post]
+func (h *UserHandler) EnrollMFA(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.EnrollMFA")
+	defer span.End()
+
+	resp, err := h.UserService.EnrollMFA(ctx)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to enroll 2fa"))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmMFA godoc
+// @Summary      Подтверждение 2FA
+// @Description  Включает 2FA, если код из приложения-аутентификатора верный
+// @Security     BearerAuth
+// @Tags         2fa
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.MFAConfirmRequest  true  "Код из приложения"
+// @Success      200    {string}  string  "2fa включена"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/2fa/confirm [post]
+func (h *UserHandler) ConfirmMFA(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ConfirmMFA")
+	defer span.End()
+
+	var req dto.MFAConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	err := h.UserService.ConfirmMFA(ctx, req.Code, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrInvalidMFACode):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrMFAAlreadyEnabled), errors.Is(err, model.ErrMFANotEnrolled):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to confirm 2fa"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "2fa enabled")
+}
+
+// DisableMFA godoc
+// @Summary      Отключение 2FA
+// @Description  Отключает 2FA. Требует пароль и код из приложения или код восстановления. Аккаунт без пароля (вход через OIDC провайдер) вместо пароля подтверждается входом через провайдер не более 5 минут назад
+// @Security     BearerAuth
+// @Tags         2fa
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.MFADisableRequest  true  "Пароль и код"
+// @Success      200    {string}  string  "2fa отключена"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "неверный пароль или код, либо нужен повторный вход через провайдер"
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      429    {object}  dto.ErrorResponse  "слишком много неудачных попыток, см. заголовок Retry-After"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/2fa/disable [post]
+func (h *UserHandler) DisableMFA(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.DisableMFA")
+	defer span.End()
+
+	var req dto.MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	err := h.UserService.DisableMFA(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case respondLockout(c, err):
+		case errors.Is(err, dto.ErrEmptyPassword):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrInvalidCredentials), errors.Is(err, model.ErrInvalidMFACode),
+			errors.Is(err, model.ErrReauthRequired):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrMFANotEnabled):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to disable 2fa"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "2fa disabled")
+}
+
+// VerifyMFA godoc
+// @Summary      Второй шаг входа
+// @Description  Обменивает mfa_token из /api/v1/auth и код из приложения (или код восстановления) на access/refresh токены
+// @Tags         2fa
+// @Accept       json
+// @Produce      json
+// @Param        X-Auth-Mode  header  string  false  "cookie - refresh токен возвращается в HttpOnly cookie"
+// @Param        input  body      dto.MFAVerifyRequest  true  "Токен второго шага и код"
+// @Success      200    {object}  dto.AuthResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "пользователь заблокирован"
+// @Failure      429    {object}  dto.ErrorResponse  "слишком много неудачных попыток, см. заголовок Retry-After"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/auth/2fa [post]
+func (h *UserHandler) VerifyMFA(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.VerifyMFA")
+	defer span.End()
+
+	var req dto.MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.MFAToken == "" || req.Code == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	resp, err := h.UserService.VerifyMFA(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case respondLockout(c, err):
+		case errors.Is(err, model.ErrInvalidMFAToken), errors.Is(err, model.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrUserBanned):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+		}
+		return
+	}
+
+	if cookieMode(c) {
+		if err := h.setSessionCookies(c, resp.RefreshToken); err != nil {
+			span.RecordError(err)
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			return
+		}
+		resp.RefreshToken = ""
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
@@ -34,11 +35,13 @@ func NewOrderHandler(hostname string,
 // @Tags         order
 // @Accept       plain
 // @Param        input  body      string  true  "Номер заказа"
+// @Param        merchant_id  query  string  false  "ID магазина, из которого пришел заказ"
 // @Produce      json
 // @Success      200    {object}  dto.AddOrderResponse
 // @Success 	 202    {object}  dto.ErrorResponse
 // @Failure      400    {object}  dto.ErrorResponse
 // @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse  "магазин не найден"
 // @Failure      409    {object}  dto.ErrorResponse
 // @Failure 	 422    {object}  dto.ErrorResponse
 // @Failure      500    {object}  dto.ErrorResponse
@@ -47,8 +50,14 @@ func (h *OrderHandler) LoadOrder(c *gin.Context) {
 	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.LoadOrder")
 	defer span.End()
 
-	bodyBytes, err := io.ReadAll(c.Request.Body)
+	bodyBytes, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, dto.MaxBatchBodySize))
 	if err != nil {
+		span.RecordError(err)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, dto.NewErrorResponse("request body is too large"))
+			return
+		}
 		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("failed to read request body"))
 		return
 	}
@@ -59,14 +68,20 @@ func (h *OrderHandler) LoadOrder(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.serv.Load(ctx, orderNumber)
+	resp, err := h.serv.Load(ctx, orderNumber, c.Query("merchant_id"))
 	if err != nil {
 		var status int
 		var message string
 		switch {
+		case errors.Is(err, dto.ErrInvalidMerchantID):
+			status = http.StatusBadRequest
+			message = err.Error()
+		case errors.Is(err, model.ErrMerchantNotFound):
+			status = http.StatusNotFound
+			message = err.Error()
 		case errors.Is(err, model.ErrBadOrderNumber):
 			status = http.StatusUnprocessableEntity
-			message = "wrong order number format"
+			message = err.Error()
 		case errors.Is(err, model.ErrOrderAlreadyExists):
 			status = http.StatusOK
 			message = "order already loaded"
@@ -85,15 +100,83 @@ func (h *OrderHandler) LoadOrder(c *gin.Context) {
 	c.JSON(http.StatusAccepted, resp)
 }
 
+// LoadOrdersBatch godoc
+// @Summary      Пакетная загрузка заказов
+// @Description  Загружает до 1000 заказов за запрос: JSON массив номеров или CSV с номером в первой колонке. Для каждого номера возвращается результат: accepted, duplicate, conflict или invalid
+// @Security BearerAuth
+// @Tags         order
+// @Accept       json
+// @Accept       text/csv
+// @Param        input  body      []string  true  "Номера заказов"
+// @Param        merchant_id  query  string  false  "ID магазина, из которого пришли заказы"
+// @Produce      json
+// @Success      200    {object}  dto.BatchOrdersResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse  "магазин не найден"
+// @Failure      413    {object}  dto.ErrorResponse  "тело запроса больше 256 КБ"
+// @Failure      415    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/orders/batch [post]
+func (h *OrderHandler) LoadOrdersBatch(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.LoadOrdersBatch")
+	defer span.End()
+
+	contentType := c.ContentType()
+	switch contentType {
+	case "application/json", "text/csv", "text/plain":
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, dto.NewErrorResponse("content type must be application/json or text/csv"))
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("failed to read request body"))
+		return
+	}
+
+	numbers, err := dto.ParseBatchOrders(contentType, bodyBytes)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		return
+	}
+
+	resp, err := h.serv.LoadBatch(ctx, ctx.Value(contextkeys.UserKeyID).(string), numbers, c.Query("merchant_id"))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, dto.ErrInvalidMerchantID):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrMerchantNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 // GetAllOrders godoc
 // @Summary      Возвращает информацию по всем товарам пользователя
-// @Description  Возвращает информацию по всем товарам пользователя
+// @Description  Возвращает страницу заказов пользователя. Курсор next_cursor передается в cursor для следующей страницы вместе с теми же фильтрами и сортировкой
 // @Security BearerAuth
 // @Tags         order
 // @Accept       json
 // @Produce      json
+// @Param        status  query     []string  false  "Статусы заказа: NEW, REGISTERED, PROCESSING, INVALID, PROCESSED, CANCELLED, RETURNED"  collectionFormat(csv)
+// @Param        from    query     string    false  "Загружен не раньше (RFC 3339)"
+// @Param        to      query     string    false  "Загружен раньше (RFC 3339)"
+// @Param        sort    query     string    false  "Сортировка: -uploaded_at (по умолчанию), uploaded_at, -accrual, accrual"
+// @Param        limit   query     int       false  "Размер страницы, по умолчанию 50, не больше 100"
+// @Param        cursor  query     string    false  "Курсор следующей страницы"
+// @Param        merchant_id  query  string  false  "Только заказы этого магазина"
 // @Success      200    {object}  dto.GetAllOrdersResponse
 // @Success 	 204    {object}  dto.ErrorResponse
+// @Failure      400    {object}  dto.ErrorResponse
 // @Failure      401    {object}  dto.ErrorResponse
 // @Failure      500    {object}  dto.ErrorResponse
 // @Router       /api/v1/user/orders [get]
@@ -101,10 +184,24 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.GetAllOrders")
 	defer span.End()
 
-	orders, err := h.serv.GetAll(ctx, ctx.Value(contextkeys.UserKeyID).(string))
+	var req dto.ListOrdersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	orders, err := h.serv.GetAll(ctx, ctx.Value(contextkeys.UserKeyID).(string), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
 		span.RecordError(err)
+		switch {
+		case errors.Is(err, dto.ErrInvalidOrderStatus), errors.Is(err, dto.ErrInvalidTimeRange),
+			errors.Is(err, dto.ErrInvalidSort), errors.Is(err, dto.ErrInvalidLimit),
+			errors.Is(err, dto.ErrInvalidCursor), errors.Is(err, dto.ErrInvalidMerchantID):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+		}
 		return
 	}
 
@@ -116,3 +213,130 @@ func (h *OrderHandler) GetAllOrders(c *gin.Context) {
 
 	c.JSON(http.StatusOK, orders)
 }
+
+// GetOrder godoc
+// @Summary      Заказ пользователя с историей
+// @Description  Возвращает заказ и историю смены статуса с временем и изменением начисления на каждом шаге, а также чек покупки, если его передал магазин
+// @Security BearerAuth
+// @Tags         order
+// @Produce      json
+// @Param        number  path      string  true  "Номер заказа"
+// @Success      200    {object}  dto.OrderDetailResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/orders/{number} [get]
+func (h *OrderHandler) GetOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.GetOrder")
+	defer span.End()
+
+	resp, err := h.serv.GetOrder(ctx, ctx.Value(contextkeys.UserKeyID).(string), c.Param("number"))
+	if err != nil {
+		span.RecordError(err)
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetOrderAdmin godoc
+// @Summary      Заказ с историей для поддержки
+// @Description  Возвращает любой заказ и историю смены его статуса. Доступно поддержке и администраторам
+// @Security BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        number  path      string  true  "Номер заказа"
+// @Success      200    {object}  dto.OrderDetailResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/orders/{number} [get]
+func (h *OrderHandler) GetOrderAdmin(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.GetOrderAdmin")
+	defer span.End()
+
+	resp, err := h.serv.GetOrderForSupport(ctx, c.Param("number"))
+	if err != nil {
+		span.RecordError(err)
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CancelOrder godoc
+// @Summary      Отмена заказа
+// @Description  Отменяет заказ пользователя, пока начисление по нему не рассчитано. Рассчитанный заказ возвращает мерчант или администратор
+// @Security BearerAuth
+// @Tags         order
+// @Produce      json
+// @Param        number  path      string  true  "Номер заказа"
+// @Success      200    {object}  dto.CancelOrderResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/orders/{number}/cancel [post]
+func (h *OrderHandler) CancelOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.CancelOrder")
+	defer span.End()
+
+	resp, err := h.serv.CancelOrder(ctx, ctx.Value(contextkeys.UserKeyID).(string), c.Param("number"))
+	if err != nil {
+		span.RecordError(err)
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReturnOrderAdmin godoc
+// @Summary      Отмена или возврат заказа администратором
+// @Description  Отменяет заказ до расчета начисления. Рассчитанный заказ переводится в RETURNED, начисленные баллы списываются обратно, при нехватке баланса у покупателя образуется долг. Доступно только администраторам
+// @Security BearerAuth
+// @Tags         admin
+// @Produce      json
+// @Param        number  path      string  true  "Номер заказа"
+// @Success      200    {object}  dto.CancelOrderResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/admin/orders/{number}/return [post]
+func (h *OrderHandler) ReturnOrderAdmin(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "OrderHandler.ReturnOrderAdmin")
+	defer span.End()
+
+	userID, _ := ctx.Value(contextkeys.UserKeyID).(string)
+	actorID, err := uuid.Parse(userID)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusUnauthorized, dto.NewErrorResponse("unauthorized"))
+		return
+	}
+
+	resp, err := h.serv.ReturnOrder(ctx, c.Param("number"), actorID)
+	if err != nil {
+		span.RecordError(err)
+		respondOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrOrderNotFound):
+		c.JSON(http.StatusNotFound, dto.NewErrorResponse("order not found"))
+	case errors.Is(err, model.ErrOrderNotCancellable):
+		c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+	default:
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type PartnerHandler struct {
+	serv interfaces.PartnerServiceInterface
+}
+
+func NewPartnerHandler(partnerService interfaces.PartnerServiceInterface) *PartnerHandler {
+	return &PartnerHandler{
+		serv: partnerService,
+	}
+}
+
+// LoadOrder godoc
+// @Summary      Загрузка заказа кассой партнера
+// @Description  Загружает заказ покупателя по его логину. Требует API ключ с правом orders:write
+// @Security     ApiKeyAuth
+// @Tags         partner
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.PartnerOrderRequest  true  "Логин покупателя и номер заказа"
+// @Success      202    {object}  dto.AddOrderResponse
+// @Success      200    {object}  dto.ErrorResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      422    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/partner/orders [post]
+func (h *PartnerHandler) LoadOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "PartnerHandler.LoadOrder")
+	defer span.End()
+
+	var req dto.PartnerOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Login == "" || strings.TrimSpace(req.Order) == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+	req.Order = strings.TrimSpace(req.Order)
+
+	resp, err := h.serv.LoadOrder(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("customer not found"))
+		case errors.Is(err, model.ErrBadOrderNumber):
+			c.JSON(http.StatusUnprocessableEntity, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrOrderAlreadyExists):
+			c.JSON(http.StatusOK, dto.NewErrorResponse("order already loaded"))
+		case errors.Is(err, model.ErrOrderLoadedByAnotherPerson):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse("this order loaded by another person"))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+		}
+		return
+	}
+
+	span.SetAttributes(attribute.String("order_number", req.Order))
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// Redeem godoc
+// @Summary      Списание баллов кассой партнера
+// @Description  Списывает баллы покупателя в счет заказа. Требует API ключ с правом redemptions:write и одноразовый код, который покупатель получил в приложении
+// @Security     ApiKeyAuth
+// @Tags         partner
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.PartnerRedemptionRequest  true  "Логин покупателя, номер заказа, сумма и код покупателя"
+// @Param        Idempotency-Key  header  string  false  "Ключ для безопасного повтора запроса"
+// @Success      200    {string}  string  "успешное списание"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      402    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "нет прав у ключа, неверный код или email покупателя не подтвержден"
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "Idempotency-Key уже использован с другим запросом"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/partner/redemptions [post]
+func (h *PartnerHandler) Redeem(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "PartnerHandler.Redeem")
+	defer span.End()
+
+	var req dto.PartnerRedemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Login == "" || req.Order == "" || req.Sum <= 0 ||
+		req.Code == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	err := h.serv.Redeem(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("customer not found"))
+		case errors.Is(err, model.ErrInsufficientFunds):
+			c.JSON(http.StatusPaymentRequired, dto.NewErrorResponse("not enough funds"))
+		case errors.Is(err, model.ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse("customer email is not verified"))
+		case errors.Is(err, model.ErrInvalidRedemptionCode):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("redemption failed"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "redemption completed")
+}
+
+// ReturnOrder godoc
+// @Summary      Отмена или возврат заказа кассой партнера
+// @Description  Отменяет заказ до расчета начисления. Рассчитанный заказ переводится в RETURNED, начисленные баллы списываются обратно, при нехватке баланса у покупателя образуется долг. Требует API ключ с правом orders:write
+// @Security     ApiKeyAuth
+// @Tags         partner
+// @Produce      json
+// @Param        number  path      string  true  "Номер заказа"
+// @Success      200    {object}  dto.CancelOrderResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/partner/orders/{number}/return [post]
+func (h *PartnerHandler) ReturnOrder(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "PartnerHandler.ReturnOrder")
+	defer span.End()
+
+	resp, err := h.serv.ReturnOrder(ctx, c.Param("number"))
+	if err != nil {
+		span.RecordError(err)
+		respondOrderError(c, err)
+		return
+	}
+
+	span.SetAttributes(attribute.String("order_number", resp.Number))
+	c.JSON(http.StatusOK, resp)
+}
+
+// SaveReceipt godoc
+// @Summary      Чек заказа
+// @Description  Сохраняет чек покупки: сумму, валюту и позиции. Чек принимается только для заказов магазина владельца ключа, пока начисление не рассчитано; повторная отправка заменяет прежний чек. Сумма чека должна совпадать с суммой позиций. Требует API ключ с правом orders:write
+// @Security     ApiKeyAuth
+// @Tags         partner
+// @Accept       json
+// @Produce      json
+// @Param        number  path      string              true  "Номер заказа"
+// @Param        input   body      dto.ReceiptRequest  true  "Чек"
+// @Success      200    {object}  dto.ReceiptResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse  "у владельца ключа нет магазина"
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "начисление уже рассчитано"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/partner/orders/{number}/receipt [put]
+func (h *PartnerHandler) SaveReceipt(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "PartnerHandler.SaveReceipt")
+	defer span.End()
+
+	var req dto.ReceiptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		return
+	}
+
+	resp, err := h.serv.SaveReceipt(ctx, c.Param("number"), req)
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, dto.ErrEmptyReceipt), errors.Is(err, dto.ErrReceiptTooLarge),
+			errors.Is(err, dto.ErrInvalidCurrency), errors.Is(err, dto.ErrInvalidReceiptItem),
+			errors.Is(err, dto.ErrReceiptTotalMismatch):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrMerchantNotFound):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse("api key owner has no merchant"))
+		case errors.Is(err, model.ErrReceiptLocked):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+		default:
+			respondOrderError(c, err)
+		}
+		return
+	}
+
+	span.SetAttributes(attribute.String("order_number", resp.OrderNumber))
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+)
+
+// GetMe godoc
+// @Summary      Текущий пользователь
+// @Description  Возвращает данные аутентифицированного пользователя и его профиль. Незаполненные поля профиля равны null
+// @Security     BearerAuth
+// @Tags         profile
+// @Produce      json
+// @Success      200    {object}  dto.ProfileResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.GetMe")
+	defer span.End()
+
+	resp, err := h.UserService.GetProfile(ctx)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to get profile"))
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateMe godoc
+// @Summary      Обновление профиля
+// @Description  Частично обновляет профиль: меняются только переданные поля, пустая строка очищает поле. Телефон в международном формате, дата рождения YYYY-MM-DD
+// @Security     BearerAuth
+// @Tags         profile
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.UpdateProfileRequest  true  "Изменяемые поля профиля"
+// @Success      200    {object}  dto.ProfileResponse
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      409    {object}  dto.ErrorResponse  "email уже используется"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/me [patch]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.UpdateMe")
+	defer span.End()
+
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	resp, err := h.UserService.UpdateProfile(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, dto.ErrInvalidDisplayName), errors.Is(err, dto.ErrInvalidEmail),
+			errors.Is(err, dto.ErrInvalidPhone), errors.Is(err, dto.ErrInvalidBirthday):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrEmailTaken):
+			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to update profile"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -2,35 +2,96 @@ package handlers
 
 import (
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 )
 
 type UserHandler struct {
 	hostname    string
+	cookies     authcookie.Config
 	UserService interfaces.UserServiceInterface
 }
 
-func NewUserHandler(hostname string,
+func NewUserHandler(hostname string, cookies authcookie.Config,
 	userService interfaces.UserServiceInterface) *UserHandler {
 	return &UserHandler{
 		hostname:    hostname,
+		cookies:     cookies,
 		UserService: userService,
 	}
 }
 
+// refreshTokenFromRequest достает refresh токен из заголовка X-Refresh-Token,
+// json тела запроса или, в браузерном режиме, из cookie. fromCookie - токен
+// взят из cookie
+func refreshTokenFromRequest(c *gin.Context) (token string, fromCookie bool) {
+	if token := c.GetHeader("X-Refresh-Token"); token != "" {
+		return token, false
+	}
+
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err == nil && req.RefreshToken != "" {
+		return req.RefreshToken, false
+	}
+
+	if token, err := c.Cookie(authcookie.RefreshCookie); err == nil && token != "" {
+		return token, true
+	}
+	return "", false
+}
+
+// cookieMode клиент просит режим cookie: refresh токен не попадает в тело ответа
+func cookieMode(c *gin.Context) bool {
+	return c.GetHeader(authcookie.ModeHeader) == authcookie.ModeCookie
+}
+
+// setSessionCookies кладет refresh токен в HttpOnly cookie и выдает новый
+// csrf токен в cookie и в заголовке X-CSRF-Token
+func (h *UserHandler) setSessionCookies(c *gin.Context, refreshToken string) error {
+	csrf, err := authcookie.NewCSRFToken()
+	if err != nil {
+		return err
+	}
+	if legacy := h.cookies.ClearLegacy(); legacy != nil {
+		http.SetCookie(c.Writer, legacy)
+	}
+	http.SetCookie(c.Writer, h.cookies.Refresh(refreshToken))
+	http.SetCookie(c.Writer, h.cookies.CSRF(csrf))
+	c.Header(authcookie.CSRFHeader, csrf)
+	return nil
+}
+
+func (h *UserHandler) clearSessionCookies(c *gin.Context) {
+	for _, cookie := range h.cookies.Clear() {
+		http.SetCookie(c.Writer, cookie)
+	}
+}
+
+// clientInfo собирает данные об устройстве клиента для сессии
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
 // Register godoc
 // @Summary      Регистрация пользователя
-// @Description  Регистрирует нового пользователя и возвращает access/refresh токены
+// @Description  Регистрирует нового пользователя и возвращает access/refresh токены. Если логин - email, на него отправляется письмо для подтверждения адреса
 // @Tags         user
 // @Accept       json
 // @Produce      json
+// @Param        X-Auth-Mode  header  string  false  "cookie - refresh токен возвращается в HttpOnly cookie"
 // @Param        input  body      dto.RegisterRequest  true  "Данные для регистрации"
 // @Success      200    {object}  dto.AuthResponse
 // @Failure      400    {object}  map[string]string
@@ -47,32 +108,49 @@ func (h *UserHandler) Register(c *gin.Context) {
 		span.RecordError(err)
 		return
 	}
-	resp, err := h.UserService.Register(ctx, req)
+	resp, err := h.UserService.Register(ctx, req, clientInfo(c))
 	if err != nil {
 		if errors.Is(err, model.ErrAlreadyExits) {
 			c.JSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
 			span.RecordError(err)
 			return
 		}
+		if errors.Is(err, model.ErrWeakPassword) || errors.Is(err, dto.ErrEmptyLogin) ||
+			errors.Is(err, dto.ErrInvalidEmail) {
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+			span.RecordError(err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse(err.Error()))
 		span.RecordError(err)
 		return
 	}
 	span.SetAttributes(attribute.String("user.login", req.Login))
-	c.SetCookie("refresh_token", resp.RefreshToken, 2592000, "/", h.hostname, false, true) //только при разработке
+	if cookieMode(c) {
+		if err := h.setSessionCookies(c, resp.RefreshToken); err != nil {
+			span.RecordError(err)
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			return
+		}
+		resp.RefreshToken = ""
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
 // Auth godoc
 // @Summary      Аутентификация пользователя
-// @Description  Аутентифицирует пользователя и возвращает access/refresh токены
+// @Description  Аутентифицирует пользователя и возвращает access/refresh токены. Если у пользователя включена 2FA, вместо токенов возвращается mfa_required и mfa_token для /api/v1/auth/2fa
 // @Tags         user
 // @Accept       json
 // @Produce      json
+// @Param        X-Auth-Mode  header  string  false  "cookie - refresh токен возвращается в HttpOnly cookie"
 // @Param        input  body      dto.AuthRequest  true  "Данные для входа"
 // @Success      200    {object}  dto.AuthResponse
 // @Failure      400    {object}  map[string]string
 // @Failure      401    {object}  map[string]string
+// @Failure      403    {object}  dto.ErrorResponse  "пользователь заблокирован"
+// @Failure      429    {object}  dto.ErrorResponse  "слишком много неудачных попыток, см. заголовок Retry-After"
+// @Failure      500    {object}  dto.ErrorResponse
 // @Router       /api/v1/auth [post]
 func (h *UserHandler) Auth(c *gin.Context) {
 	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.Auth")
@@ -84,48 +162,371 @@ func (h *UserHandler) Auth(c *gin.Context) {
 		span.RecordError(err)
 		return
 	}
-	resp, err := h.UserService.Auth(ctx, req)
+	resp, err := h.UserService.Auth(ctx, req, clientInfo(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(err.Error()))
 		span.RecordError(err)
+		switch {
+		case respondLockout(c, err):
+		case errors.Is(err, model.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrUserBanned):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+		}
 		return
 	}
 	span.SetAttributes(attribute.String("user.login", req.Login))
-	c.SetCookie("refresh_token", resp.RefreshToken, 2592000, "/", h.hostname, false, true) // только при разработке
+	if !resp.MFARequired && cookieMode(c) {
+		if err := h.setSessionCookies(c, resp.RefreshToken); err != nil {
+			span.RecordError(err)
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			return
+		}
+		resp.RefreshToken = ""
+	}
 	c.JSON(http.StatusOK, resp)
 }
 
+// respondLockout отвечает 429 с Retry-After, если вход временно запрещен
+func respondLockout(c *gin.Context, err error) bool {
+	var lockoutErr *model.LockoutError
+	if !errors.As(err, &lockoutErr) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, dto.NewErrorResponse(model.ErrTooManyAttempts.Error()))
+	return true
+}
+
 // Refresh godoc
-// @Summary      Обновление access токена
-// @Description  Обновляет access токен по refresh токену
+// @Summary      Обновление токенов
+// @Description  Выдает новую пару access/refresh токенов. Переданный refresh токен становится недействительным.
+// @Description  Токен передается в заголовке X-Refresh-Token, в теле запроса или в cookie refresh_token.
+// @Description  В режиме cookie новый refresh токен возвращается только в cookie, запрос требует X-CSRF-Token.
 // @Tags         user
 // @Accept       json
 // @Produce      json
+// @Param        X-Refresh-Token  header    string              false  "Refresh токен"
+// @Param        X-Auth-Mode      header    string              false  "cookie - браузерный режим"
+// @Param        X-CSRF-Token     header    string              false  "CSRF токен, обязателен при refresh токене в cookie"
+// @Param        input            body      dto.RefreshRequest  false  "Refresh токен"
 // @Success      200    {object}  dto.RefreshResponse
 // @Failure      400    {object}  dto.ErrorResponse
 // @Failure      401    {object}  dto.ErrorResponse
-// @Router       /api/v1/refresh [get]
+// @Failure      403    {object}  dto.ErrorResponse  "пользователь заблокирован или неверный CSRF токен"
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/refresh [post]
 func (h *UserHandler) Refresh(c *gin.Context) {
 	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.Refresh")
 	defer span.End()
 
-	var req struct {
-		RefreshToken string `json:"refresh_token"`
+	refreshToken, fromCookie := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("refresh_token is required"))
+		return
+	}
+
+	resp, err := h.UserService.GetNewAccessToken(ctx, refreshToken, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrInvalidRefreshToken),
+			errors.Is(err, model.ErrRefreshTokenExpired),
+			errors.Is(err, model.ErrRefreshTokenReused):
+			// недействительную cookie браузеру хранить незачем
+			if fromCookie {
+				h.clearSessionCookies(c)
+			}
+			c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrUserBanned):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to refresh tokens"))
+		}
+		return
+	}
+
+	if fromCookie || cookieMode(c) {
+		if err := h.setSessionCookies(c, resp.RefreshToken); err != nil {
+			span.RecordError(err)
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to refresh tokens"))
+			return
+		}
+		resp.RefreshToken = ""
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Logout godoc
+// @Summary      Выход из системы
+// @Description  Завершает сессию, к которой привязан переданный refresh токен. В режиме cookie
+// @Description  используется DELETE /api/v1/refresh: токен берется из cookie, которые затем удаляются
+// @Security     BearerAuth
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        X-Refresh-Token  header    string              false  "Refresh токен текущей сессии"
+// @Param        X-CSRF-Token     header    string              false  "CSRF токен, обязателен при refresh токене в cookie"
+// @Param        input            body      dto.RefreshRequest  false  "Refresh токен текущей сессии"
+// @Success      200    {string}  string  "сессия завершена"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/logout [post]
+// @Router       /api/v1/refresh [delete]
+func (h *UserHandler) Logout(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.Logout")
+	defer span.End()
+
+	refreshToken, fromCookie := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("refresh_token is required"))
+		return
+	}
+
+	err := h.UserService.Logout(ctx, refreshToken)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("session not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("logout failed"))
+		return
+	}
+
+	if fromCookie || cookieMode(c) {
+		h.clearSessionCookies(c)
 	}
-	cookie, err := c.Cookie("refresh_token")
+	c.JSON(http.StatusOK, "logged out")
+}
+
+// GetSessions godoc
+// @Summary      Активные сессии пользователя
+// @Description  Возвращает список активных сессий: устройство, IP, время входа и истечения
+// @Security     BearerAuth
+// @Tags         session
+// @Produce      json
+// @Success      200  {object}  dto.GetSessionsResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api/v1/user/sessions [get]
+func (h *UserHandler) GetSessions(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.GetSessions")
+	defer span.End()
+
+	res, err := h.UserService.GetSessions(ctx)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("no refresh_token cookie"))
 		span.RecordError(err)
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to get sessions"))
 		return
 	}
-	req.RefreshToken = cookie
-	resp, err := h.UserService.GetNewAccessToken(ctx, req.RefreshToken)
+
+	c.JSON(http.StatusOK, res)
+}
+
+// RevokeSession godoc
+// @Summary      Завершение сессии
+// @Description  Завершает указанную сессию пользователя
+// @Security     BearerAuth
+// @Tags         session
+// @Produce      json
+// @Param        id   path      string  true  "ID сессии"
+// @Success      200  {string}  string  "сессия завершена"
+// @Failure      400  {object}  dto.ErrorResponse
+// @Failure      401  {object}  dto.ErrorResponse
+// @Failure      404  {object}  dto.ErrorResponse
+// @Failure      500  {object}  dto.ErrorResponse
+// @Router       /api/v1/user/sessions/{id} [delete]
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.RevokeSession")
+	defer span.End()
+
+	sessionID := c.Param("id")
+	if _, err := uuid.Parse(sessionID); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid session id"))
+		return
+	}
+
+	err := h.UserService.RevokeSession(ctx, sessionID)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, dto.NewErrorResponse(err.Error()))
 		span.RecordError(err)
+		if errors.Is(err, model.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("session not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to revoke session"))
 		return
 	}
 
-	span.SetAttributes(attribute.String("refresh_token", req.RefreshToken))
-	c.JSON(http.StatusOK, resp)
+	span.SetAttributes(attribute.String("session.id", sessionID))
+	c.JSON(http.StatusOK, "session revoked")
+}
+
+// RevokeOtherSessions godoc
+// @Summary      Завершение всех остальных сессий
+// @Description  Завершает все сессии пользователя, кроме текущей
+// @Security     BearerAuth
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        X-Refresh-Token  header    string              false  "Refresh токен текущей сессии"
+// @Param        input            body      dto.RefreshRequest  false  "Refresh токен текущей сессии"
+// @Success      200    {string}  string  "сессии завершены"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/sessions [delete]
+func (h *UserHandler) RevokeOtherSessions(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.RevokeOtherSessions")
+	defer span.End()
+
+	refreshToken, _ := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("refresh_token is required"))
+		return
+	}
+
+	err := h.UserService.RevokeOtherSessions(ctx, refreshToken)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("session not found"))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to revoke sessions"))
+		return
+	}
+
+	c.JSON(http.StatusOK, "other sessions revoked")
+}
+
+// ChangePassword godoc
+// @Summary      Смена пароля
+// @Description  Меняет пароль пользователя и завершает все остальные его сессии. Если передан refresh токен текущей сессии, она остается активной
+// @Security     BearerAuth
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        X-Refresh-Token  header    string                     false  "Refresh токен текущей сессии"
+// @Param        input            body      dto.ChangePasswordRequest  true   "Старый и новый пароль"
+// @Success      200    {string}  string  "пароль изменен"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      401    {object}  dto.ErrorResponse
+// @Failure      403    {object}  dto.ErrorResponse
+// @Failure      404    {object}  dto.ErrorResponse
+// @Failure      429    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/user/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ChangePassword")
+	defer span.End()
+
+	var req dto.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.GetHeader("X-Refresh-Token")
+	}
+
+	err := h.UserService.ChangePassword(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		if respondLockout(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, model.ErrWeakPassword), errors.Is(err, dto.ErrEmptyPassword):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		case errors.Is(err, model.ErrInvalidCredentials):
+			c.JSON(http.StatusForbidden, dto.NewErrorResponse("invalid old password"))
+		case errors.Is(err, model.ErrSessionNotFound):
+			c.JSON(http.StatusNotFound, dto.NewErrorResponse("session not found"))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to change password"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "password changed")
+}
+
+// ForgotPassword godoc
+// @Summary      Запрос сброса пароля
+// @Description  Отправляет пользователю одноразовый токен сброса пароля. Ответ не зависит от того, существует ли логин
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.ForgotPasswordRequest  true  "Логин пользователя"
+// @Success      202    {string}  string  "запрос принят"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/password/forgot [post]
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ForgotPassword")
+	defer span.End()
+
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	err := h.UserService.ForgotPassword(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, dto.ErrEmptyLogin) {
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to request password reset"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, "if the account exists, reset instructions have been sent")
+}
+
+// ResetPassword godoc
+// @Summary      Сброс пароля
+// @Description  Устанавливает новый пароль по одноразовому токену сброса и завершает все сессии пользователя
+// @Tags         user
+// @Accept       json
+// @Produce      json
+// @Param        input  body      dto.ResetPasswordRequest  true  "Токен сброса и новый пароль"
+// @Success      200    {string}  string  "пароль изменен"
+// @Failure      400    {object}  dto.ErrorResponse
+// @Failure      500    {object}  dto.ErrorResponse
+// @Router       /api/v1/password/reset [post]
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	ctx, span := otel.Tracer("handler").Start(c.Request.Context(), "UserHandler.ResetPassword")
+	defer span.End()
+
+	var req dto.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.NewErrorResponse("invalid request"))
+		span.RecordError(err)
+		return
+	}
+
+	err := h.UserService.ResetPassword(ctx, req, clientInfo(c))
+	if err != nil {
+		span.RecordError(err)
+		switch {
+		case errors.Is(err, model.ErrWeakPassword), errors.Is(err, model.ErrInvalidResetToken):
+			c.JSON(http.StatusBadRequest, dto.NewErrorResponse(err.Error()))
+		default:
+			c.JSON(http.StatusInternalServerError, dto.NewErrorResponse("failed to reset password"))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, "password changed")
 }
@@ -19,8 +19,50 @@ var (
 		},
 		[]string{"method", "path", "status"},
 	)
+
+	AuthFailedAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_failed_attempts_total",
+			Help: "Количество неудачных попыток входа",
+		},
+		[]string{"scope"},
+	)
+
+	AuthLockoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_lockouts_total",
+			Help: "Количество блокировок входа после серии неудачных попыток",
+		},
+		[]string{"scope"},
+	)
+
+	AuthThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_throttled_requests_total",
+			Help: "Количество попыток входа, отклоненных из-за задержки или блокировки",
+		},
+		[]string{"scope"},
+	)
+
+	APIKeyRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "api_key_requests_total",
+			Help: "Количество запросов партнеров по API ключам",
+		},
+		[]string{"key", "path", "status"},
+	)
+
+	OrderTransitionsRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "order_transitions_rejected_total",
+			Help: "Количество отклоненных переходов статуса заказа",
+		},
+		[]string{"from", "to"},
+	)
 )
 
 func RegisterMetrics() {
-	prometheus.MustRegister(HttpRequestsTotal, HttpRequestDuration)
+	prometheus.MustRegister(HttpRequestsTotal, HttpRequestDuration,
+		AuthFailedAttemptsTotal, AuthLockoutsTotal, AuthThrottledTotal, APIKeyRequestsTotal,
+		OrderTransitionsRejectedTotal)
 }
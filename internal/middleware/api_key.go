@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/metrics"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error)
+}
+
+// APIKeyMiddleware аутентифицирует кассы партнеров по заголовку X-Api-Key
+// и считает запросы по каждому ключу
+func APIKeyMiddleware(keys APIKeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, span := otel.Tracer("middleware").Start(c.Request.Context(), "APIKeyMiddleware")
+		defer span.End()
+
+		rawKey := c.GetHeader("X-Api-Key")
+		if rawKey == "" {
+			span.RecordError(errors.New("missing api key"))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse("missing api key"))
+			return
+		}
+
+		key, err := keys.Authenticate(ctx, rawKey)
+		if err != nil {
+			span.RecordError(err)
+			if errors.Is(err, model.ErrInvalidAPIKey) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse("invalid api key"))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			return
+		}
+		span.SetAttributes(attribute.String("api_key.prefix", key.Prefix))
+
+		ctx = context.WithValue(ctx, contextkeys.APIKey, key)
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(string(contextkeys.APIKey), key)
+		c.Next()
+
+		metrics.APIKeyRequestsTotal.WithLabelValues(key.Prefix, c.FullPath(),
+			strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
+
+// RequireScope пропускает только ключи с нужным правом.
+// Должен стоять после APIKeyMiddleware
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := c.Request.Context().Value(contextkeys.APIKey).(*model.APIKey)
+		if !ok || !key.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse("api key has no "+scope+" scope"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		key    *model.APIKey
+		status int
+	}{
+		{name: "no api key", status: http.StatusForbidden},
+		{name: "matching scope", key: &model.APIKey{Scopes: []string{model.ScopeOrdersWrite}}, status: http.StatusOK},
+		{
+			name:   "one of scopes",
+			key:    &model.APIKey{Scopes: []string{model.ScopeRedemptionsWrite, model.ScopeOrdersWrite}},
+			status: http.StatusOK,
+		},
+		{name: "other scope", key: &model.APIKey{Scopes: []string{model.ScopeRedemptionsWrite}}, status: http.StatusForbidden},
+		{name: "no scopes", key: &model.APIKey{}, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.key != nil {
+					ctx := context.WithValue(c.Request.Context(), contextkeys.APIKey, tt.key)
+					c.Request = c.Request.WithContext(ctx)
+				}
+				c.Next()
+			})
+			r.Use(RequireScope(model.ScopeOrdersWrite))
+			r.POST("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+			if w.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
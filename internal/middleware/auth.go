@@ -9,11 +9,17 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	tokenmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/tokenManager"
 	"go.opentelemetry.io/otel"
 )
 
-func AuthMiddleware(tm *tokenmanager.TokenManager) gin.HandlerFunc {
+// TokenDenylist отозванные access токены
+type TokenDenylist interface {
+	Revoked(jti, sid string) bool
+}
+
+func AuthMiddleware(tm *tokenmanager.TokenManager, denylist TokenDenylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, span := otel.Tracer("middleware").Start(c.Request.Context(), "AuthMiddleware")
 		defer span.End()
@@ -27,19 +33,36 @@ func AuthMiddleware(tm *tokenmanager.TokenManager) gin.HandlerFunc {
 
 		token := parts[1]
 
-		userID, err := tm.ParseToken(token)
+		claims, err := tm.ParseToken(token)
 		if err != nil {
 			span.RecordError(err)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse("invalid token"))
 			return
 		}
 
-		// добавляем в контекст id клиента
-		ctx = context.WithValue(ctx, contextkeys.UserKeyID, userID.String())
+		// токен или вся его сессия отозваны (выход, смена пароля, бан)
+		if denylist.Revoked(claims.ID, claims.SessionID) {
+			span.RecordError(model.ErrTokenRevoked)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, dto.NewErrorResponse("token revoked"))
+			return
+		}
+
+		// токены, выданные до появления ролей, считаем токенами клиента
+		role := model.Role(claims.Role)
+		if role == "" {
+			role = model.RoleCustomer
+		}
+
+		// добавляем в контекст id и роль клиента
+		ctx = context.WithValue(ctx, contextkeys.UserKeyID, claims.UserID.String())
+		ctx = context.WithValue(ctx, contextkeys.UserRoleKey, role)
+		ctx = context.WithValue(ctx, contextkeys.TokenIDKey, claims.ID)
+		ctx = context.WithValue(ctx, contextkeys.SessionIDKey, claims.SessionID)
 
 		// прокидываем наш контекст дальше
 		c.Request = c.Request.WithContext(ctx)
-		c.Set(string(contextkeys.UserKeyID), userID.String())
+		c.Set(string(contextkeys.UserKeyID), claims.UserID.String())
+		c.Set(string(contextkeys.UserRoleKey), role)
 		c.Next()
 	}
 }
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
+)
+
+var corsAllowHeaders = strings.Join([]string{
+	"Authorization", "Content-Type", "X-Refresh-Token", "X-Api-Key",
+	authcookie.CSRFHeader, authcookie.ModeHeader, IdempotencyKeyHeader,
+}, ", ")
+
+// CORS разрешает кросс-доменные запросы с перечисленных origin, включая
+// cookie. "*" разрешает любой origin, но без cookie: иначе любой сайт мог бы
+// делать запросы от имени пользователя. Пустой список - CORS выключен
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	origins := make([]string, 0, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	anyOrigin := slices.Contains(origins, "*")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" || len(origins) == 0 {
+			c.Next()
+			return
+		}
+
+		c.Writer.Header().Add("Vary", "Origin")
+		listed := slices.Contains(origins, origin)
+		if !anyOrigin && !listed {
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		if listed {
+			// с credentials нельзя отвечать "*", поэтому возвращаем сам origin
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		h.Set("Access-Control-Expose-Headers", authcookie.CSRFHeader+", Retry-After, "+IdempotentReplayedHeader)
+
+		// preflight
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", "600")
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
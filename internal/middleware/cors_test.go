@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		allowed     []string
+		origin      string
+		allowOrigin string
+		credentials string
+	}{
+		{
+			name:        "listed origin",
+			allowed:     []string{"https://app.example.com"},
+			origin:      "https://app.example.com",
+			allowOrigin: "https://app.example.com",
+			credentials: "true",
+		},
+		{
+			name:    "unknown origin",
+			allowed: []string{"https://app.example.com"},
+			origin:  "https://evil.example.com",
+		},
+		{
+			name:        "wildcard without credentials",
+			allowed:     []string{"*"},
+			origin:      "https://evil.example.com",
+			allowOrigin: "*",
+		},
+		{
+			name:        "listed origin next to wildcard",
+			allowed:     []string{"*", "https://app.example.com/"},
+			origin:      "https://app.example.com",
+			allowOrigin: "https://app.example.com",
+			credentials: "true",
+		},
+		{
+			name:    "cors disabled",
+			allowed: []string{""},
+			origin:  "https://app.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(CORS(tt.allowed))
+			r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Errorf("Allow-Credentials = %q, want %q", got, tt.credentials)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
+	"go.opentelemetry.io/otel"
+)
+
+// CSRF защищает запросы, аутентифицированные refresh cookie: значение
+// заголовка X-CSRF-Token должно совпадать с cookie csrf_token (double-submit).
+// Запросы без cookie и с токеном в X-Refresh-Token не проверяются:
+// токен из cookie в них не используется
+func CSRF() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if _, err := c.Cookie(authcookie.RefreshCookie); err != nil || c.GetHeader("X-Refresh-Token") != "" {
+			c.Next()
+			return
+		}
+
+		_, span := otel.Tracer("middleware").Start(c.Request.Context(), "CSRF")
+		defer span.End()
+
+		cookie, err := c.Cookie(authcookie.CSRFCookie)
+		header := c.GetHeader(authcookie.CSRFHeader)
+		if err != nil || cookie == "" ||
+			subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+			span.RecordError(errors.New("csrf token mismatch"))
+			c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse("invalid csrf token"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/authcookie"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CSRF())
+	r.Any("/refresh", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name    string
+		method  string
+		cookies map[string]string
+		header  string
+		refresh string
+		status  int
+	}{
+		{
+			name:   "bearer client without cookies",
+			method: http.MethodPost,
+			status: http.StatusOK,
+		},
+		{
+			name:    "safe method",
+			method:  http.MethodGet,
+			cookies: map[string]string{authcookie.RefreshCookie: "r"},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "matching token",
+			method:  http.MethodPost,
+			cookies: map[string]string{authcookie.RefreshCookie: "r", authcookie.CSRFCookie: "csrf"},
+			header:  "csrf",
+			status:  http.StatusOK,
+		},
+		{
+			name:    "missing header",
+			method:  http.MethodPost,
+			cookies: map[string]string{authcookie.RefreshCookie: "r", authcookie.CSRFCookie: "csrf"},
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "wrong header",
+			method:  http.MethodDelete,
+			cookies: map[string]string{authcookie.RefreshCookie: "r", authcookie.CSRFCookie: "csrf"},
+			header:  "other",
+			status:  http.StatusForbidden,
+		},
+		{
+			name:    "explicit refresh token header",
+			method:  http.MethodPost,
+			cookies: map[string]string{authcookie.RefreshCookie: "r"},
+			refresh: "r",
+			status:  http.StatusOK,
+		},
+		{
+			name:    "missing csrf cookie",
+			method:  http.MethodPost,
+			cookies: map[string]string{authcookie.RefreshCookie: "r"},
+			header:  "",
+			status:  http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/refresh", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			if tt.header != "" {
+				req.Header.Set(authcookie.CSRFHeader, tt.header)
+			}
+			if tt.refresh != "" {
+				req.Header.Set("X-Refresh-Token", tt.refresh)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("expected %d, got %d", tt.status, w.Code)
+			}
+		})
+	}
+}
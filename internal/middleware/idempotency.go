@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// выставляется на ответе, повторенном из сохраненного
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 255
+	// тело запроса читается в память целиком, поэтому его размер ограничен
+	maxIdempotentBodySize = 1 << 20
+)
+
+// IdempotencyStore хранилище ключей идемпотентности
+type IdempotencyStore interface {
+	Begin(ctx context.Context, scope, key, fingerprint string) (*model.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *model.IdempotencyRecord) error
+	Release(ctx context.Context, scope, key string) error
+}
+
+// Idempotency повторяет сохраненный ответ на POST запрос с тем же
+// Idempotency-Key и тем же телом. Ключи разделены по пользователю или API
+// ключу, поэтому middleware должен стоять после аутентификации. Запросы без
+// заголовка и ответы 5xx не сохраняются. Ответы хранятся в базе как есть,
+// поэтому middleware ставится только на маршруты без секретов в теле
+// запроса и ответа
+func Idempotency(store IdempotencyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		ctx, span := otel.Tracer("middleware").Start(c.Request.Context(), "IdempotencyMiddleware")
+		defer span.End()
+
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewErrorResponse("idempotency key is too long"))
+			return
+		}
+		scope := idempotencyScope(ctx)
+		if scope == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			span.RecordError(err)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, dto.NewErrorResponse("request body is too large"))
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, dto.NewErrorResponse("failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		sum.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		sum.Write(body)
+		fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+		record, err := store.Begin(ctx, scope, key, fingerprint)
+		if err != nil {
+			span.RecordError(err)
+			switch {
+			case errors.Is(err, model.ErrIdempotencyKeyReused), errors.Is(err, model.ErrIdempotencyInProgress):
+				c.AbortWithStatusJSON(http.StatusConflict, dto.NewErrorResponse(err.Error()))
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, dto.NewErrorResponse("internal server error"))
+			}
+			return
+		}
+		if record != nil {
+			span.SetAttributes(attribute.Bool("idempotency.replayed", true))
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// ответ сохраняем, даже если клиент уже отключился
+		ctx = context.WithoutCancel(ctx)
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, scope, key); err != nil {
+				span.RecordError(err)
+			}
+			return
+		}
+		err = store.Complete(ctx, &model.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			span.RecordError(err)
+		}
+	}
+}
+
+// idempotencyScope владелец ключа: API ключ партнера или пользователь
+func idempotencyScope(ctx context.Context) string {
+	if key, ok := ctx.Value(contextkeys.APIKey).(*model.APIKey); ok && key != nil {
+		return "api_key:" + key.ID.String()
+	}
+	if userID, ok := ctx.Value(contextkeys.UserKeyID).(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return ""
+}
+
+// responseRecorder копирует тело ответа, чтобы сохранить его для повторов
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+// memoryIdempotencyStore хранилище в памяти с той же логикой, что у сервиса
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*model.IdempotencyRecord
+}
+
+func (s *memoryIdempotencyStore) Begin(_ context.Context, scope, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[scope+"/"+key]
+	switch {
+	case !ok:
+		s.records[scope+"/"+key] = &model.IdempotencyRecord{Scope: scope, Key: key, Fingerprint: fingerprint}
+		return nil, nil
+	case r.Fingerprint != fingerprint:
+		return nil, model.ErrIdempotencyKeyReused
+	case r.StatusCode == 0:
+		return nil, model.ErrIdempotencyInProgress
+	}
+	return r, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, record *model.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[record.Scope+"/"+record.Key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, scope+"/"+key)
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &memoryIdempotencyStore{records: map[string]*model.IdempotencyRecord{}}
+
+	calls := 0
+	status := http.StatusOK
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextkeys.UserKeyID, user))
+		}
+	}, Idempotency(store))
+	r.POST("/withdraw", func(c *gin.Context) {
+		calls++
+		c.JSON(status, gin.H{"call": calls})
+	})
+	r.GET("/withdraw", func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusOK)
+	})
+
+	do := func(method, user, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/withdraw", strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do(http.MethodPost, "u1", "k1", `{"sum": 10}`)
+	retry := do(http.MethodPost, "u1", "k1", `{"sum": 10}`)
+	if calls != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() ||
+		retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("expected replayed response %d %q, got %d %q", first.Code, first.Body, retry.Code, retry.Body)
+	}
+
+	if w := do(http.MethodPost, "u1", "k1", `{"sum": 20}`); w.Code != http.StatusConflict {
+		t.Errorf("reused key with other payload: expected 409, got %d", w.Code)
+	}
+
+	// ключи разных пользователей не пересекаются
+	if do(http.MethodPost, "u2", "k1", `{"sum": 20}`); calls != 2 {
+		t.Errorf("expected other user's key to run the handler, calls %d", calls)
+	}
+
+	// без ключа и не для POST запрос выполняется каждый раз
+	do(http.MethodPost, "u1", "", `{"sum": 10}`)
+	do(http.MethodGet, "u1", "k1", "")
+	if calls != 4 {
+		t.Errorf("expected requests without key to run the handler, calls %d", calls)
+	}
+
+	// ответ 5xx не сохраняется, запрос можно повторить
+	status = http.StatusInternalServerError
+	do(http.MethodPost, "u1", "k2", `{"sum": 10}`)
+	status = http.StatusOK
+	if w := do(http.MethodPost, "u1", "k2", `{"sum": 10}`); w.Code != http.StatusOK || calls != 6 {
+		t.Errorf("expected retry after 5xx to run the handler, got %d, calls %d", w.Code, calls)
+	}
+
+	if w := do(http.MethodPost, "u1", strings.Repeat("k", maxIdempotencyKeyLen+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("too long key: expected 400, got %d", w.Code)
+	}
+
+	if w := do(http.MethodPost, "u1", "k3", strings.Repeat(" ", maxIdempotentBodySize+1)); w.Code != http.StatusRequestEntityTooLarge || calls != 6 {
+		t.Errorf("too large body: expected 413, got %d, calls %d", w.Code, calls)
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+// RequireRole пропускает только пользователей с одной из ролей.
+// Должен стоять после AuthMiddleware
+func RequireRole(roles ...model.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, ok := c.Request.Context().Value(contextkeys.UserRoleKey).(model.Role)
+		if !ok || !slices.Contains(roles, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, dto.NewErrorResponse("forbidden"))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// права API ключа
+const (
+	ScopeOrdersWrite      = "orders:write"
+	ScopeRedemptionsWrite = "redemptions:write"
+)
+
+var APIKeyScopes = []string{ScopeOrdersWrite, ScopeRedemptionsWrite}
+
+// APIKey ключ мерчанта для интеграций сервер-сервер. Сам ключ не хранится,
+// только его хеш; Prefix - открытая часть ключа для отображения и метрик
+type APIKey struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	// после ротации старый ключ действует до ExpiresAt
+	ExpiresAt *time.Time
+	RevokedAt *time.Time
+}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Active ключ не отозван и не истек
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || k.ExpiresAt.After(now))
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEventType string
+
+const (
+	// повторное использование уже ротированного refresh токена
+	AuditEventRefreshTokenReuse AuditEventType = "refresh_token_reuse"
+	// блокировка входа после серии неудачных попыток
+	AuditEventAccountLocked AuditEventType = "account_locked"
+	// ручная разблокировка администратором
+	AuditEventAccountUnlocked AuditEventType = "account_unlocked"
+	// смена пароля пользователем
+	AuditEventPasswordChanged AuditEventType = "password_changed"
+	// включение и отключение двухфакторной аутентификации
+	AuditEventMFAEnabled  AuditEventType = "mfa_enabled"
+	AuditEventMFADisabled AuditEventType = "mfa_disabled"
+	// смена роли администратором
+	AuditEventRoleChanged AuditEventType = "role_changed"
+	// списание баллов покупателя кассой партнера
+	AuditEventPartnerRedemption AuditEventType = "partner_redemption"
+	// вход по одноразовому коду восстановления
+	AuditEventMFARecoveryCodeUsed AuditEventType = "mfa_recovery_code_used"
+	// восстановление пароля по одноразовому токену
+	AuditEventPasswordReset AuditEventType = "password_reset"
+	// блокировка и разблокировка пользователя администратором
+	AuditEventUserBanned   AuditEventType = "user_banned"
+	AuditEventUserUnbanned AuditEventType = "user_unbanned"
+	// удаление аккаунта: запрос, отмена входом в течение grace периода
+	// и обезличивание фоновой задачей
+	AuditEventAccountDeletionRequested AuditEventType = "account_deletion_requested"
+	AuditEventAccountDeletionCancelled AuditEventType = "account_deletion_cancelled"
+	AuditEventAccountAnonymized        AuditEventType = "account_anonymized"
+	AuditEventEmailVerified            AuditEventType = "email_verified"
+	AuditEventIdentityLinked           AuditEventType = "identity_linked"
+	// отмена заказа до расчета и возврат после начисления
+	AuditEventOrderCancelled AuditEventType = "order_cancelled"
+	AuditEventOrderReturned  AuditEventType = "order_returned"
+)
+
+type AuditEvent struct {
+	ID        uuid.UUID
+	UserID    *uuid.UUID
+	Type      AuditEventType
+	SessionID *uuid.UUID
+	IP        string
+	Details   map[string]string
+	CreatedAt time.Time
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AuthAttempt счетчик неудачных попыток входа по ключу (логин или IP)
+type AuthAttempt struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// LockoutError попытки входа временно запрещены
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
@@ -6,4 +6,48 @@ var ErrAlreadyExits = errors.New("login already taken")
 var ErrBadOrderNumber = errors.New("bad order number")
 var ErrOrderAlreadyExists = errors.New("such order already exists")
 var ErrOrderLoadedByAnotherPerson = errors.New("such order loaded by another person")
+var ErrOrderNotFound = errors.New("order not found")
+var ErrInvalidOrderTransition = errors.New("order status transition is not allowed")
+var ErrOrderNotCancellable = errors.New("order can't be cancelled in its current status")
 var ErrInsufficientFunds = errors.New("insufficient funds")
+var ErrSessionNotFound = errors.New("session not found")
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+var ErrRefreshTokenExpired = errors.New("refresh token expired")
+var ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+var ErrInvalidCredentials = errors.New("invalid login or password")
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+var ErrNotLocked = errors.New("no active lockout")
+var ErrWeakPassword = errors.New("password does not satisfy policy")
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+var ErrMFANotEnabled = errors.New("two-factor authentication is not enabled")
+var ErrMFANotEnrolled = errors.New("two-factor authentication enrollment not started")
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+var ErrInvalidMFAToken = errors.New("invalid or expired mfa token")
+var ErrInvalidRole = errors.New("invalid role")
+var ErrUserNotFound = errors.New("user not found")
+var ErrAPIKeyNotFound = errors.New("api key not found")
+var ErrInvalidAPIKey = errors.New("invalid api key")
+var ErrInvalidScope = errors.New("invalid api key scope")
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+var ErrUserBanned = errors.New("user is banned")
+var ErrTokenRevoked = errors.New("token revoked")
+var ErrEmailTaken = errors.New("email already in use")
+var ErrEmailNotVerified = errors.New("email is not verified")
+var ErrEmailAlreadyVerified = errors.New("email already verified")
+var ErrNoEmail = errors.New("no email to verify")
+var ErrInvalidVerificationToken = errors.New("invalid or expired email verification token")
+var ErrVerificationThrottled = errors.New("verification email was sent recently")
+var ErrUnknownProvider = errors.New("unknown identity provider")
+var ErrInvalidLoginState = errors.New("invalid or expired login state")
+var ErrExternalAuthFailed = errors.New("external authentication failed")
+var ErrIdentityConflict = errors.New("account with this login already exists, sign in and link the provider")
+var ErrIdentityLinked = errors.New("identity is linked to another account")
+var ErrReauthRequired = errors.New("recent sign in required, sign in with the external provider again")
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+var ErrIdempotencyInProgress = errors.New("request with this idempotency key is still in progress")
+var ErrMerchantNotFound = errors.New("merchant not found")
+var ErrMerchantNameTaken = errors.New("merchant with this name already exists")
+var ErrMerchantOwnerTaken = errors.New("user already owns another merchant")
+var ErrInvalidMerchantOwner = errors.New("merchant owner must be a user with merchant role")
+var ErrReceiptLocked = errors.New("receipt can't be changed after accrual is calculated")
+var ErrInvalidRedemptionCode = errors.New("invalid or expired redemption code")
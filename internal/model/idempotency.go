@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// IdempotencyRecord запрос с ключом идемпотентности и ответ на него.
+// StatusCode 0 - запрос еще выполняется
+type IdempotencyRecord struct {
+	Scope       string
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
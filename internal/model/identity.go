@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity привязка пользователя к учетной записи внешнего провайдера
+type UserIdentity struct {
+	Provider    string
+	Subject     string
+	UserID      uuid.UUID
+	Email       *string
+	CreatedAt   time.Time
+	LastLoginAt *time.Time
+}
+
+// ExternalLoginState начатый вход через внешний провайдер. Хранится хеш
+// state; UserID заполнен, если провайдер привязывается к существующему аккаунту
+type ExternalLoginState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	UserID       *uuid.UUID
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Merchant магазин партнера, от которого приходят заказы и списания
+type Merchant struct {
+	ID   uuid.UUID
+	Name string
+	// пользователь с ролью merchant, чьи API ключи работают от имени мерчанта
+	OwnerID *uuid.UUID
+	// правила проверки номеров заказов, пусто - из настроек сервиса
+	OrderNumberValidator string
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+}
+
+// MerchantReport сводка по заказам и списаниям мерчанта за период
+type MerchantReport struct {
+	Orders          int
+	ProcessedOrders int
+	ReturnedOrders  int
+	Accrued         decimal.Decimal
+	ClawedBack      decimal.Decimal
+	Withdrawals     int
+	Withdrawn       decimal.Decimal
+}
@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA настройки TOTP пользователя. Пока EnabledAt == nil, секрет выдан,
+// но не подтвержден кодом и при входе не используется
+type UserMFA struct {
+	UserID       uuid.UUID
+	Secret       string
+	EnabledAt    *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+func (m *UserMFA) Enabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
@@ -26,9 +26,14 @@ func (s OrderStatus) Value() (driver.Value, error) {
 
 const (
 	OrderStatusNew        OrderStatus = "NEW"
+	OrderStatusRegistered OrderStatus = "REGISTERED"
 	OrderStatusProcessing OrderStatus = "PROCESSING"
 	OrderStatusInvalid    OrderStatus = "INVALID"
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
+	// отмена до расчета начисления
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+	// возврат покупки после начисления, баллы списываются обратно
+	OrderStatusReturned OrderStatus = "RETURNED"
 )
 
 type Order struct {
@@ -37,4 +42,50 @@ type Order struct {
 	Status     OrderStatus
 	Accrual    decimal.Decimal
 	UploadedAt time.Time
+	// магазин, из которого пришел заказ, если известен
+	MerchantID *uuid.UUID
+}
+
+// Valid сообщает, что статус известен сервису
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// OrderSort порядок выдачи списка заказов, "-" - по убыванию
+type OrderSort string
+
+const (
+	OrderSortUploadedDesc OrderSort = "-uploaded_at"
+	OrderSortUploadedAsc  OrderSort = "uploaded_at"
+	OrderSortAccrualDesc  OrderSort = "-accrual"
+	OrderSortAccrualAsc   OrderSort = "accrual"
+)
+
+// OrderCursor последний заказ предыдущей страницы. Из ключей сортировки
+// используется тот, по которому сортируется список, Number различает равные
+type OrderCursor struct {
+	UploadedAt time.Time
+	Accrual    decimal.Decimal
+	Number     string
+}
+
+// OrderListQuery фильтры, сортировка и страница списка заказов
+type OrderListQuery struct {
+	Statuses []OrderStatus
+	// начало интервала загрузки, включительно
+	UploadedFrom *time.Time
+	// конец интервала загрузки, не включительно
+	UploadedTo *time.Time
+	MerchantID *uuid.UUID
+	Sort       OrderSort
+	Limit      int
+	After      *OrderCursor
+}
+
+// OrderStatusChange запись истории заказа: статус и начисление после изменения
+type OrderStatusChange struct {
+	Status    OrderStatus
+	Accrual   decimal.Decimal
+	ChangedAt time.Time
 }
@@ -0,0 +1,58 @@
+package model
+
+// orderTransitions допустимые переходы заказа. Заказ загружается в NEW,
+// расчетная система регистрирует его и обрабатывает. До расчета заказ можно
+// отменить, после начисления - вернуть. INVALID, CANCELLED и RETURNED конечные
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusNew: {OrderStatusRegistered, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed,
+		OrderStatusCancelled},
+	OrderStatusRegistered: {OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed, OrderStatusCancelled},
+	OrderStatusProcessing: {OrderStatusInvalid, OrderStatusProcessed, OrderStatusCancelled},
+	OrderStatusInvalid:    {},
+	OrderStatusProcessed:  {OrderStatusReturned},
+	OrderStatusCancelled:  {},
+	OrderStatusReturned:   {},
+}
+
+// Final сообщает, что заказ больше не меняет статус
+func (s OrderStatus) Final() bool {
+	return len(orderTransitions[s]) == 0
+}
+
+// Pending сообщает, что заказ ждет расчета начисления
+func (s OrderStatus) Pending() bool {
+	switch s {
+	case OrderStatusNew, OrderStatusRegistered, OrderStatusProcessing:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo сообщает, допустим ли переход в next. Повтор текущего
+// статуса допустим, пока заказ ждет расчета: расчетная система может
+// уточнить начисление, не меняя статус
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	if !s.Valid() || !next.Valid() {
+		return false
+	}
+	if s == next {
+		return s.Pending()
+	}
+	for _, to := range orderTransitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedFrom статусы, из которых допустим переход в s
+func (s OrderStatus) AllowedFrom() []OrderStatus {
+	from := make([]OrderStatus, 0, len(orderTransitions))
+	for status := range orderTransitions {
+		if status.CanTransitionTo(s) {
+			from = append(from, status)
+		}
+	}
+	return from
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{OrderStatusNew, OrderStatusRegistered, true},
+		{OrderStatusNew, OrderStatusProcessed, true},
+		{OrderStatusRegistered, OrderStatusProcessing, true},
+		{OrderStatusProcessing, OrderStatusProcessing, true},
+		{OrderStatusProcessing, OrderStatusProcessed, true},
+		{OrderStatusProcessing, OrderStatusInvalid, true},
+		{OrderStatusProcessing, OrderStatusRegistered, false},
+		{OrderStatusProcessing, OrderStatusNew, false},
+		{OrderStatusProcessed, OrderStatusProcessing, false},
+		{OrderStatusProcessed, OrderStatusProcessed, false},
+		{OrderStatusInvalid, OrderStatusProcessed, false},
+		{OrderStatusProcessing, OrderStatusCancelled, true},
+		{OrderStatusProcessed, OrderStatusCancelled, false},
+		{OrderStatusProcessed, OrderStatusReturned, true},
+		{OrderStatusReturned, OrderStatusProcessed, false},
+		{OrderStatusCancelled, OrderStatusProcessing, false},
+		{OrderStatusNew, OrderStatus("DONE"), false},
+		{OrderStatus("DONE"), OrderStatusProcessed, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: expected %v, got %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestOrderStatusAllowedFrom(t *testing.T) {
+	from := OrderStatusProcessing.AllowedFrom()
+	slices.Sort(from)
+	want := []OrderStatus{OrderStatusNew, OrderStatusProcessing, OrderStatusRegistered}
+	if !slices.Equal(from, want) {
+		t.Errorf("expected %v, got %v", want, from)
+	}
+
+	if from := OrderStatusReturned.AllowedFrom(); len(from) != 1 || from[0] != OrderStatusProcessed {
+		t.Errorf("expected only PROCESSED before RETURNED, got %v", from)
+	}
+
+	if from := OrderStatusNew.AllowedFrom(); len(from) != 1 || from[0] != OrderStatusNew {
+		t.Errorf("expected only NEW before NEW, got %v", from)
+	}
+}
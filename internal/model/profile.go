@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserProfile персональные данные пользователя. Пустые поля - не заполнены
+type UserProfile struct {
+	UserID      uuid.UUID
+	DisplayName *string
+	Email       *string
+	Phone       *string
+	Birthday    *time.Time
+	// согласия на маркетинговые рассылки
+	MarketingEmail    bool
+	MarketingSMS      bool
+	ConsentsUpdatedAt *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Receipt чек покупки, по которой загружен заказ
+type Receipt struct {
+	OrderNumber string
+	// магазин, передавший чек
+	MerchantID *uuid.UUID
+	Total      decimal.Decimal
+	// код валюты ISO 4217
+	Currency  string
+	Items     []ReceiptItem
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ReceiptItem позиция чека
+type ReceiptItem struct {
+	SKU      string
+	Category string
+	Quantity decimal.Decimal
+	// цена за единицу с учетом скидок
+	Price decimal.Decimal
+}
+
+// Amount стоимость позиции
+func (i ReceiptItem) Amount() decimal.Decimal {
+	return i.Quantity.Mul(i.Price)
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RedemptionCode одноразовый код, которым покупатель подтверждает списание
+// баллов на кассе партнера. Хранится только хеш
+type RedemptionCode struct {
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
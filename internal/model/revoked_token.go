@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RevokedTokenKind по какому claim отозван access токен
+type RevokedTokenKind string
+
+const (
+	// отдельный токен
+	RevokedTokenJTI RevokedTokenKind = "jti"
+	// все токены сессии
+	RevokedTokenSID RevokedTokenKind = "sid"
+)
+
+type RevokedToken struct {
+	Kind      RevokedTokenKind
+	Value     string
+	RevokedAt time.Time
+}
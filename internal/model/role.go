@@ -0,0 +1,18 @@
+package model
+
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+	RoleMerchant Role = "merchant"
+)
+
+func (r Role) Valid() bool {
+	switch r {
+	case RoleCustomer, RoleSupport, RoleAdmin, RoleMerchant:
+		return true
+	}
+	return false
+}
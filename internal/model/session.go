@@ -10,6 +10,8 @@ type Session struct {
 	ID                 uuid.UUID
 	UserID             uuid.UUID
 	HashedRefreshToken string
+	UserAgent          string
+	IP                 string
 	AuthAt             time.Time
 	ExpireAt           time.Time
 }
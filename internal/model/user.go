@@ -1,6 +1,8 @@
 package model
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
@@ -11,5 +13,12 @@ type User struct {
 	Password  string
 	Balance   decimal.Decimal
 	Withdrawn decimal.Decimal
+	Role      Role
 	Salt      string
+	// время блокировки администратором, nil - не заблокирован
+	BannedAt *time.Time
+	// когда пользователь запросил удаление аккаунта, nil - не запрашивал
+	DeletionRequestedAt *time.Time
+	// когда пользователь подтвердил email, nil - не подтвержден
+	EmailVerifiedAt *time.Time
 }
@@ -10,6 +10,24 @@ import (
 type Balance struct {
 	Current   float64
 	Withdrawn float64
+	// сколько баллов пользователь должен после возврата уже потраченных
+	Debt float64
+}
+
+// BalanceAdjustmentReason причина корректировки баланса
+type BalanceAdjustmentReason string
+
+// возврат заказа, начисление за который списывается обратно
+const BalanceAdjustmentOrderReturned BalanceAdjustmentReason = "order_returned"
+
+// BalanceAdjustment корректировка баланса, отрицательная сумма его уменьшает
+type BalanceAdjustment struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	OrderNumber string
+	Amount      decimal.Decimal
+	Reason      BalanceAdjustmentReason
+	CreatedAt   time.Time
 }
 
 type Withdrawal struct {
@@ -18,4 +36,6 @@ type Withdrawal struct {
 	UserID      uuid.UUID
 	Amount      decimal.Decimal
 	ProcessedAt time.Time
+	// магазин, в котором списаны баллы, если известен
+	MerchantID *uuid.UUID
 }
@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileNotifier пишет сообщения в файл или stdout. Нужен для разработки
+// и окружений без почтового сервера
+type FileNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewFileNotifier открывает файл на дозапись. Пустой путь или "-" - stdout
+func NewFileNotifier(path string) (*FileNotifier, error) {
+	if path == "" || path == "-" {
+		return &FileNotifier{w: os.Stdout}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open notifier file: %w", err)
+	}
+	return &FileNotifier{w: file}, nil
+}
+
+func (n *FileNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.w, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+
+	n, err := NewFileNotifier(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, to := range []string{"first@example.com", "second@example.com"} {
+		err := n.Send(context.Background(), Message{To: to, Subject: "hello", Body: "token"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(raw)
+	if !strings.Contains(out, "To: first@example.com") || !strings.Contains(out, "To: second@example.com") {
+		t.Errorf("expected both messages in file, got %q", out)
+	}
+}
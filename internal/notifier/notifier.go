@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Message сообщение пользователю. To - адрес получателя
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Notifier доставляет сообщения пользователям
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv выбирает реализацию по NOTIFIER_DRIVER. По умолчанию сообщения
+// пишутся в файл NOTIFIER_FILE, а если он не задан - в stdout. smtp
+// отправляет письма через NOTIFIER_SMTP_ADDR
+func NewFromEnv() (Notifier, error) {
+	switch driver := os.Getenv("NOTIFIER_DRIVER"); driver {
+	case "", "file":
+		return NewFileNotifier(os.Getenv("NOTIFIER_FILE"))
+	case "smtp":
+		return NewSMTPNotifier(os.Getenv("NOTIFIER_SMTP_ADDR"), os.Getenv("NOTIFIER_SMTP_FROM"),
+			os.Getenv("NOTIFIER_SMTP_USERNAME"), os.Getenv("NOTIFIER_SMTP_PASSWORD"))
+	default:
+		return nil, fmt.Errorf("unknown notifier driver %q", driver)
+	}
+}
@@ -0,0 +1,105 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// без дедлайна в ctx не ждем почтовый сервер дольше этого
+const smtpTimeout = 30 * time.Second
+
+// SMTPNotifier отправляет сообщения письмами через SMTP сервер. Если сервер
+// поддерживает STARTTLS, соединение шифруется
+type SMTPNotifier struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPNotifier addr - host:port сервера. Без username письма отправляются
+// без аутентификации, как принимает локальный relay или mailpit
+func NewSMTPNotifier(addr, from, username, password string) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", addr, err)
+	}
+	if from == "" {
+		return nil, fmt.Errorf("smtp sender address is required")
+	}
+
+	n := &SMTPNotifier{addr: addr, host: host, from: from}
+	if username != "" {
+		n.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", n.addr)
+	if err != nil {
+		return fmt.Errorf("dial smtp: %w", err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	_ = conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: n.host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if n.auth != nil {
+		if err := c.Auth(n.auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(n.from); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(n.compose(msg)); err != nil {
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// compose собирает письмо в формате RFC 5322, тема кодируется для кириллицы
+func (n *SMTPNotifier) compose(msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", n.from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.Write(bytes.ReplaceAll([]byte(msg.Body), []byte("\n"), []byte("\r\n")))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,84 @@
+package notifier
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP принимает одно письмо и отдает его текст в канал
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	out := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 fake")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				out <- data.String()
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, out := fakeSMTP(t)
+
+	n, err := NewSMTPNotifier(addr, "noreply@example.com", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(context.Background(), Message{To: "ivan@example.com", Subject: "Подтверждение email",
+		Body: "token\nline"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-out
+	for _, want := range []string{"From: noreply@example.com\r\n", "To: ivan@example.com\r\n",
+		"Subject: =?utf-8?q?", "token\r\nline\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in message, got %q", want, msg)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type APIKeyRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewAPIKeyRepoPostgres(db DBExecutor, logger *zap.Logger) *APIKeyRepoPostgres {
+	return &APIKeyRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "api_key")),
+	}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, created_at,
+	last_used_at, expires_at, revoked_at`
+
+func scanAPIKey(row pgx.Row, k *model.APIKey) error {
+	return row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &k.Scopes,
+		&k.CreatedAt, &k.LastUsedAt, &k.ExpiresAt, &k.RevokedAt)
+}
+
+func (repo *APIKeyRepoPostgres) Create(ctx context.Context, key *model.APIKey) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := repo.db.Exec(ctx, query, key.ID, key.UserID, key.Name, key.Prefix,
+		key.KeyHash, key.Scopes, key.CreatedAt)
+	if err != nil {
+		repo.logger.Error("failed to create api key", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("api key create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", key.Prefix))
+	repo.logger.Info("api key created", zap.String("user_id", key.UserID.String()),
+		zap.String("prefix", key.Prefix))
+	return nil
+}
+
+// GetByID возвращает ключ пользователя или model.ErrAPIKeyNotFound
+func (repo *APIKeyRepoPostgres) GetByID(ctx context.Context, userID, keyID string) (*model.APIKey, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.GetByID")
+	defer span.End()
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	var k model.APIKey
+	if err := scanAPIKey(repo.db.QueryRow(ctx, query, keyID, userID), &k); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, model.ErrAPIKeyNotFound
+		}
+		repo.logger.Error("failed to get api key", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+	return &k, nil
+}
+
+// GetActiveByHash ищет действующий ключ, владелец которого все еще мерчант.
+// nil, если такого нет
+func (repo *APIKeyRepoPostgres) GetActiveByHash(ctx context.Context, hash string) (*model.APIKey, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.GetActiveByHash")
+	defer span.End()
+
+	query := `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.key_hash, k.scopes, k.created_at,
+			k.last_used_at, k.expires_at, k.revoked_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1
+			AND k.revoked_at IS NULL
+			AND (k.expires_at IS NULL OR k.expires_at > NOW())
+			AND u.role = 'merchant'
+	`
+
+	var k model.APIKey
+	if err := scanAPIKey(repo.db.QueryRow(ctx, query, hash), &k); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get api key by hash", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get api key by hash: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", k.Prefix))
+	return &k, nil
+}
+
+// GetAllByUserID возвращает все неотозванные ключи пользователя
+func (repo *APIKeyRepoPostgres) GetAllByUserID(ctx context.Context, userID string) ([]model.APIKey, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.GetAllByUserID")
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`
+
+	rows, err := repo.db.Query(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("failed to get api keys", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]model.APIKey, 0)
+	for rows.Next() {
+		var k model.APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			repo.logger.Error("failed to scan api key", zap.Error(err))
+			span.RecordError(err)
+			return nil, fmt.Errorf("scan api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		repo.logger.Error("rows error", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("api_keys_count", len(keys)))
+	return keys, nil
+}
+
+// Revoke отзывает ключ пользователя
+func (repo *APIKeyRepoPostgres) Revoke(ctx context.Context, userID, keyID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.Revoke")
+	defer span.End()
+
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+
+	tag, err := repo.db.Exec(ctx, query, keyID, userID)
+	if err != nil {
+		repo.logger.Error("failed to revoke api key", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(model.ErrAPIKeyNotFound)
+		return model.ErrAPIKeyNotFound
+	}
+
+	repo.logger.Info("api key revoked", zap.String("key_id", keyID))
+	return nil
+}
+
+// SetExpiresAt ограничивает срок действия ключа (используется при ротации)
+func (repo *APIKeyRepoPostgres) SetExpiresAt(ctx context.Context, keyID string, expiresAt time.Time) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.SetExpiresAt")
+	defer span.End()
+
+	query := `
+		UPDATE api_keys
+		SET expires_at = LEAST(COALESCE(expires_at, $2), $2)
+		WHERE id = $1
+	`
+
+	if _, err := repo.db.Exec(ctx, query, keyID, expiresAt); err != nil {
+		repo.logger.Error("failed to set api key expiration", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("set api key expiration: %w", err)
+	}
+	return nil
+}
+
+// TouchLastUsed обновляет last_used_at не чаще раза в minInterval,
+// чтобы не писать в базу на каждый запрос
+func (repo *APIKeyRepoPostgres) TouchLastUsed(ctx context.Context, keyID string, minInterval time.Duration) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.TouchLastUsed")
+	defer span.End()
+
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - $2::interval)
+	`
+
+	if _, err := repo.db.Exec(ctx, query, keyID, minInterval); err != nil {
+		repo.logger.Error("failed to update api key last_used_at", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	return nil
+}
+
+// RevokeAll отзывает все ключи пользователя
+func (repo *APIKeyRepoPostgres) RevokeAll(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "APIKeyRepo.RevokeAll")
+	defer span.End()
+
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	tag, err := repo.db.Exec(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("failed to revoke api keys", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("revoke api keys: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("api_keys_revoked", tag.RowsAffected()))
+	return nil
+}
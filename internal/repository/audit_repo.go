@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type AuditRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewAuditRepoPostgres(db DBExecutor, logger *zap.Logger) *AuditRepoPostgres {
+	return &AuditRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "audit")),
+	}
+}
+
+func (repo *AuditRepoPostgres) Create(ctx context.Context, event *model.AuditEvent) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuditRepo.Create")
+	defer span.End()
+
+	details := event.Details
+	if details == nil {
+		details = map[string]string{}
+	}
+
+	query := `
+		INSERT INTO audit_events (id, user_id, event_type, session_id, ip, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := repo.db.Exec(ctx, query,
+		event.ID,
+		event.UserID,
+		string(event.Type),
+		event.SessionID,
+		event.IP,
+		details,
+		event.CreatedAt,
+	)
+	if err != nil {
+		repo.logger.Error("failed to create audit event", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("audit event create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("audit.event_type", string(event.Type)))
+	repo.logger.Info("audit event recorded", zap.String("event_type", string(event.Type)))
+	return nil
+}
+
+// ScrubUser удаляет из событий пользователя персональные данные (IP,
+// user agent в details), оставляя сами события
+func (repo *AuditRepoPostgres) ScrubUser(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuditRepo.ScrubUser")
+	defer span.End()
+
+	query := `UPDATE audit_events SET ip = '', details = '{}'::jsonb WHERE user_id = $1`
+
+	tag, err := repo.db.Exec(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("failed to scrub audit events", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("scrub audit events: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("audit_events_scrubbed", tag.RowsAffected()))
+	return nil
+}
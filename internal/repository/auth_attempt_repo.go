@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type AuthAttemptRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewAuthAttemptRepoPostgres(db DBExecutor, logger *zap.Logger) *AuthAttemptRepoPostgres {
+	return &AuthAttemptRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "auth_attempt")),
+	}
+}
+
+// Get возвращает счетчик попыток или nil, если неудач по ключу не было
+func (repo *AuthAttemptRepoPostgres) Get(ctx context.Context, key string) (*model.AuthAttempt, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuthAttemptRepo.Get")
+	defer span.End()
+
+	query := `
+		SELECT key, failures, last_failure_at, locked_until
+		FROM auth_attempts
+		WHERE key = $1
+	`
+
+	var a model.AuthAttempt
+	err := repo.db.QueryRow(ctx, query, key).Scan(&a.Key, &a.Failures, &a.LastFailureAt, &a.LockedUntil)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get auth attempt", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get auth attempt: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("auth_attempt.failures", a.Failures))
+	return &a, nil
+}
+
+// Acquire атомарно проверяет, разрешена ли попытка по ключу, и сразу учитывает
+// ее как неудачную. Задержка, блокировка и сброс счетчика считаются в том же
+// UPDATE, что и инкремент, поэтому параллельные запросы не проскочат в одно окно.
+// Возвращает nil, если попытка сейчас запрещена
+func (repo *AuthAttemptRepoPostgres) Acquire(ctx context.Context, key string,
+	policy loginguard.Policy, now time.Time) (*model.AuthAttempt, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuthAttemptRepo.Acquire")
+	defer span.End()
+
+	insertQuery := `
+		INSERT INTO auth_attempts (key, failures, last_failure_at)
+		VALUES ($1, 0, $2)
+		ON CONFLICT (key) DO NOTHING
+	`
+	if _, err := repo.db.Exec(ctx, insertQuery, key, now); err != nil {
+		repo.logger.Error("failed to init auth attempt", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("init auth attempt: %w", err)
+	}
+
+	// expired повторяет loginguard.Policy.expired, delay - loginguard.Policy.delay.
+	// Длительности передаются в миллисекундах
+	const expired = `(
+		(locked_until IS NOT NULL AND locked_until <= $2::timestamptz)
+		OR (locked_until IS NULL AND $7::bigint > 0
+			AND last_failure_at + $7::bigint * interval '1 millisecond' <= $2::timestamptz)
+	)`
+	const delay = `(CASE
+		WHEN $4::bigint <= 0 OR failures <= 0 THEN 0
+		WHEN $5::bigint > 0 THEN LEAST($4::bigint * power(2, LEAST(failures - 1, 30)), $5::bigint)
+		ELSE $4::bigint * power(2, LEAST(failures - 1, 30))
+	END)`
+	const failures = `(CASE WHEN ` + expired + ` THEN 1 ELSE failures + 1 END)`
+
+	query := `
+		UPDATE auth_attempts
+		SET failures = ` + failures + `,
+			last_failure_at = $2::timestamptz,
+			locked_until = CASE
+				WHEN $3::int > 0 AND ` + failures + ` >= $3::int
+				THEN $2::timestamptz + $6::bigint * interval '1 millisecond'
+			END
+		WHERE key = $1
+			AND (` + expired + `
+				OR (locked_until IS NULL
+					AND last_failure_at + ` + delay + ` * interval '1 millisecond' <= $2::timestamptz))
+		RETURNING key, failures, last_failure_at, locked_until
+	`
+
+	var a model.AuthAttempt
+	err := repo.db.QueryRow(ctx, query, key, now, policy.MaxFailures,
+		policy.BaseDelay.Milliseconds(), policy.MaxDelay.Milliseconds(),
+		policy.LockoutDuration.Milliseconds(), policy.ResetAfter.Milliseconds()).
+		Scan(&a.Key, &a.Failures, &a.LastFailureAt, &a.LockedUntil)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			span.SetAttributes(attribute.Bool("auth_attempt.throttled", true))
+			return nil, nil
+		}
+		repo.logger.Error("failed to acquire auth attempt", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("acquire auth attempt: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("auth_attempt.failures", a.Failures))
+	return &a, nil
+}
+
+// Release возвращает попытку, учтенную Acquire, если она оказалась успешной.
+// Блокировка снимается, только если без этой попытки порог не достигнут
+func (repo *AuthAttemptRepoPostgres) Release(ctx context.Context, key string, policy loginguard.Policy) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuthAttemptRepo.Release")
+	defer span.End()
+
+	query := `
+		UPDATE auth_attempts
+		SET failures = GREATEST(failures - 1, 0),
+			locked_until = CASE
+				WHEN $2::int > 0 AND failures - 1 >= $2::int THEN locked_until
+			END
+		WHERE key = $1
+	`
+
+	if _, err := repo.db.Exec(ctx, query, key, policy.MaxFailures); err != nil {
+		repo.logger.Error("failed to release auth attempt", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("release auth attempt: %w", err)
+	}
+	return nil
+}
+
+// Delete сбрасывает счетчик. Возвращает false, если сбрасывать было нечего
+func (repo *AuthAttemptRepoPostgres) Delete(ctx context.Context, key string) (bool, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "AuthAttemptRepo.Delete")
+	defer span.End()
+
+	query := `DELETE FROM auth_attempts WHERE key = $1`
+
+	tag, err := repo.db.Exec(ctx, query, key)
+	if err != nil {
+		repo.logger.Error("failed to delete auth attempt", zap.Error(err))
+		span.RecordError(err)
+		return false, fmt.Errorf("delete auth attempt: %w", err)
+	}
+
+	return tag.RowsAffected() > 0, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	"go.uber.org/zap"
@@ -22,20 +23,27 @@ func NewBalanceRepoPostgres(db DBExecutor, logger *zap.Logger) *BalanceRepoPostg
 }
 
 func (r *BalanceRepoPostgres) Get(ctx context.Context, userID string) (*model.Balance, error) {
+	// суммы считаются отдельными подзапросами: соединение заказов со
+	// списаниями размножало строки и завышало обе суммы. Остаток ниже нуля
+	// после возврата заказа отдается как долг
 	query := `
 		SELECT
-			COALESCE(SUM(CASE WHEN o.accrual IS NOT NULL THEN o.accrual ELSE 0 END), 0) AS current,
-			COALESCE(SUM(w.amount), 0) AS withdrawn
-		FROM users u
-		LEFT JOIN orders o ON u.id = o.user_id
-		LEFT JOIN withdrawals w ON u.id = w.user_id
-		WHERE u.id = $1;
+			GREATEST(b.accrued + b.adjusted - b.withdrawn, 0) AS current,
+			b.withdrawn,
+			GREATEST(b.withdrawn - b.accrued - b.adjusted, 0) AS debt
+		FROM (
+			SELECT
+				(SELECT COALESCE(SUM(accrual), 0) FROM orders WHERE user_id = $1) AS accrued,
+				(SELECT COALESCE(SUM(amount), 0) FROM balance_adjustments WHERE user_id = $1) AS adjusted,
+				(SELECT COALESCE(SUM(amount), 0) FROM withdrawals WHERE user_id = $1) AS withdrawn
+		) b;
 	`
 
 	var balance model.Balance
 	err := r.db.QueryRow(ctx, query, userID).Scan(
 		&balance.Current,
 		&balance.Withdrawn,
+		&balance.Debt,
 	)
 	if err != nil {
 		r.logger.Error("failed to get balance", zap.Error(err))
@@ -47,8 +55,8 @@ func (r *BalanceRepoPostgres) Get(ctx context.Context, userID string) (*model.Ba
 
 func (r *BalanceRepoPostgres) AddWithdraw(ctx context.Context, withdrawal *model.Withdrawal) error {
 	query := `
-		INSERT INTO withdrawals (id, order_id, user_id, amount, processed_at)
-		VALUES ($1, $2, $3, $4, $5);
+		INSERT INTO withdrawals (id, order_id, user_id, amount, processed_at, merchant_id)
+		VALUES ($1, $2, $3, $4, $5, $6);
 	`
 
 	_, err := r.db.Exec(
@@ -59,6 +67,7 @@ func (r *BalanceRepoPostgres) AddWithdraw(ctx context.Context, withdrawal *model
 		withdrawal.UserID,
 		withdrawal.Amount,
 		withdrawal.ProcessedAt,
+		withdrawal.MerchantID,
 	)
 
 	if err != nil {
@@ -69,15 +78,42 @@ func (r *BalanceRepoPostgres) AddWithdraw(ctx context.Context, withdrawal *model
 	return nil
 }
 
-func (r *BalanceRepoPostgres) GetAllWithdrawals(ctx context.Context, userID string) ([]model.Withdrawal, error) {
+func (r *BalanceRepoPostgres) AddAdjustment(ctx context.Context, adjustment *model.BalanceAdjustment) error {
 	query := `
-		SELECT id, order_id, user_id, amount, processed_at
+		INSERT INTO balance_adjustments (id, user_id, order_number, amount, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`
+
+	_, err := r.db.Exec(
+		ctx,
+		query,
+		adjustment.ID,
+		adjustment.UserID,
+		adjustment.OrderNumber,
+		adjustment.Amount,
+		adjustment.Reason,
+		adjustment.CreatedAt,
+	)
+
+	if err != nil {
+		r.logger.Error("failed to add balance adjustment", zap.Error(err))
+		return fmt.Errorf("add balance adjustment: %w", err)
+	}
+
+	return nil
+}
+
+// GetAllWithdrawals списания пользователя, merchantID ограничивает их одним мерчантом
+func (r *BalanceRepoPostgres) GetAllWithdrawals(ctx context.Context, userID string,
+	merchantID *uuid.UUID) ([]model.Withdrawal, error) {
+	query := `
+		SELECT id, order_id, user_id, amount, processed_at, merchant_id
 		FROM withdrawals
-		WHERE user_id = $1
+		WHERE user_id = $1 AND ($2::uuid IS NULL OR merchant_id = $2)
 		ORDER BY processed_at DESC;
 	`
 
-	rows, err := r.db.Query(ctx, query, userID)
+	rows, err := r.db.Query(ctx, query, userID, merchantID)
 	if err != nil {
 		r.logger.Error("failed to get withdrawals", zap.Error(err))
 		return nil, fmt.Errorf("get withdrawals: %w", err)
@@ -90,7 +126,7 @@ func (r *BalanceRepoPostgres) GetAllWithdrawals(ctx context.Context, userID stri
 		var w model.Withdrawal
 		var amount decimal.Decimal
 
-		err := rows.Scan(&w.ID, &w.OrderID, &w.UserID, &amount, &w.ProcessedAt)
+		err := rows.Scan(&w.ID, &w.OrderID, &w.UserID, &amount, &w.ProcessedAt, &w.MerchantID)
 		if err != nil {
 			r.logger.Error("failed to scan withdrawal", zap.Error(err))
 			return nil, fmt.Errorf("scan withdrawal: %w", err)
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type EmailVerificationRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewEmailVerificationRepoPostgres(db DBExecutor, logger *zap.Logger) *EmailVerificationRepoPostgres {
+	return &EmailVerificationRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "email_verification")),
+	}
+}
+
+func (repo *EmailVerificationRepoPostgres) Create(ctx context.Context, token *model.EmailVerificationToken) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "EmailVerificationRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := repo.db.Exec(ctx, query, token.TokenHash, token.UserID, token.Email,
+		token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		repo.logger.Error("failed to create email verification token", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("email verification token create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", token.UserID.String()))
+	return nil
+}
+
+// LastCreatedAt время выдачи последнего токена пользователя или nil
+func (repo *EmailVerificationRepoPostgres) LastCreatedAt(ctx context.Context, userID string) (*time.Time, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "EmailVerificationRepo.LastCreatedAt")
+	defer span.End()
+
+	query := `SELECT MAX(created_at) FROM email_verification_tokens WHERE user_id = $1`
+
+	var last *time.Time
+	if err := repo.db.QueryRow(ctx, query, userID).Scan(&last); err != nil {
+		repo.logger.Error("failed to get last email verification token", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get last email verification token: %w", err)
+	}
+	return last, nil
+}
+
+// Consume атомарно помечает действующий токен использованным и возвращает его.
+// nil - токен не найден, истек или уже использован
+func (repo *EmailVerificationRepoPostgres) Consume(ctx context.Context,
+	tokenHash string) (*model.EmailVerificationToken, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "EmailVerificationRepo.Consume")
+	defer span.End()
+
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING token_hash, user_id, email, created_at, expires_at, used_at
+	`
+
+	var t model.EmailVerificationToken
+	err := repo.db.QueryRow(ctx, query, tokenHash).Scan(&t.TokenHash, &t.UserID, &t.Email,
+		&t.CreatedAt, &t.ExpiresAt, &t.UsedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to consume email verification token", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("consume email verification token: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", t.UserID.String()))
+	return &t, nil
+}
+
+// InvalidateAll помечает использованными все неиспользованные токены пользователя
+func (repo *EmailVerificationRepoPostgres) InvalidateAll(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "EmailVerificationRepo.InvalidateAll")
+	defer span.End()
+
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	if _, err := repo.db.Exec(ctx, query, userID); err != nil {
+		repo.logger.Error("failed to invalidate email verification tokens", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("invalidate email verification tokens: %w", err)
+	}
+	return nil
+}
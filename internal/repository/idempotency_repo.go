@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type IdempotencyRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewIdempotencyRepoPostgres(db DBExecutor, logger *zap.Logger) *IdempotencyRepoPostgres {
+	return &IdempotencyRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "idempotency")),
+	}
+}
+
+// Reserve занимает ключ под новый запрос. Истекший ключ и ключ, запрос по
+// которому не завершился до staleBefore, занимаются заново. false - ключ занят
+func (repo *IdempotencyRepoPostgres) Reserve(ctx context.Context,
+	record *model.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdempotencyRepo.Reserve")
+	defer span.End()
+
+	query := `
+		INSERT INTO idempotency_keys (scope, key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint, status_code = NULL, content_type = NULL,
+			response_body = NULL, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= NOW()
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $6)
+		RETURNING key
+	`
+
+	var key string
+	err := repo.db.QueryRow(ctx, query, record.Scope, record.Key, record.Fingerprint,
+		record.CreatedAt, record.ExpiresAt, staleBefore).Scan(&key)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		repo.logger.Error("failed to reserve idempotency key", zap.Error(err))
+		span.RecordError(err)
+		return false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("idempotency.scope", record.Scope))
+	return true, nil
+}
+
+// Get возвращает действующий ключ или nil
+func (repo *IdempotencyRepoPostgres) Get(ctx context.Context,
+	scope, key string) (*model.IdempotencyRecord, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdempotencyRepo.Get")
+	defer span.End()
+
+	query := `
+		SELECT scope, key, fingerprint, COALESCE(status_code, 0), COALESCE(content_type, ''),
+			response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND key = $2 AND expires_at > NOW()
+	`
+
+	var r model.IdempotencyRecord
+	err := repo.db.QueryRow(ctx, query, scope, key).Scan(&r.Scope, &r.Key, &r.Fingerprint,
+		&r.StatusCode, &r.ContentType, &r.Body, &r.CreatedAt, &r.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get idempotency key", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+	return &r, nil
+}
+
+// Complete сохраняет ответ на запрос по ключу
+func (repo *IdempotencyRepoPostgres) Complete(ctx context.Context, record *model.IdempotencyRecord) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdempotencyRepo.Complete")
+	defer span.End()
+
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $3, content_type = $4, response_body = $5
+		WHERE scope = $1 AND key = $2
+	`
+
+	_, err := repo.db.Exec(ctx, query, record.Scope, record.Key, record.StatusCode,
+		record.ContentType, record.Body)
+	if err != nil {
+		repo.logger.Error("failed to complete idempotency key", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", record.StatusCode))
+	return nil
+}
+
+func (repo *IdempotencyRepoPostgres) Delete(ctx context.Context, scope, key string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdempotencyRepo.Delete")
+	defer span.End()
+
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	if _, err := repo.db.Exec(ctx, query, scope, key); err != nil {
+		repo.logger.Error("failed to delete idempotency key", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired удаляет истекшие ключи и возвращает их число
+func (repo *IdempotencyRepoPostgres) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdempotencyRepo.DeleteExpired")
+	defer span.End()
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= NOW()`
+
+	tag, err := repo.db.Exec(ctx, query)
+	if err != nil {
+		repo.logger.Error("failed to delete expired idempotency keys", zap.Error(err))
+		span.RecordError(err)
+		return 0, fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("deleted_count", tag.RowsAffected()))
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type IdentityRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewIdentityRepoPostgres(db DBExecutor, logger *zap.Logger) *IdentityRepoPostgres {
+	return &IdentityRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "identity")),
+	}
+}
+
+// Get возвращает привязку внешней учетной записи или nil, если ее нет
+func (repo *IdentityRepoPostgres) Get(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdentityRepo.Get")
+	defer span.End()
+
+	query := `
+		SELECT provider, subject, user_id, email, created_at, last_login_at
+		FROM user_identities
+		WHERE provider = $1 AND subject = $2
+	`
+
+	var i model.UserIdentity
+	err := repo.db.QueryRow(ctx, query, provider, subject).Scan(&i.Provider, &i.Subject, &i.UserID,
+		&i.Email, &i.CreatedAt, &i.LastLoginAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get identity", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get identity: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", i.UserID.String()))
+	return &i, nil
+}
+
+func (repo *IdentityRepoPostgres) Create(ctx context.Context, identity *model.UserIdentity) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdentityRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO user_identities (provider, subject, user_id, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := repo.db.Exec(ctx, query, identity.Provider, identity.Subject, identity.UserID,
+		identity.Email, identity.CreatedAt, identity.LastLoginAt)
+	if err != nil {
+		repo.logger.Error("failed to create identity", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("identity create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", identity.UserID.String()),
+		attribute.String("identity.provider", identity.Provider))
+	return nil
+}
+
+func (repo *IdentityRepoPostgres) TouchLastLogin(ctx context.Context, provider, subject string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdentityRepo.TouchLastLogin")
+	defer span.End()
+
+	query := `UPDATE user_identities SET last_login_at = NOW() WHERE provider = $1 AND subject = $2`
+
+	if _, err := repo.db.Exec(ctx, query, provider, subject); err != nil {
+		repo.logger.Error("failed to update identity last login", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("update identity last login: %w", err)
+	}
+	return nil
+}
+
+func (repo *IdentityRepoPostgres) DeleteAllByUserID(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "IdentityRepo.DeleteAllByUserID")
+	defer span.End()
+
+	query := `DELETE FROM user_identities WHERE user_id = $1`
+
+	if _, err := repo.db.Exec(ctx, query, userID); err != nil {
+		repo.logger.Error("failed to delete identities", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete identities: %w", err)
+	}
+	return nil
+}
+
+type ExternalLoginStateRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewExternalLoginStateRepoPostgres(db DBExecutor, logger *zap.Logger) *ExternalLoginStateRepoPostgres {
+	return &ExternalLoginStateRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "external_login_state")),
+	}
+}
+
+// Create сохраняет state и заодно удаляет истекшие, чтобы брошенные входы
+// не копились
+func (repo *ExternalLoginStateRepoPostgres) Create(ctx context.Context, state *model.ExternalLoginState) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ExternalLoginStateRepo.Create")
+	defer span.End()
+
+	if _, err := repo.db.Exec(ctx, `DELETE FROM external_login_states WHERE expires_at < NOW()`); err != nil {
+		repo.logger.Error("failed to delete expired login states", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete expired login states: %w", err)
+	}
+
+	query := `
+		INSERT INTO external_login_states (state_hash, provider, code_verifier, nonce, user_id,
+			created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := repo.db.Exec(ctx, query, state.StateHash, state.Provider, state.CodeVerifier, state.Nonce,
+		state.UserID, state.CreatedAt, state.ExpiresAt)
+	if err != nil {
+		repo.logger.Error("failed to create login state", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("login state create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("identity.provider", state.Provider))
+	return nil
+}
+
+// Consume атомарно удаляет действующий state и возвращает его.
+// nil - state не найден, истек или выдан для другого провайдера
+func (repo *ExternalLoginStateRepoPostgres) Consume(ctx context.Context,
+	provider, stateHash string) (*model.ExternalLoginState, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ExternalLoginStateRepo.Consume")
+	defer span.End()
+
+	query := `
+		DELETE FROM external_login_states
+		WHERE state_hash = $1 AND provider = $2 AND expires_at > NOW()
+		RETURNING state_hash, provider, code_verifier, nonce, user_id, created_at, expires_at
+	`
+
+	var s model.ExternalLoginState
+	err := repo.db.QueryRow(ctx, query, stateHash, provider).Scan(&s.StateHash, &s.Provider,
+		&s.CodeVerifier, &s.Nonce, &s.UserID, &s.CreatedAt, &s.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to consume login state", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("consume login state: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("identity.provider", s.Provider))
+	return &s, nil
+}
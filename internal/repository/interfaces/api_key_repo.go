@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *model.APIKey) error
+	GetByID(ctx context.Context, userID, keyID string) (*model.APIKey, error)
+	GetActiveByHash(ctx context.Context, hash string) (*model.APIKey, error)
+	GetAllByUserID(ctx context.Context, userID string) ([]model.APIKey, error)
+	Revoke(ctx context.Context, userID, keyID string) error
+	RevokeAll(ctx context.Context, userID string) error
+	SetExpiresAt(ctx context.Context, keyID string, expiresAt time.Time) error
+	TouchLastUsed(ctx context.Context, keyID string, minInterval time.Duration) error
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *model.AuditEvent) error
+	ScrubUser(ctx context.Context, userID string) error
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+)
+
+type AuthAttemptRepository interface {
+	Get(ctx context.Context, key string) (*model.AuthAttempt, error)
+	Acquire(ctx context.Context, key string, policy loginguard.Policy, now time.Time) (*model.AuthAttempt, error)
+	Release(ctx context.Context, key string, policy loginguard.Policy) error
+	Delete(ctx context.Context, key string) (bool, error)
+}
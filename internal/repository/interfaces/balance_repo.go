@@ -3,11 +3,14 @@ package interfaces
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 )
 
 type BalanceRepository interface {
 	Get(ctx context.Context, userID string) (*model.Balance, error)
 	AddWithdraw(ctx context.Context, withdrawal *model.Withdrawal) error
-	GetAllWithdrawals(ctx context.Context, userID string) ([]model.Withdrawal, error)
+	AddAdjustment(ctx context.Context, adjustment *model.BalanceAdjustment) error
+	GetAllWithdrawals(ctx context.Context, userID string, merchantID *uuid.UUID) ([]model.Withdrawal, error)
 }
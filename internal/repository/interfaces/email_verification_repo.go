@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type EmailVerificationRepository interface {
+	Create(ctx context.Context, token *model.EmailVerificationToken) error
+	LastCreatedAt(ctx context.Context, userID string) (*time.Time, error)
+	Consume(ctx context.Context, tokenHash string) (*model.EmailVerificationToken, error)
+	InvalidateAll(ctx context.Context, userID string) error
+}
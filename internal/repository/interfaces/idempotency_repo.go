@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, record *model.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	Get(ctx context.Context, scope, key string) (*model.IdempotencyRecord, error)
+	Complete(ctx context.Context, record *model.IdempotencyRecord) error
+	Delete(ctx context.Context, scope, key string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type IdentityRepository interface {
+	Get(ctx context.Context, provider, subject string) (*model.UserIdentity, error)
+	Create(ctx context.Context, identity *model.UserIdentity) error
+	TouchLastLogin(ctx context.Context, provider, subject string) error
+	DeleteAllByUserID(ctx context.Context, userID string) error
+}
+
+type ExternalLoginStateRepository interface {
+	Create(ctx context.Context, state *model.ExternalLoginState) error
+	Consume(ctx context.Context, provider, stateHash string) (*model.ExternalLoginState, error)
+}
@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type MerchantRepository interface {
+	Create(ctx context.Context, m *model.Merchant) error
+	GetByID(ctx context.Context, id string) (*model.Merchant, error)
+	GetByOwner(ctx context.Context, ownerID string) (*model.Merchant, error)
+	List(ctx context.Context) ([]model.Merchant, error)
+	Update(ctx context.Context, m *model.Merchant) error
+	Delete(ctx context.Context, id string) error
+	Report(ctx context.Context, id string, from, to *time.Time) (*model.MerchantReport, error)
+}
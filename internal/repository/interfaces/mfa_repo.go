@@ -0,0 +1,18 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type MFARepository interface {
+	Get(ctx context.Context, userID string) (*model.UserMFA, error)
+	GetForUpdate(ctx context.Context, userID string) (*model.UserMFA, error)
+	SavePending(ctx context.Context, mfa *model.UserMFA) error
+	Enable(ctx context.Context, userID string, step int64) error
+	UpdateLastUsedStep(ctx context.Context, userID string, step int64) error
+	Delete(ctx context.Context, userID string) error
+	ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error
+	UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error)
+}
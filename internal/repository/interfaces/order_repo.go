@@ -3,14 +3,22 @@ package interfaces
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 )
 
 type OrderRepository interface {
 	Create(ctx context.Context, order *model.Order) error
+	CreateBatch(ctx context.Context, orders []model.Order) ([]string, error)
+	GetOwners(ctx context.Context, numbers []string) (map[string]uuid.UUID, error)
 	GetByNumber(ctx context.Context, orderNumber string) (*model.Order, error)
+	GetByNumberForUpdate(ctx context.Context, orderNumber string) (*model.Order, error)
 	GetAll(ctx context.Context, userID string) ([]model.Order, error)
+	List(ctx context.Context, userID string, q model.OrderListQuery) ([]model.Order, error)
+	Count(ctx context.Context, userID string, q model.OrderListQuery) (int, error)
 	Delete(ctx context.Context, orderNumber string) error
-	GetAllPending(ctx context.Context) ([]string, error)
+	GetAllPending(ctx context.Context) ([]model.Order, error)
 	Update(ctx context.Context, order model.Order) error
+	GetHistory(ctx context.Context, orderNumber string) ([]model.OrderStatusChange, error)
 }
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *model.PasswordResetToken) error
+	LastCreatedAt(ctx context.Context, userID string) (*time.Time, error)
+	Consume(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error)
+	InvalidateAll(ctx context.Context, userID string) error
+}
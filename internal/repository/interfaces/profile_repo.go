@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type ProfileRepository interface {
+	Get(ctx context.Context, userID string) (*model.UserProfile, error)
+	Upsert(ctx context.Context, profile *model.UserProfile) error
+	Delete(ctx context.Context, userID string) error
+}
@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type ReceiptRepository interface {
+	Save(ctx context.Context, r *model.Receipt) error
+	Get(ctx context.Context, orderNumber string) (*model.Receipt, error)
+}
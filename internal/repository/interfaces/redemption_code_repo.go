@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type RedemptionCodeRepository interface {
+	Create(ctx context.Context, code *model.RedemptionCode) error
+	Consume(ctx context.Context, userID, codeHash string) (bool, error)
+	RegisterFailure(ctx context.Context, userID string, maxFailures int) error
+	InvalidateAll(ctx context.Context, userID string) error
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type RevokedTokenRepository interface {
+	Create(ctx context.Context, token *model.RevokedToken) error
+	GetSince(ctx context.Context, since time.Time) ([]model.RevokedToken, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
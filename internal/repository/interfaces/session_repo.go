@@ -9,5 +9,11 @@ import (
 type SessionRepository interface {
 	Create(ctx context.Context, session *model.Session) error
 	GetByRefreshToken(ctx context.Context, refreshToken string) (*model.Session, error)
+	GetByID(ctx context.Context, sessionID string) (*model.Session, error)
+	GetAllByUserID(ctx context.Context, userID string) ([]model.Session, error)
 	Delete(ctx context.Context, sessionID string) error
+	DeleteAllByUserID(ctx context.Context, userID string) error
+	DeleteOthers(ctx context.Context, userID, keepSessionID string) error
+	Rotate(ctx context.Context, sessionID, oldHash, newHash string) error
+	GetByRotatedToken(ctx context.Context, refreshToken string) (*model.Session, error)
 }
@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 )
@@ -9,4 +10,12 @@ import (
 type UserRepositoryInterface interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByLogin(ctx context.Context, login string) (*model.User, error)
+	GetByID(ctx context.Context, userID string) (*model.User, error)
+	UpdatePassword(ctx context.Context, userID string, hash string) error
+	UpdateRole(ctx context.Context, userID string, role model.Role) error
+	SetBanned(ctx context.Context, userID string, banned bool) error
+	SetEmailVerified(ctx context.Context, userID string, verified bool) error
+	SetDeletionRequested(ctx context.Context, userID string, requested bool) error
+	LockDueForDeletion(ctx context.Context, before time.Time, limit int) ([]model.User, error)
+	Anonymize(ctx context.Context, userID string) error
 }
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// Listen подписывается на канал NOTIFY и вызывает handle для каждого
+// уведомления. Держит отдельное соединение из пула. Возвращает ошибку при
+// потере соединения или nil после отмены ctx; onListen вызывается, когда
+// подписка активна
+func (repos *Repositories) Listen(ctx context.Context, channel string,
+	onListen func(), handle func(payload string)) error {
+	pooled, err := repos.pgxpool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire conn: %w", err)
+	}
+	// соединение в состоянии LISTEN не возвращаем в пул
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return fmt.Errorf("listen %s: %w", channel, err)
+	}
+	repos.logger.Info("listening for notifications", zap.String("channel", channel))
+	if onListen != nil {
+		onListen()
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("wait for notification: %w", err)
+		}
+		handle(n.Payload)
+	}
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type MerchantRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewMerchantRepoPostgres(db DBExecutor, logger *zap.Logger) *MerchantRepoPostgres {
+	return &MerchantRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "merchant")),
+	}
+}
+
+const merchantColumns = `id, name, owner_id, COALESCE(order_number_validator, ''), created_at, updated_at`
+
+func scanMerchant(row pgx.Row, m *model.Merchant) error {
+	return row.Scan(&m.ID, &m.Name, &m.OwnerID, &m.OrderNumberValidator, &m.CreatedAt, &m.UpdatedAt)
+}
+
+// merchantConflict переводит нарушение уникальности имени или владельца в ошибку модели
+func merchantConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != pgUniqueViolation {
+		return nil
+	}
+	if pgErr.ConstraintName == "merchants_owner_id_key" {
+		return model.ErrMerchantOwnerTaken
+	}
+	return model.ErrMerchantNameTaken
+}
+
+func (repo *MerchantRepoPostgres) Create(ctx context.Context, m *model.Merchant) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO merchants (id, name, owner_id, order_number_validator, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+	`
+
+	_, err := repo.db.Exec(ctx, query, m.ID, m.Name, m.OwnerID, m.OrderNumberValidator,
+		m.CreatedAt, m.UpdatedAt)
+	if err != nil {
+		span.RecordError(err)
+		if conflict := merchantConflict(err); conflict != nil {
+			return conflict
+		}
+		repo.logger.Error("failed to create merchant", zap.Error(err))
+		return fmt.Errorf("merchant create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", m.ID.String()))
+	repo.logger.Info("merchant created", zap.String("merchant.id", m.ID.String()))
+	return nil
+}
+
+// GetByID возвращает мерчанта или model.ErrMerchantNotFound
+func (repo *MerchantRepoPostgres) GetByID(ctx context.Context, id string) (*model.Merchant, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.GetByID")
+	defer span.End()
+
+	query := `SELECT ` + merchantColumns + ` FROM merchants WHERE id = $1`
+
+	var m model.Merchant
+	if err := scanMerchant(repo.db.QueryRow(ctx, query, id), &m); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, model.ErrMerchantNotFound
+		}
+		repo.logger.Error("failed to get merchant", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get merchant: %w", err)
+	}
+	return &m, nil
+}
+
+// GetByOwner возвращает мерчанта пользователя или nil, если его нет
+func (repo *MerchantRepoPostgres) GetByOwner(ctx context.Context, ownerID string) (*model.Merchant, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.GetByOwner")
+	defer span.End()
+
+	query := `SELECT ` + merchantColumns + ` FROM merchants WHERE owner_id = $1`
+
+	var m model.Merchant
+	if err := scanMerchant(repo.db.QueryRow(ctx, query, ownerID), &m); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get merchant by owner", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get merchant by owner: %w", err)
+	}
+	return &m, nil
+}
+
+func (repo *MerchantRepoPostgres) List(ctx context.Context) ([]model.Merchant, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.List")
+	defer span.End()
+
+	query := `SELECT ` + merchantColumns + ` FROM merchants ORDER BY name`
+
+	rows, err := repo.db.Query(ctx, query)
+	if err != nil {
+		repo.logger.Error("failed to list merchants", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("list merchants: %w", err)
+	}
+	defer rows.Close()
+
+	merchants := make([]model.Merchant, 0)
+	for rows.Next() {
+		var m model.Merchant
+		if err := scanMerchant(rows, &m); err != nil {
+			repo.logger.Error("failed to scan merchant", zap.Error(err))
+			span.RecordError(err)
+			return nil, fmt.Errorf("scan merchant: %w", err)
+		}
+		merchants = append(merchants, m)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("merchants_count", len(merchants)))
+	return merchants, nil
+}
+
+// Update сохраняет имя, владельца и правила проверки номеров
+func (repo *MerchantRepoPostgres) Update(ctx context.Context, m *model.Merchant) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.Update")
+	defer span.End()
+
+	query := `
+		UPDATE merchants
+		SET name = $2, owner_id = $3, order_number_validator = NULLIF($4, ''), updated_at = $5
+		WHERE id = $1
+	`
+
+	tag, err := repo.db.Exec(ctx, query, m.ID, m.Name, m.OwnerID, m.OrderNumberValidator, m.UpdatedAt)
+	if err != nil {
+		span.RecordError(err)
+		if conflict := merchantConflict(err); conflict != nil {
+			return conflict
+		}
+		repo.logger.Error("failed to update merchant", zap.Error(err))
+		return fmt.Errorf("merchant update: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrMerchantNotFound
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", m.ID.String()))
+	return nil
+}
+
+// Delete удаляет мерчанта, у его заказов и списаний merchant_id становится пустым
+func (repo *MerchantRepoPostgres) Delete(ctx context.Context, id string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.Delete")
+	defer span.End()
+
+	tag, err := repo.db.Exec(ctx, `DELETE FROM merchants WHERE id = $1`, id)
+	if err != nil {
+		repo.logger.Error("failed to delete merchant", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("merchant delete: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return model.ErrMerchantNotFound
+	}
+
+	repo.logger.Info("merchant deleted", zap.String("merchant.id", id))
+	return nil
+}
+
+// Report сводка по заказам и списаниям мерчанта. Заказы попадают в период
+// по uploaded_at, списания - по processed_at; пустые границы не ограничивают
+func (repo *MerchantRepoPostgres) Report(ctx context.Context, id string,
+	from, to *time.Time) (*model.MerchantReport, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MerchantRepo.Report")
+	defer span.End()
+
+	query := `
+		SELECT o.orders, o.processed, o.returned, o.accrued, a.clawed_back, w.withdrawals, w.withdrawn
+		FROM (
+			SELECT
+				COUNT(*) AS orders,
+				COUNT(*) FILTER (WHERE status = 'PROCESSED') AS processed,
+				COUNT(*) FILTER (WHERE status = 'RETURNED') AS returned,
+				COALESCE(SUM(accrual), 0) AS accrued
+			FROM orders
+			WHERE merchant_id = $1
+				AND ($2::timestamptz IS NULL OR uploaded_at >= $2)
+				AND ($3::timestamptz IS NULL OR uploaded_at < $3)
+		) o, (
+			SELECT COALESCE(-SUM(ba.amount), 0) AS clawed_back
+			FROM balance_adjustments ba
+			JOIN orders ord ON ord.number = ba.order_number
+			WHERE ord.merchant_id = $1
+				AND ($2::timestamptz IS NULL OR ord.uploaded_at >= $2)
+				AND ($3::timestamptz IS NULL OR ord.uploaded_at < $3)
+		) a, (
+			SELECT COUNT(*) AS withdrawals, COALESCE(SUM(amount), 0) AS withdrawn
+			FROM withdrawals
+			WHERE merchant_id = $1
+				AND ($2::timestamptz IS NULL OR processed_at >= $2)
+				AND ($3::timestamptz IS NULL OR processed_at < $3)
+		) w
+	`
+
+	var r model.MerchantReport
+	err := repo.db.QueryRow(ctx, query, id, from, to).Scan(&r.Orders, &r.ProcessedOrders,
+		&r.ReturnedOrders, &r.Accrued, &r.ClawedBack, &r.Withdrawals, &r.Withdrawn)
+	if err != nil {
+		repo.logger.Error("failed to build merchant report", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("merchant report: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id), attribute.Int("orders_count", r.Orders))
+	return &r, nil
+}
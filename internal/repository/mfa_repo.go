@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type MFARepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewMFARepoPostgres(db DBExecutor, logger *zap.Logger) *MFARepoPostgres {
+	return &MFARepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "mfa")),
+	}
+}
+
+// Get возвращает настройки TOTP или nil, если пользователь их не заводил
+func (repo *MFARepoPostgres) Get(ctx context.Context, userID string) (*model.UserMFA, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.Get")
+	defer span.End()
+
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+	`
+	return repo.get(ctx, span, query, userID)
+}
+
+// GetForUpdate то же, что Get, но блокирует строку до конца транзакции,
+// чтобы один и тот же код нельзя было использовать параллельными запросами
+func (repo *MFARepoPostgres) GetForUpdate(ctx context.Context, userID string) (*model.UserMFA, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.GetForUpdate")
+	defer span.End()
+
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = $1
+		FOR UPDATE
+	`
+	return repo.get(ctx, span, query, userID)
+}
+
+func (repo *MFARepoPostgres) get(ctx context.Context, span trace.Span,
+	query, userID string) (*model.UserMFA, error) {
+	var m model.UserMFA
+	err := repo.db.QueryRow(ctx, query, userID).Scan(&m.UserID, &m.Secret, &m.EnabledAt,
+		&m.LastUsedStep, &m.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get user mfa", zap.String("user_id", userID), zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get user mfa: %w", err)
+	}
+	return &m, nil
+}
+
+// SavePending сохраняет новый, еще не подтвержденный секрет.
+// Уже включенную 2FA не перезаписывает
+func (repo *MFARepoPostgres) SavePending(ctx context.Context, mfa *model.UserMFA) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.SavePending")
+	defer span.End()
+
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled_at, last_used_step, created_at)
+		VALUES ($1, $2, NULL, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret,
+		    last_used_step = 0,
+		    created_at = EXCLUDED.created_at
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	tag, err := repo.db.Exec(ctx, query, mfa.UserID, mfa.Secret, mfa.CreatedAt)
+	if err != nil {
+		repo.logger.Error("failed to save user mfa", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("save user mfa: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(model.ErrMFAAlreadyEnabled)
+		return model.ErrMFAAlreadyEnabled
+	}
+
+	span.SetAttributes(attribute.String("user.id", mfa.UserID.String()))
+	return nil
+}
+
+// Enable включает 2FA и запоминает шаг кода, которым она подтверждена
+func (repo *MFARepoPostgres) Enable(ctx context.Context, userID string, step int64) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.Enable")
+	defer span.End()
+
+	query := `
+		UPDATE user_mfa
+		SET enabled_at = NOW(), last_used_step = $2
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`
+
+	tag, err := repo.db.Exec(ctx, query, userID, step)
+	if err != nil {
+		repo.logger.Error("failed to enable user mfa", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("enable user mfa: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(model.ErrMFANotEnrolled)
+		return model.ErrMFANotEnrolled
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	repo.logger.Info("mfa enabled", zap.String("user_id", userID))
+	return nil
+}
+
+func (repo *MFARepoPostgres) UpdateLastUsedStep(ctx context.Context, userID string, step int64) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.UpdateLastUsedStep")
+	defer span.End()
+
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1`
+
+	if _, err := repo.db.Exec(ctx, query, userID, step); err != nil {
+		repo.logger.Error("failed to update last used step", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("update last used step: %w", err)
+	}
+	return nil
+}
+
+// Delete отключает 2FA вместе с кодами восстановления
+func (repo *MFARepoPostgres) Delete(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.Delete")
+	defer span.End()
+
+	if _, err := repo.db.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		repo.logger.Error("failed to delete recovery codes", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	tag, err := repo.db.Exec(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID)
+	if err != nil {
+		repo.logger.Error("failed to delete user mfa", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete user mfa: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(model.ErrMFANotEnabled)
+		return model.ErrMFANotEnabled
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	repo.logger.Info("mfa disabled", zap.String("user_id", userID))
+	return nil
+}
+
+// ReplaceRecoveryCodes заменяет коды восстановления пользователя новыми хешами
+func (repo *MFARepoPostgres) ReplaceRecoveryCodes(ctx context.Context, userID string, hashes []string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.ReplaceRecoveryCodes")
+	defer span.End()
+
+	if _, err := repo.db.Exec(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		repo.logger.Error("failed to delete recovery codes", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (code_hash, user_id)
+		SELECT unnest($2::text[]), $1
+	`
+	if _, err := repo.db.Exec(ctx, query, userID, hashes); err != nil {
+		repo.logger.Error("failed to insert recovery codes", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("insert recovery codes: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("mfa.recovery_codes", len(hashes)))
+	return nil
+}
+
+// UseRecoveryCode помечает код использованным. false - код не найден или уже использован
+func (repo *MFARepoPostgres) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "MFARepo.UseRecoveryCode")
+	defer span.End()
+
+	query := `
+		UPDATE mfa_recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	tag, err := repo.db.Exec(ctx, query, userID, hash)
+	if err != nil {
+		repo.logger.Error("failed to use recovery code", zap.Error(err))
+		span.RecordError(err)
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
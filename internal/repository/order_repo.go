@@ -3,7 +3,10 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -26,13 +29,19 @@ func (repo *OrderRepoPostgres) Create(ctx context.Context, order *model.Order) e
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.Create")
 	defer span.End()
 
+	// первая запись истории пишется тем же запросом, что и заказ
 	query := `
-	INSERT INTO orders (number, user_id, status, accrual, uploaded_at) 
-	VALUES ($1, $2, $3, $4, $5)
+	WITH o AS (
+		INSERT INTO orders (number, user_id, status, accrual, uploaded_at, merchant_id) 
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING number, status, accrual, uploaded_at
+	)
+	INSERT INTO order_status_history (order_number, status, accrual, changed_at)
+	SELECT number, status, accrual, COALESCE(uploaded_at, NOW()) FROM o
 	`
 
 	_, err := repo.db.Exec(ctx, query, order.Number, order.UserID.String(),
-		order.Status, order.Accrual, order.UploadedAt)
+		order.Status, order.Accrual, order.UploadedAt, order.MerchantID)
 	if err != nil {
 		span.RecordError(err)
 		repo.logger.Error("can't exec query", zap.Error(err))
@@ -44,13 +53,125 @@ func (repo *OrderRepoPostgres) Create(ctx context.Context, order *model.Order) e
 	return nil
 }
 
+// CreateBatch вставляет заказы одним запросом вместе с первыми записями истории.
+// Уже существующие номера пропускаются, возвращаются номера вставленных заказов
+func (repo *OrderRepoPostgres) CreateBatch(ctx context.Context, orders []model.Order) ([]string, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.CreateBatch")
+	defer span.End()
+
+	numbers := make([]string, 0, len(orders))
+	userIDs := make([]string, 0, len(orders))
+	statuses := make([]string, 0, len(orders))
+	accruals := make([]string, 0, len(orders))
+	uploadedAt := make([]time.Time, 0, len(orders))
+	merchantIDs := make([]*string, 0, len(orders))
+	for _, o := range orders {
+		numbers = append(numbers, o.Number)
+		userIDs = append(userIDs, o.UserID.String())
+		statuses = append(statuses, string(o.Status))
+		accruals = append(accruals, o.Accrual.String())
+		uploadedAt = append(uploadedAt, o.UploadedAt)
+		var merchantID *string
+		if o.MerchantID != nil {
+			id := o.MerchantID.String()
+			merchantID = &id
+		}
+		merchantIDs = append(merchantIDs, merchantID)
+	}
+
+	query := `
+	WITH o AS (
+		INSERT INTO orders (number, user_id, status, accrual, uploaded_at, merchant_id)
+		SELECT n, u::uuid, s::order_status, a, t, m::uuid
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::numeric[], $5::timestamptz[], $6::text[])
+			AS b(n, u, s, a, t, m)
+		ON CONFLICT (number) DO NOTHING
+		RETURNING number, status, accrual, uploaded_at
+	), h AS (
+		INSERT INTO order_status_history (order_number, status, accrual, changed_at)
+		SELECT number, status, accrual, COALESCE(uploaded_at, NOW()) FROM o
+	)
+	SELECT number FROM o
+	`
+
+	rows, err := repo.db.Query(ctx, query, numbers, userIDs, statuses, accruals, uploadedAt, merchantIDs)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("query exec error", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("[db.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	created := make([]string, 0, len(orders))
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			span.RecordError(err)
+			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
+			return nil, fmt.Errorf("[rows.Scan]: %w", err)
+		}
+		created = append(created, number)
+	}
+
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("error occured while reading rows", zap.Error(err))
+		return nil, fmt.Errorf("[rows.Err]: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("orders_count", len(orders)), attribute.Int("created_count", len(created)))
+	repo.logger.Info("orders batch created", zap.Int("created_count", len(created)))
+	return created, nil
+}
+
+// GetOwners возвращает владельцев заказов с указанными номерами, отсутствующих в ответе нет
+func (repo *OrderRepoPostgres) GetOwners(ctx context.Context, numbers []string) (map[string]uuid.UUID, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetOwners")
+	defer span.End()
+
+	query := `
+	SELECT number, user_id
+	FROM orders
+	WHERE number = ANY($1)
+	`
+
+	rows, err := repo.db.Query(ctx, query, numbers)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("query exec error", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("[db.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	owners := make(map[string]uuid.UUID, len(numbers))
+	for rows.Next() {
+		var number string
+		var userID uuid.UUID
+		if err := rows.Scan(&number, &userID); err != nil {
+			span.RecordError(err)
+			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
+			return nil, fmt.Errorf("[rows.Scan]: %w", err)
+		}
+		owners[number] = userID
+	}
+
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("error occured while reading rows", zap.Error(err))
+		return nil, fmt.Errorf("[rows.Err]: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("orders_count", len(owners)))
+	return owners, nil
+}
+
 func (repo *OrderRepoPostgres) GetByNumber(ctx context.Context,
 	orderNumber string) (*model.Order, error) {
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetByNumber")
 	defer span.End()
 
 	query := `
-	SELECT number, user_id, status, accrual, uploaded_at
+	SELECT number, user_id, status, accrual, uploaded_at, merchant_id
 	FROM orders
 	WHERE number = $1
 	`
@@ -62,6 +183,7 @@ func (repo *OrderRepoPostgres) GetByNumber(ctx context.Context,
 		&order.Status,
 		&order.Accrual,
 		&order.UploadedAt,
+		&order.MerchantID,
 	)
 	if err != nil {
 		span.RecordError(err)
@@ -74,13 +196,46 @@ func (repo *OrderRepoPostgres) GetByNumber(ctx context.Context,
 	return &order, nil
 }
 
+// GetByNumberForUpdate возвращает заказ и блокирует его строку до конца
+// транзакции, чтобы статус не изменился, пока по нему меняются связанные данные
+func (repo *OrderRepoPostgres) GetByNumberForUpdate(ctx context.Context,
+	orderNumber string) (*model.Order, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetByNumberForUpdate")
+	defer span.End()
+
+	query := `
+	SELECT number, user_id, status, accrual, uploaded_at, merchant_id
+	FROM orders
+	WHERE number = $1
+	FOR UPDATE
+	`
+
+	var order model.Order
+	err := repo.db.QueryRow(ctx, query, orderNumber).Scan(
+		&order.Number,
+		&order.UserID,
+		&order.Status,
+		&order.Accrual,
+		&order.UploadedAt,
+		&order.MerchantID,
+	)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("can't scan row", zap.Error(err), zap.String("query", query))
+		return nil, fmt.Errorf("[db.QueryRow]: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("order_number", order.Number))
+	return &order, nil
+}
+
 func (repo *OrderRepoPostgres) GetAll(ctx context.Context,
 	userID string) ([]model.Order, error) {
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetAll")
 	defer span.End()
 
 	query := `
-	SELECT number, user_id, status, accrual, uploaded_at
+	SELECT number, user_id, status, accrual, uploaded_at, merchant_id
 	FROM orders
 	WHERE user_id = $1
 	ORDER BY uploaded_at DESC
@@ -103,6 +258,7 @@ func (repo *OrderRepoPostgres) GetAll(ctx context.Context,
 			&order.Status,
 			&order.Accrual,
 			&order.UploadedAt,
+			&order.MerchantID,
 		)
 		if err != nil {
 			span.RecordError(err)
@@ -123,14 +279,137 @@ func (repo *OrderRepoPostgres) GetAll(ctx context.Context,
 	return orders, nil
 }
 
-func (repo *OrderRepoPostgres) GetAllPending(ctx context.Context) ([]string, error) {
+// List возвращает страницу заказов пользователя по фильтрам запроса
+func (repo *OrderRepoPostgres) List(ctx context.Context, userID string,
+	q model.OrderListQuery) ([]model.Order, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.List")
+	defer span.End()
+
+	where, args := orderFilter(userID, q)
+
+	// ключ сортировки и направление берем только из белого списка
+	key, dir, cmp := "uploaded_at", "DESC", "<"
+	switch q.Sort {
+	case model.OrderSortUploadedAsc:
+		dir, cmp = "ASC", ">"
+	case model.OrderSortAccrualDesc:
+		key = "COALESCE(accrual, 0)"
+	case model.OrderSortAccrualAsc:
+		key, dir, cmp = "COALESCE(accrual, 0)", "ASC", ">"
+	}
+
+	if q.After != nil {
+		var value any = q.After.UploadedAt
+		if q.Sort == model.OrderSortAccrualDesc || q.Sort == model.OrderSortAccrualAsc {
+			value = q.After.Accrual
+		}
+		args = append(args, value, q.After.Number)
+		where = append(where, fmt.Sprintf("(%s, number) %s ($%d, $%d)", key, cmp, len(args)-1, len(args)))
+	}
+
+	args = append(args, q.Limit)
+	query := fmt.Sprintf(`
+	SELECT number, user_id, status, accrual, uploaded_at, merchant_id
+	FROM orders
+	WHERE %s
+	ORDER BY %s %s, number %s
+	LIMIT $%d
+	`, strings.Join(where, " AND "), key, dir, dir, len(args))
+
+	rows, err := repo.db.Query(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("query exec error", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("[db.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	orders := make([]model.Order, 0, q.Limit)
+	for rows.Next() {
+		var order model.Order
+		err := rows.Scan(
+			&order.Number,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+			&order.MerchantID,
+		)
+		if err != nil {
+			span.RecordError(err)
+			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
+			return nil, fmt.Errorf("[rows.Scan]: %w", err)
+		}
+		orders = append(orders, order)
+	}
+
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("error occured while reading rows", zap.Error(err))
+		return nil, fmt.Errorf("[rows.Err]: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("orders_count", len(orders)))
+	return orders, nil
+}
+
+// Count число заказов пользователя по фильтрам запроса без учета страницы
+func (repo *OrderRepoPostgres) Count(ctx context.Context, userID string,
+	q model.OrderListQuery) (int, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.Count")
+	defer span.End()
+
+	where, args := orderFilter(userID, q)
+	query := "SELECT COUNT(*) FROM orders WHERE " + strings.Join(where, " AND ")
+
+	var count int
+	if err := repo.db.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("can't count orders", zap.String("query", query), zap.Error(err))
+		return 0, fmt.Errorf("[db.QueryRow]: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("orders_count", count))
+	return count, nil
+}
+
+// orderFilter условия WHERE и их аргументы для фильтров списка заказов
+func orderFilter(userID string, q model.OrderListQuery) ([]string, []any) {
+	where := []string{"user_id = $1"}
+	args := []any{userID}
+
+	if len(q.Statuses) > 0 {
+		statuses := make([]string, 0, len(q.Statuses))
+		for _, s := range q.Statuses {
+			statuses = append(statuses, string(s))
+		}
+		args = append(args, statuses)
+		where = append(where, fmt.Sprintf("status::text = ANY($%d)", len(args)))
+	}
+	if q.UploadedFrom != nil {
+		args = append(args, *q.UploadedFrom)
+		where = append(where, fmt.Sprintf("uploaded_at >= $%d", len(args)))
+	}
+	if q.UploadedTo != nil {
+		args = append(args, *q.UploadedTo)
+		where = append(where, fmt.Sprintf("uploaded_at < $%d", len(args)))
+	}
+	if q.MerchantID != nil {
+		args = append(args, *q.MerchantID)
+		where = append(where, fmt.Sprintf("merchant_id = $%d", len(args)))
+	}
+	return where, args
+}
+
+// GetAllPending возвращает заказы, которые еще ждут расчета начисления
+func (repo *OrderRepoPostgres) GetAllPending(ctx context.Context) ([]model.Order, error) {
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetAllPending")
 	defer span.End()
 
 	query := `
-	SELECT number
+	SELECT number, user_id, status, accrual, uploaded_at, merchant_id
 	FROM orders
-	WHERE status NOT IN ('INVALID', 'PROCESSED')
+	WHERE status IN ('NEW', 'REGISTERED', 'PROCESSING')
 	ORDER BY uploaded_at
 	`
 
@@ -142,10 +421,17 @@ func (repo *OrderRepoPostgres) GetAllPending(ctx context.Context) ([]string, err
 	}
 	defer rows.Close()
 
-	orders := make([]string, 0)
+	orders := make([]model.Order, 0)
 	for rows.Next() {
-		var order string
-		err := rows.Scan(&order)
+		var order model.Order
+		err := rows.Scan(
+			&order.Number,
+			&order.UserID,
+			&order.Status,
+			&order.Accrual,
+			&order.UploadedAt,
+			&order.MerchantID,
+		)
 		if err != nil {
 			span.RecordError(err)
 			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
@@ -164,20 +450,48 @@ func (repo *OrderRepoPostgres) GetAllPending(ctx context.Context) ([]string, err
 	return orders, nil
 }
 
+// Update меняет статус и начисление заказа. Строка обновляется, только если
+// текущий статус допускает переход, иначе возвращается ErrInvalidOrderTransition
 func (repo *OrderRepoPostgres) Update(ctx context.Context, order model.Order) error {
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.Update")
 	defer span.End()
 
+	allowed := make([]string, 0)
+	for _, s := range order.Status.AllowedFrom() {
+		allowed = append(allowed, string(s))
+	}
+
+	// в историю попадает только реальное изменение статуса или начисления,
+	// prev видит строку до обновления, так как CTE выполняются на одном снимке
 	query := `
-	UPDATE orders SET status = $1, accrual = $2
-	WHERE number = $3
+	WITH prev AS (
+		SELECT number, status, accrual FROM orders
+		WHERE number = $3
+		FOR UPDATE
+	), upd AS (
+		UPDATE orders SET status = $1, accrual = $2
+		WHERE number = $3 AND status::text = ANY($4)
+		RETURNING number, status, accrual
+	), hist AS (
+		INSERT INTO order_status_history (order_number, status, accrual)
+		SELECT upd.number, upd.status, upd.accrual
+		FROM upd JOIN prev ON prev.number = upd.number
+		WHERE upd.status IS DISTINCT FROM prev.status
+			OR upd.accrual IS DISTINCT FROM prev.accrual
+	)
+	SELECT COUNT(*) FROM upd
 	`
 
-	_, err := repo.db.Exec(ctx, query, order.Status, order.Accrual, order.Number)
+	var updated int
+	err := repo.db.QueryRow(ctx, query, order.Status, order.Accrual, order.Number, allowed).Scan(&updated)
 	if err != nil {
 		span.RecordError(err)
 		repo.logger.Error("error while excuting query", zap.String("query", query), zap.Error(err))
-		return fmt.Errorf("[db.Exec]: %w", err)
+		return fmt.Errorf("[db.QueryRow]: %w", err)
+	}
+	if updated == 0 {
+		span.RecordError(model.ErrInvalidOrderTransition)
+		return model.ErrInvalidOrderTransition
 	}
 
 	span.SetAttributes(attribute.String("order_number", order.Number))
@@ -185,6 +499,48 @@ func (repo *OrderRepoPostgres) Update(ctx context.Context, order model.Order) er
 	return nil
 }
 
+// GetHistory возвращает историю заказа от загрузки к текущему состоянию
+func (repo *OrderRepoPostgres) GetHistory(ctx context.Context,
+	orderNumber string) ([]model.OrderStatusChange, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.GetHistory")
+	defer span.End()
+
+	query := `
+	SELECT status, COALESCE(accrual, 0), changed_at
+	FROM order_status_history
+	WHERE order_number = $1
+	ORDER BY changed_at, id
+	`
+
+	rows, err := repo.db.Query(ctx, query, orderNumber)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("query exec error", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("[db.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]model.OrderStatusChange, 0)
+	for rows.Next() {
+		var change model.OrderStatusChange
+		if err := rows.Scan(&change.Status, &change.Accrual, &change.ChangedAt); err != nil {
+			span.RecordError(err)
+			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
+			return nil, fmt.Errorf("[rows.Scan]: %w", err)
+		}
+		history = append(history, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("error occured while reading rows", zap.Error(err))
+		return nil, fmt.Errorf("[rows.Err]: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("order_number", orderNumber), attribute.Int("history_count", len(history)))
+	return history, nil
+}
+
 func (repo *OrderRepoPostgres) Delete(ctx context.Context, orderNumber string) error {
 	ctx, span := otel.Tracer("repository").Start(ctx, "OrderRepo.Delete")
 	defer span.End()
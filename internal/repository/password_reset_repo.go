@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type PasswordResetRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewPasswordResetRepoPostgres(db DBExecutor, logger *zap.Logger) *PasswordResetRepoPostgres {
+	return &PasswordResetRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "password_reset")),
+	}
+}
+
+func (repo *PasswordResetRepoPostgres) Create(ctx context.Context, token *model.PasswordResetToken) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "PasswordResetRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := repo.db.Exec(ctx, query, token.TokenHash, token.UserID, token.CreatedAt, token.ExpiresAt)
+	if err != nil {
+		repo.logger.Error("failed to create password reset token", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("password reset token create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", token.UserID.String()))
+	return nil
+}
+
+// LastCreatedAt время выдачи последнего токена пользователя или nil
+func (repo *PasswordResetRepoPostgres) LastCreatedAt(ctx context.Context, userID string) (*time.Time, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "PasswordResetRepo.LastCreatedAt")
+	defer span.End()
+
+	query := `SELECT MAX(created_at) FROM password_reset_tokens WHERE user_id = $1`
+
+	var last *time.Time
+	if err := repo.db.QueryRow(ctx, query, userID).Scan(&last); err != nil {
+		repo.logger.Error("failed to get last password reset token", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get last password reset token: %w", err)
+	}
+	return last, nil
+}
+
+// Consume атомарно помечает действующий токен использованным и возвращает его.
+// nil - токен не найден, истек или уже использован
+func (repo *PasswordResetRepoPostgres) Consume(ctx context.Context, tokenHash string) (*model.PasswordResetToken, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "PasswordResetRepo.Consume")
+	defer span.End()
+
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING token_hash, user_id, created_at, expires_at, used_at
+	`
+
+	var t model.PasswordResetToken
+	err := repo.db.QueryRow(ctx, query, tokenHash).Scan(&t.TokenHash, &t.UserID, &t.CreatedAt,
+		&t.ExpiresAt, &t.UsedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to consume password reset token", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("consume password reset token: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", t.UserID.String()))
+	return &t, nil
+}
+
+// InvalidateAll помечает использованными все неиспользованные токены пользователя
+func (repo *PasswordResetRepoPostgres) InvalidateAll(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "PasswordResetRepo.InvalidateAll")
+	defer span.End()
+
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	if _, err := repo.db.Exec(ctx, query, userID); err != nil {
+		repo.logger.Error("failed to invalidate password reset tokens", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("invalidate password reset tokens: %w", err)
+	}
+	return nil
+}
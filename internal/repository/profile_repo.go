@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// код ошибки postgres unique_violation
+const pgUniqueViolation = "23505"
+
+type ProfileRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewProfileRepoPostgres(db DBExecutor, logger *zap.Logger) *ProfileRepoPostgres {
+	return &ProfileRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "profile")),
+	}
+}
+
+// Get возвращает профиль или nil, если пользователь его еще не заполнял
+func (repo *ProfileRepoPostgres) Get(ctx context.Context, userID string) (*model.UserProfile, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ProfileRepo.Get")
+	defer span.End()
+
+	query := `
+		SELECT user_id, display_name, email, phone, birthday, marketing_email,
+			marketing_sms, consents_updated_at, created_at, updated_at
+		FROM user_profiles
+		WHERE user_id = $1
+	`
+
+	var p model.UserProfile
+	err := repo.db.QueryRow(ctx, query, userID).Scan(&p.UserID, &p.DisplayName, &p.Email,
+		&p.Phone, &p.Birthday, &p.MarketingEmail, &p.MarketingSMS, &p.ConsentsUpdatedAt,
+		&p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get profile", zap.String("user_id", userID), zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get profile: %w", err)
+	}
+	return &p, nil
+}
+
+// Upsert создает или целиком перезаписывает профиль.
+// Занятый другим пользователем email - model.ErrEmailTaken
+func (repo *ProfileRepoPostgres) Upsert(ctx context.Context, p *model.UserProfile) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ProfileRepo.Upsert")
+	defer span.End()
+
+	query := `
+		INSERT INTO user_profiles (user_id, display_name, email, phone, birthday,
+			marketing_email, marketing_sms, consents_updated_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (user_id) DO UPDATE
+		SET display_name = EXCLUDED.display_name,
+		    email = EXCLUDED.email,
+		    phone = EXCLUDED.phone,
+		    birthday = EXCLUDED.birthday,
+		    marketing_email = EXCLUDED.marketing_email,
+		    marketing_sms = EXCLUDED.marketing_sms,
+		    consents_updated_at = EXCLUDED.consents_updated_at,
+		    updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := repo.db.Exec(ctx, query, p.UserID, p.DisplayName, p.Email, p.Phone, p.Birthday,
+		p.MarketingEmail, p.MarketingSMS, p.ConsentsUpdatedAt, p.CreatedAt, p.UpdatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			span.RecordError(model.ErrEmailTaken)
+			return model.ErrEmailTaken
+		}
+		repo.logger.Error("failed to save profile", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("save profile: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", p.UserID.String()))
+	return nil
+}
+
+func (repo *ProfileRepoPostgres) Delete(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ProfileRepo.Delete")
+	defer span.End()
+
+	if _, err := repo.db.Exec(ctx, `DELETE FROM user_profiles WHERE user_id = $1`, userID); err != nil {
+		repo.logger.Error("failed to delete profile", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete profile: %w", err)
+	}
+	return nil
+}
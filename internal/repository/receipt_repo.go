@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type ReceiptRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewReceiptRepoPostgres(db DBExecutor, logger *zap.Logger) *ReceiptRepoPostgres {
+	return &ReceiptRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "receipt")),
+	}
+}
+
+// Save сохраняет чек заказа, повторная отправка целиком заменяет прежний чек.
+// Вызывать в транзакции: чек и позиции пишутся разными запросами
+func (repo *ReceiptRepoPostgres) Save(ctx context.Context, r *model.Receipt) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ReceiptRepo.Save")
+	defer span.End()
+
+	query := `
+	INSERT INTO receipts (order_number, merchant_id, total, currency, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (order_number) DO UPDATE
+	SET merchant_id = EXCLUDED.merchant_id, total = EXCLUDED.total,
+		currency = EXCLUDED.currency, updated_at = EXCLUDED.updated_at
+	RETURNING created_at
+	`
+
+	err := repo.db.QueryRow(ctx, query, r.OrderNumber, r.MerchantID, r.Total, r.Currency,
+		r.CreatedAt, r.UpdatedAt).Scan(&r.CreatedAt)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("can't save receipt", zap.Error(err))
+		return fmt.Errorf("[db.QueryRow]: %w", err)
+	}
+
+	_, err = repo.db.Exec(ctx, `DELETE FROM receipt_items WHERE order_number = $1`, r.OrderNumber)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("can't delete receipt items", zap.Error(err))
+		return fmt.Errorf("[db.Exec]: %w", err)
+	}
+
+	skus := make([]string, 0, len(r.Items))
+	categories := make([]*string, 0, len(r.Items))
+	quantities := make([]string, 0, len(r.Items))
+	prices := make([]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		skus = append(skus, item.SKU)
+		var category *string
+		if item.Category != "" {
+			c := item.Category
+			category = &c
+		}
+		categories = append(categories, category)
+		quantities = append(quantities, item.Quantity.String())
+		prices = append(prices, item.Price.String())
+	}
+
+	query = `
+	INSERT INTO receipt_items (order_number, line_no, sku, category, quantity, price)
+	SELECT $1, i.line_no, i.sku, i.category, i.quantity, i.price
+	FROM unnest($2::text[], $3::text[], $4::numeric[], $5::numeric[])
+		WITH ORDINALITY AS i(sku, category, quantity, price, line_no)
+	`
+
+	_, err = repo.db.Exec(ctx, query, r.OrderNumber, skus, categories, quantities, prices)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("can't insert receipt items", zap.Error(err))
+		return fmt.Errorf("[db.Exec]: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("order_number", r.OrderNumber), attribute.Int("items_count", len(r.Items)))
+	repo.logger.Info("receipt saved", zap.String("order_number", r.OrderNumber))
+	return nil
+}
+
+// Get возвращает чек заказа с позициями или nil, если чека нет
+func (repo *ReceiptRepoPostgres) Get(ctx context.Context, orderNumber string) (*model.Receipt, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "ReceiptRepo.Get")
+	defer span.End()
+
+	query := `
+	SELECT order_number, merchant_id, total, currency, created_at, updated_at
+	FROM receipts
+	WHERE order_number = $1
+	`
+
+	var r model.Receipt
+	err := repo.db.QueryRow(ctx, query, orderNumber).Scan(&r.OrderNumber, &r.MerchantID, &r.Total,
+		&r.Currency, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		span.RecordError(err)
+		repo.logger.Error("can't get receipt", zap.Error(err))
+		return nil, fmt.Errorf("[db.QueryRow]: %w", err)
+	}
+
+	query = `
+	SELECT sku, COALESCE(category, ''), quantity, price
+	FROM receipt_items
+	WHERE order_number = $1
+	ORDER BY line_no
+	`
+
+	rows, err := repo.db.Query(ctx, query, orderNumber)
+	if err != nil {
+		span.RecordError(err)
+		repo.logger.Error("query exec error", zap.String("query", query), zap.Error(err))
+		return nil, fmt.Errorf("[db.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	r.Items = make([]model.ReceiptItem, 0)
+	for rows.Next() {
+		var item model.ReceiptItem
+		if err := rows.Scan(&item.SKU, &item.Category, &item.Quantity, &item.Price); err != nil {
+			span.RecordError(err)
+			repo.logger.Error("scan row error", zap.String("query", query), zap.Error(err))
+			return nil, fmt.Errorf("[rows.Scan]: %w", err)
+		}
+		r.Items = append(r.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		span.RecordError(err)
+		repo.logger.Error("error occured while reading rows", zap.Error(err))
+		return nil, fmt.Errorf("[rows.Err]: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("order_number", orderNumber), attribute.Int("items_count", len(r.Items)))
+	return &r, nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type RedemptionCodeRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewRedemptionCodeRepoPostgres(db DBExecutor, logger *zap.Logger) *RedemptionCodeRepoPostgres {
+	return &RedemptionCodeRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "redemption_code")),
+	}
+}
+
+func (repo *RedemptionCodeRepoPostgres) Create(ctx context.Context, code *model.RedemptionCode) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RedemptionCodeRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO redemption_codes (user_id, code_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := repo.db.Exec(ctx, query, code.UserID, code.CodeHash, code.CreatedAt, code.ExpiresAt)
+	if err != nil {
+		repo.logger.Error("failed to create redemption code", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("redemption code create: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", code.UserID.String()))
+	return nil
+}
+
+// Consume атомарно помечает действующий код покупателя использованным.
+// false - код не найден, истек или уже использован
+func (repo *RedemptionCodeRepoPostgres) Consume(ctx context.Context, userID, codeHash string) (bool, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RedemptionCodeRepo.Consume")
+	defer span.End()
+
+	query := `
+		UPDATE redemption_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	tag, err := repo.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		repo.logger.Error("failed to consume redemption code", zap.Error(err))
+		span.RecordError(err)
+		return false, fmt.Errorf("consume redemption code: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	return tag.RowsAffected() > 0, nil
+}
+
+// RegisterFailure учитывает неверный код для действующих кодов покупателя.
+// После maxFailures неудач коды гасятся, чтобы их нельзя было подобрать
+func (repo *RedemptionCodeRepoPostgres) RegisterFailure(ctx context.Context, userID string, maxFailures int) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RedemptionCodeRepo.RegisterFailure")
+	defer span.End()
+
+	query := `
+		UPDATE redemption_codes
+		SET failures = failures + 1,
+			used_at = CASE WHEN failures + 1 >= $2 THEN NOW() END
+		WHERE user_id = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	if _, err := repo.db.Exec(ctx, query, userID, maxFailures); err != nil {
+		repo.logger.Error("failed to register redemption code failure", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("register redemption code failure: %w", err)
+	}
+	return nil
+}
+
+// InvalidateAll помечает использованными все неиспользованные коды покупателя
+func (repo *RedemptionCodeRepoPostgres) InvalidateAll(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RedemptionCodeRepo.InvalidateAll")
+	defer span.End()
+
+	query := `
+		UPDATE redemption_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND used_at IS NULL
+	`
+
+	if _, err := repo.db.Exec(ctx, query, userID); err != nil {
+		repo.logger.Error("failed to invalidate redemption codes", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("invalidate redemption codes: %w", err)
+	}
+	return nil
+}
@@ -43,6 +43,11 @@ func (repos *Repositories) Init(ctx context.Context, dsn string) error {
 	return nil
 }
 
+// Pool возвращает пул соединений для запросов вне транзакции
+func (r *Repositories) Pool() DBExecutor {
+	return r.pgxpool
+}
+
 // Возвращает транзакцию для работыт с БД
 func (r *Repositories) BeginTx(ctx context.Context, isoLevel pgx.TxIsoLevel) (pgx.Tx, error) {
 	return r.pgxpool.BeginTx(ctx, pgx.TxOptions{
@@ -70,3 +75,59 @@ func (repos *Repositories) NewOrderRepo(exec DBExecutor) interfaces.OrderReposit
 func (repos *Repositories) NewBalanceRepo(exec DBExecutor) interfaces.BalanceRepository {
 	return NewBalanceRepoPostgres(exec, repos.logger)
 }
+
+func (repos *Repositories) NewAuditRepo(exec DBExecutor) interfaces.AuditRepository {
+	return NewAuditRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewAuthAttemptRepo(exec DBExecutor) interfaces.AuthAttemptRepository {
+	return NewAuthAttemptRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewMFARepo(exec DBExecutor) interfaces.MFARepository {
+	return NewMFARepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewAPIKeyRepo(exec DBExecutor) interfaces.APIKeyRepository {
+	return NewAPIKeyRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewPasswordResetRepo(exec DBExecutor) interfaces.PasswordResetRepository {
+	return NewPasswordResetRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewRedemptionCodeRepo(exec DBExecutor) interfaces.RedemptionCodeRepository {
+	return NewRedemptionCodeRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewEmailVerificationRepo(exec DBExecutor) interfaces.EmailVerificationRepository {
+	return NewEmailVerificationRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewIdentityRepo(exec DBExecutor) interfaces.IdentityRepository {
+	return NewIdentityRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewExternalLoginStateRepo(exec DBExecutor) interfaces.ExternalLoginStateRepository {
+	return NewExternalLoginStateRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewRevokedTokenRepo(exec DBExecutor) interfaces.RevokedTokenRepository {
+	return NewRevokedTokenRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewProfileRepo(exec DBExecutor) interfaces.ProfileRepository {
+	return NewProfileRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewIdempotencyRepo(exec DBExecutor) interfaces.IdempotencyRepository {
+	return NewIdempotencyRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewMerchantRepo(exec DBExecutor) interfaces.MerchantRepository {
+	return NewMerchantRepoPostgres(exec, repos.logger)
+}
+
+func (repos *Repositories) NewReceiptRepo(exec DBExecutor) interfaces.ReceiptRepository {
+	return NewReceiptRepoPostgres(exec, repos.logger)
+}
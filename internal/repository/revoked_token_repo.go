@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+type RevokedTokenRepoPostgres struct {
+	db     DBExecutor
+	logger *zap.Logger
+}
+
+func NewRevokedTokenRepoPostgres(db DBExecutor, logger *zap.Logger) *RevokedTokenRepoPostgres {
+	return &RevokedTokenRepoPostgres{
+		db:     db,
+		logger: logger.With(zap.String("repo", "revoked_token")),
+	}
+}
+
+// Create отзывает токен. Повторный отзыв не ошибка
+func (repo *RevokedTokenRepoPostgres) Create(ctx context.Context, token *model.RevokedToken) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RevokedTokenRepo.Create")
+	defer span.End()
+
+	query := `
+		INSERT INTO revoked_tokens (kind, value, revoked_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`
+
+	_, err := repo.db.Exec(ctx, query, string(token.Kind), token.Value, token.RevokedAt)
+	if err != nil {
+		repo.logger.Error("failed to revoke token", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("revoke token: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("token.kind", string(token.Kind)))
+	return nil
+}
+
+// GetSince возвращает токены, отозванные начиная с since
+func (repo *RevokedTokenRepoPostgres) GetSince(ctx context.Context, since time.Time) ([]model.RevokedToken, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RevokedTokenRepo.GetSince")
+	defer span.End()
+
+	query := `
+		SELECT kind, value, revoked_at
+		FROM revoked_tokens
+		WHERE revoked_at >= $1
+	`
+
+	rows, err := repo.db.Query(ctx, query, since)
+	if err != nil {
+		repo.logger.Error("failed to get revoked tokens", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get revoked tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]model.RevokedToken, 0)
+	for rows.Next() {
+		var t model.RevokedToken
+		if err := rows.Scan(&t.Kind, &t.Value, &t.RevokedAt); err != nil {
+			repo.logger.Error("failed to scan revoked token", zap.Error(err))
+			span.RecordError(err)
+			return nil, fmt.Errorf("scan revoked token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		repo.logger.Error("rows error", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("revoked_tokens_count", len(tokens)))
+	return tokens, nil
+}
+
+// DeleteBefore удаляет записи, отозванные раньше before
+func (repo *RevokedTokenRepoPostgres) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "RevokedTokenRepo.DeleteBefore")
+	defer span.End()
+
+	tag, err := repo.db.Exec(ctx, `DELETE FROM revoked_tokens WHERE revoked_at < $1`, before)
+	if err != nil {
+		repo.logger.Error("failed to delete revoked tokens", zap.Error(err))
+		span.RecordError(err)
+		return 0, fmt.Errorf("delete revoked tokens: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int64("revoked_tokens_deleted", tag.RowsAffected()))
+	return tag.RowsAffected(), nil
+}
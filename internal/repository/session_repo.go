@@ -28,14 +28,16 @@ func (repo *SessionRepoPostgres) Create(ctx context.Context, session *model.Sess
 	defer span.End()
 
 	query := `
-		INSERT INTO sessions (id, user_id, hashed_refresh_token, auth_at, expire_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO sessions (id, user_id, hashed_refresh_token, user_agent, ip, auth_at, expire_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
 	_, err := repo.db.Exec(ctx, query,
 		session.ID,
 		session.UserID,
 		session.HashedRefreshToken,
+		session.UserAgent,
+		session.IP,
 		session.AuthAt,
 		session.ExpireAt,
 	)
@@ -57,7 +59,7 @@ func (repo *SessionRepoPostgres) GetByRefreshToken(ctx context.Context, token st
 
 	var s model.Session
 	query := `
-		SELECT id, user_id, hashed_refresh_token, auth_at, expire_at
+		SELECT id, user_id, hashed_refresh_token, user_agent, ip, auth_at, expire_at
 		FROM sessions
 		WHERE hashed_refresh_token = $1
 	`
@@ -66,6 +68,8 @@ func (repo *SessionRepoPostgres) GetByRefreshToken(ctx context.Context, token st
 		&s.ID,
 		&s.UserID,
 		&s.HashedRefreshToken,
+		&s.UserAgent,
+		&s.IP,
 		&s.AuthAt,
 		&s.ExpireAt,
 	)
@@ -101,3 +105,204 @@ func (repo *SessionRepoPostgres) Delete(ctx context.Context, sessionID string) e
 	span.SetAttributes(attribute.String("session.id", sessionID))
 	return nil
 }
+
+func (repo *SessionRepoPostgres) GetByID(ctx context.Context, sessionID string) (*model.Session, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.GetByID")
+	defer span.End()
+
+	var s model.Session
+	query := `
+		SELECT id, user_id, hashed_refresh_token, user_agent, ip, auth_at, expire_at
+		FROM sessions
+		WHERE id = $1
+	`
+
+	err := repo.db.QueryRow(ctx, query, sessionID).Scan(
+		&s.ID,
+		&s.UserID,
+		&s.HashedRefreshToken,
+		&s.UserAgent,
+		&s.IP,
+		&s.AuthAt,
+		&s.ExpireAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			repo.logger.Warn("session not found", zap.String("session_id", sessionID))
+			return nil, nil
+		}
+		repo.logger.Error("failed to get session", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("session.id", s.ID.String()))
+	return &s, nil
+}
+
+// GetAllByUserID возвращает все активные сессии пользователя
+func (repo *SessionRepoPostgres) GetAllByUserID(ctx context.Context, userID string) ([]model.Session, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.GetAllByUserID")
+	defer span.End()
+
+	query := `
+		SELECT id, user_id, hashed_refresh_token, user_agent, ip, auth_at, expire_at
+		FROM sessions
+		WHERE user_id = $1 AND expire_at > NOW()
+		ORDER BY auth_at DESC
+	`
+
+	rows, err := repo.db.Query(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("failed to get sessions", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]model.Session, 0)
+	for rows.Next() {
+		var s model.Session
+		err := rows.Scan(
+			&s.ID,
+			&s.UserID,
+			&s.HashedRefreshToken,
+			&s.UserAgent,
+			&s.IP,
+			&s.AuthAt,
+			&s.ExpireAt,
+		)
+		if err != nil {
+			repo.logger.Error("failed to scan session", zap.Error(err))
+			span.RecordError(err)
+			return nil, fmt.Errorf("scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		repo.logger.Error("rows error", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("sessions_count", len(sessions)))
+	return sessions, nil
+}
+
+// DeleteAllByUserID удаляет все сессии пользователя
+func (repo *SessionRepoPostgres) DeleteAllByUserID(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.DeleteAllByUserID")
+	defer span.End()
+
+	query := `DELETE FROM sessions WHERE user_id = $1`
+
+	tag, err := repo.db.Exec(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("failed to delete sessions", zap.String("user_id", userID), zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	repo.logger.Info("all sessions deleted", zap.String("user_id", userID),
+		zap.Int64("deleted", tag.RowsAffected()))
+	span.SetAttributes(attribute.String("user.id", userID))
+	return nil
+}
+
+// DeleteOthers удаляет все сессии пользователя, кроме keepSessionID
+func (repo *SessionRepoPostgres) DeleteOthers(ctx context.Context, userID, keepSessionID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.DeleteOthers")
+	defer span.End()
+
+	query := `DELETE FROM sessions WHERE user_id = $1 AND id <> $2`
+
+	tag, err := repo.db.Exec(ctx, query, userID, keepSessionID)
+	if err != nil {
+		repo.logger.Error("failed to delete sessions", zap.String("user_id", userID), zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	repo.logger.Info("other sessions deleted", zap.String("user_id", userID),
+		zap.Int64("deleted", tag.RowsAffected()))
+	span.SetAttributes(attribute.String("user.id", userID))
+	return nil
+}
+
+// Rotate заменяет хеш refresh токена сессии и сохраняет старый хеш в истории.
+// Обновление выполняется только если в сессии все еще лежит oldHash, поэтому
+// при конкурентной ротации одного и того же токена успешной будет только одна.
+func (repo *SessionRepoPostgres) Rotate(ctx context.Context, sessionID, oldHash, newHash string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.Rotate")
+	defer span.End()
+
+	query := `
+		UPDATE sessions SET hashed_refresh_token = $1
+		WHERE id = $2 AND hashed_refresh_token = $3
+	`
+
+	tag, err := repo.db.Exec(ctx, query, newHash, sessionID, oldHash)
+	if err != nil {
+		repo.logger.Error("failed to rotate refresh token", zap.String("session_id", sessionID), zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("rotate refresh token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(model.ErrSessionNotFound)
+		return model.ErrSessionNotFound
+	}
+
+	historyQuery := `
+		INSERT INTO refresh_token_history (hashed_refresh_token, session_id, rotated_at)
+		VALUES ($1, $2, NOW())
+	`
+
+	_, err = repo.db.Exec(ctx, historyQuery, oldHash, sessionID)
+	if err != nil {
+		repo.logger.Error("failed to save rotated token", zap.String("session_id", sessionID), zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("save rotated token: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("session.id", sessionID))
+	repo.logger.Info("refresh token rotated", zap.String("session_id", sessionID))
+	return nil
+}
+
+// GetByRotatedToken ищет сессию, в которой токен с таким хешем уже был ротирован
+func (repo *SessionRepoPostgres) GetByRotatedToken(ctx context.Context, token string) (*model.Session, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "SessionRepo.GetByRotatedToken")
+	defer span.End()
+
+	var s model.Session
+	query := `
+		SELECT s.id, s.user_id, s.hashed_refresh_token, s.user_agent, s.ip, s.auth_at, s.expire_at
+		FROM refresh_token_history h
+		JOIN sessions s ON s.id = h.session_id
+		WHERE h.hashed_refresh_token = $1
+	`
+
+	err := repo.db.QueryRow(ctx, query, token).Scan(
+		&s.ID,
+		&s.UserID,
+		&s.HashedRefreshToken,
+		&s.UserAgent,
+		&s.IP,
+		&s.AuthAt,
+		&s.ExpireAt,
+	)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		repo.logger.Error("failed to get session by rotated token", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("get session: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("session.id", s.ID.String()))
+	return &s, nil
+}
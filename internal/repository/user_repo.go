@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
@@ -28,11 +29,15 @@ func (repo *UserRepoPostgres) Create(ctx context.Context, user *model.User) erro
 	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.Create")
 	defer span.End()
 
-	query := "INSERT INTO users (id, login, password, balance, withdrawn) " +
-		"VALUES ($1, $2, $3, $4, $5)"
+	if user.Role == "" {
+		user.Role = model.RoleCustomer
+	}
+
+	query := "INSERT INTO users (id, login, password, balance, withdrawn, role) " +
+		"VALUES ($1, $2, $3, $4, $5, $6)"
 
 	_, err := repo.db.Exec(ctx, query, user.ID, user.Login, user.Password,
-		user.Balance, user.Withdrawn)
+		user.Balance, user.Withdrawn, string(user.Role))
 
 	if err != nil {
 		repo.logger.Error("can't insert row", zap.Error(err))
@@ -51,11 +56,13 @@ func (repo *UserRepoPostgres) GetByLogin(ctx context.Context, login string) (*mo
 	defer span.End()
 
 	var user model.User
-	query := "SELECT id, login, password, balance, withdrawn " +
+	query := "SELECT id, login, password, balance, withdrawn, role::text, banned_at, deletion_requested_at, " +
+		"email_verified_at " +
 		"FROM users WHERE login=$1"
 
 	err := repo.db.QueryRow(ctx, query, login).Scan(&user.ID, &user.Login, &user.Password,
-		&user.Balance, &user.Withdrawn)
+		&user.Balance, &user.Withdrawn, &user.Role, &user.BannedAt, &user.DeletionRequestedAt,
+		&user.EmailVerifiedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -74,3 +81,212 @@ func (repo *UserRepoPostgres) GetByLogin(ctx context.Context, login string) (*mo
 	repo.logger.Info("succcessfully get user by ID", zap.String("user_id", user.ID.String()))
 	return &user, nil
 }
+
+func (repo *UserRepoPostgres) GetByID(ctx context.Context, userID string) (*model.User, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.GetByID")
+	defer span.End()
+
+	var user model.User
+	query := "SELECT id, login, password, balance, withdrawn, role::text, banned_at, deletion_requested_at, " +
+		"email_verified_at " +
+		"FROM users WHERE id=$1"
+
+	err := repo.db.QueryRow(ctx, query, userID).Scan(&user.ID, &user.Login, &user.Password,
+		&user.Balance, &user.Withdrawn, &user.Role, &user.BannedAt, &user.DeletionRequestedAt,
+		&user.EmailVerifiedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			repo.logger.Error("no such user", zap.Error(err))
+			span.RecordError(err)
+			return nil, ErrNoUser
+		}
+
+		repo.logger.Error("[pgxpool.Conn.QueryRow]", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("[pgxpool.Conn.QueryRow]: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", user.ID.String()))
+	return &user, nil
+}
+
+// UpdatePassword сохраняет новый хеш пароля
+func (repo *UserRepoPostgres) UpdatePassword(ctx context.Context, userID string, hash string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.UpdatePassword")
+	defer span.End()
+
+	query := "UPDATE users SET password=$1 WHERE id=$2"
+
+	tag, err := repo.db.Exec(ctx, query, hash, userID)
+	if err != nil {
+		repo.logger.Error("can't update password", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	repo.logger.Info("password updated", zap.String("user_id", userID))
+	return nil
+}
+
+// UpdateRole меняет роль пользователя
+func (repo *UserRepoPostgres) UpdateRole(ctx context.Context, userID string, role model.Role) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.UpdateRole")
+	defer span.End()
+
+	query := "UPDATE users SET role=$1 WHERE id=$2"
+
+	tag, err := repo.db.Exec(ctx, query, string(role), userID)
+	if err != nil {
+		repo.logger.Error("can't update role", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.String("user.role", string(role)))
+	repo.logger.Info("role updated", zap.String("user_id", userID), zap.String("role", string(role)))
+	return nil
+}
+
+// SetBanned блокирует (banned=true) или разблокирует пользователя
+func (repo *UserRepoPostgres) SetBanned(ctx context.Context, userID string, banned bool) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.SetBanned")
+	defer span.End()
+
+	query := "UPDATE users SET banned_at = CASE WHEN $1 THEN NOW() END WHERE id=$2"
+
+	tag, err := repo.db.Exec(ctx, query, banned, userID)
+	if err != nil {
+		repo.logger.Error("can't update banned_at", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.Bool("user.banned", banned))
+	repo.logger.Info("user ban updated", zap.String("user_id", userID), zap.Bool("banned", banned))
+	return nil
+}
+
+// SetEmailVerified отмечает email подтвержденным (verified=true) или сбрасывает отметку
+func (repo *UserRepoPostgres) SetEmailVerified(ctx context.Context, userID string, verified bool) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.SetEmailVerified")
+	defer span.End()
+
+	query := "UPDATE users SET email_verified_at = CASE WHEN $1 THEN NOW() END WHERE id=$2"
+
+	tag, err := repo.db.Exec(ctx, query, verified, userID)
+	if err != nil {
+		repo.logger.Error("can't update email_verified_at", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.Bool("user.email_verified", verified))
+	return nil
+}
+
+// SetDeletionRequested планирует (requested=true) или отменяет удаление аккаунта
+func (repo *UserRepoPostgres) SetDeletionRequested(ctx context.Context, userID string, requested bool) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.SetDeletionRequested")
+	defer span.End()
+
+	query := "UPDATE users SET deletion_requested_at = CASE WHEN $1 THEN NOW() END " +
+		"WHERE id=$2 AND anonymized_at IS NULL"
+
+	tag, err := repo.db.Exec(ctx, query, requested, userID)
+	if err != nil {
+		repo.logger.Error("can't update deletion_requested_at", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.Bool("user.deletion_requested", requested))
+	return nil
+}
+
+// LockDueForDeletion возвращает до limit пользователей, запросивших удаление
+// раньше before, и блокирует их строки до конца транзакции. Строки, занятые
+// другой репликой, пропускаются
+func (repo *UserRepoPostgres) LockDueForDeletion(ctx context.Context, before time.Time,
+	limit int) ([]model.User, error) {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.LockDueForDeletion")
+	defer span.End()
+
+	query := "SELECT id, login, password, balance, withdrawn, role::text, banned_at, deletion_requested_at, " +
+		"email_verified_at " +
+		"FROM users WHERE deletion_requested_at < $1 AND anonymized_at IS NULL " +
+		"ORDER BY deletion_requested_at LIMIT $2 FOR UPDATE SKIP LOCKED"
+
+	rows, err := repo.db.Query(ctx, query, before, limit)
+	if err != nil {
+		repo.logger.Error("[pgxpool.Conn.Query]", zap.Error(err))
+		span.RecordError(err)
+		return nil, fmt.Errorf("[pgxpool.Conn.Query]: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]model.User, 0)
+	for rows.Next() {
+		var user model.User
+		if err := rows.Scan(&user.ID, &user.Login, &user.Password, &user.Balance, &user.Withdrawn,
+			&user.Role, &user.BannedAt, &user.DeletionRequestedAt, &user.EmailVerifiedAt); err != nil {
+			repo.logger.Error("can't scan user", zap.Error(err))
+			span.RecordError(err)
+			return nil, fmt.Errorf("scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("rows err: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("users_count", len(users)))
+	return users, nil
+}
+
+// Anonymize заменяет логин на обезличенный и делает вход невозможным.
+// Баланс и списания остаются для бухгалтерии
+func (repo *UserRepoPostgres) Anonymize(ctx context.Context, userID string) error {
+	ctx, span := otel.Tracer("repository").Start(ctx, "UserRepo.Anonymize")
+	defer span.End()
+
+	query := "UPDATE users SET login = 'deleted-' || id::text, password = '', " +
+		"anonymized_at = NOW() WHERE id=$1 AND anonymized_at IS NULL"
+
+	tag, err := repo.db.Exec(ctx, query, userID)
+	if err != nil {
+		repo.logger.Error("can't anonymize user", zap.Error(err))
+		span.RecordError(err)
+		return fmt.Errorf("[pgxpool.Conn.Exec]: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		span.RecordError(ErrNoUser)
+		return ErrNoUser
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	repo.logger.Info("user anonymized", zap.String("user_id", userID))
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"net"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -12,6 +14,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/handlers"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/middleware"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	tokenmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/tokenManager"
 	"go.uber.org/zap"
 )
@@ -21,10 +24,14 @@ type Router struct {
 	logger *zap.Logger
 }
 
-func NewRouter(ctx context.Context, logger *zap.Logger, userHandler *handlers.UserHandler,
-	orderHandler *handlers.OrderHandler, balanceHandler *handlers.BalanceHandler) *Router {
-	// инициализация token manager
-	tm := tokenmanager.NewTokenManager(tokenmanager.NewTokenManagerConfig())
+func NewRouter(ctx context.Context, logger *zap.Logger, tm *tokenmanager.TokenManager,
+	denylist middleware.TokenDenylist, userHandler *handlers.UserHandler,
+	orderHandler *handlers.OrderHandler, balanceHandler *handlers.BalanceHandler,
+	jwksHandler *handlers.JWKSHandler,
+	adminHandler *handlers.AdminHandler, merchantAdminHandler *handlers.MerchantAdminHandler,
+	merchantHandler *handlers.MerchantHandler,
+	partnerHandler *handlers.PartnerHandler, accountHandler *handlers.AccountHandler,
+	apiKeys middleware.APIKeyAuthenticator, idempotency middleware.IdempotencyStore) *Router {
 	// Инициализация gin
 	r := gin.Default()
 
@@ -32,25 +39,102 @@ func NewRouter(ctx context.Context, logger *zap.Logger, userHandler *handlers.Us
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 	r.Use(middleware.LoggerMiddleware(logger))
 	r.Use(middleware.Metrics())
+	r.Use(middleware.CORS(strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",")))
 	api := r.Group("/api/v1")
 
 	// Регистрация маршрутов для user
 	api.POST("/register", userHandler.Register)
 	api.POST("/auth", userHandler.Auth)
-	api.GET("/refresh", userHandler.Refresh)
+	api.POST("/auth/2fa", userHandler.VerifyMFA)
+	// вход через внешние OIDC провайдеры
+	api.GET("/auth/oidc/:provider/login", userHandler.ExternalLogin)
+	api.GET("/auth/oidc/:provider/callback", userHandler.ExternalCallback)
+	api.POST("/auth/oidc/:provider/callback", userHandler.ExternalCallback)
+	// refresh cookie читается только здесь, поэтому X-CSRF-Token требуется только на этом пути
+	api.POST("/refresh", middleware.CSRF(), userHandler.Refresh)
+	// выход в режиме cookie: refresh cookie видна только на этом пути
+	api.DELETE("/refresh", middleware.AuthMiddleware(tm, denylist), middleware.CSRF(), userHandler.Logout)
+	api.POST("/password/forgot", userHandler.ForgotPassword)
+	api.POST("/password/reset", userHandler.ResetPassword)
+	api.POST("/email/verify", userHandler.VerifyEmail)
 
 	auth := api.Group("/user")
-	auth.Use(middleware.AuthMiddleware(tm))
+	auth.Use(middleware.AuthMiddleware(tm, denylist))
+	// повтор по Idempotency-Key только для операций с заказами и баллами
+	idempotent := middleware.Idempotency(idempotency)
+
+	// Регистрация маршрутов по сессиям
+	auth.POST("/logout", userHandler.Logout)
+	auth.GET("/sessions", userHandler.GetSessions)
+	auth.DELETE("/sessions", userHandler.RevokeOtherSessions)
+	auth.DELETE("/sessions/:id", userHandler.RevokeSession)
+	auth.POST("/password", userHandler.ChangePassword)
+
+	// профиль пользователя
+	auth.GET("/me", userHandler.GetMe)
+	auth.PATCH("/me", userHandler.UpdateMe)
+	auth.POST("/email/verify/resend", userHandler.ResendVerification)
+	auth.POST("/identities/:provider", userHandler.LinkIdentity)
+
+	// выгрузка данных и удаление аккаунта
+	auth.GET("/export", accountHandler.Export)
+	auth.DELETE("/me", accountHandler.DeleteMe)
+
+	// двухфакторная аутентификация
+	auth.POST("/2fa/enroll", userHandler.EnrollMFA)
+	auth.POST("/2fa/confirm", userHandler.ConfirmMFA)
+	auth.POST("/2fa/disable", userHandler.DisableMFA)
 
 	// Регистрация маршрутов по заказам (orders)
-	auth.POST("/orders", orderHandler.LoadOrder)
+	auth.POST("/orders", idempotent, orderHandler.LoadOrder)
+	auth.POST("/orders/batch", idempotent, orderHandler.LoadOrdersBatch)
 	auth.GET("/orders", orderHandler.GetAllOrders)
+	auth.GET("/orders/:number", orderHandler.GetOrder)
+	auth.POST("/orders/:number/cancel", idempotent, orderHandler.CancelOrder)
 
 	// Регистрация маршрутов по балансу
 	auth.GET("/balance", balanceHandler.GetBalance)
-	auth.POST("/balance/withdraw", balanceHandler.Withdraw)
+	auth.POST("/balance/withdraw", idempotent, balanceHandler.Withdraw)
+	auth.POST("/balance/redemption-code", balanceHandler.IssueRedemptionCode)
 	auth.GET("/withdrawals", balanceHandler.GetWithdrawals)
 
+	// Регистрация маршрутов администратора
+	admin := api.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(tm, denylist), middleware.RequireRole(model.RoleAdmin, model.RoleSupport))
+	admin.DELETE("/lockouts/:login", adminHandler.UnlockAccount)
+	admin.PUT("/users/:id/role", middleware.RequireRole(model.RoleAdmin), adminHandler.SetUserRole)
+	admin.PUT("/users/:id/ban", middleware.RequireRole(model.RoleAdmin), adminHandler.BanUser)
+	admin.DELETE("/users/:id/ban", middleware.RequireRole(model.RoleAdmin), adminHandler.UnbanUser)
+	admin.GET("/orders/:number", orderHandler.GetOrderAdmin)
+	admin.POST("/orders/:number/return", middleware.RequireRole(model.RoleAdmin), orderHandler.ReturnOrderAdmin)
+
+	// магазины партнеров
+	admin.GET("/merchants", merchantAdminHandler.GetMerchants)
+	admin.POST("/merchants", middleware.RequireRole(model.RoleAdmin), merchantAdminHandler.CreateMerchant)
+	admin.GET("/merchants/:id", merchantAdminHandler.GetMerchant)
+	admin.PATCH("/merchants/:id", middleware.RequireRole(model.RoleAdmin), merchantAdminHandler.UpdateMerchant)
+	admin.DELETE("/merchants/:id", middleware.RequireRole(model.RoleAdmin), merchantAdminHandler.DeleteMerchant)
+	admin.GET("/merchants/:id/report", merchantAdminHandler.GetMerchantReport)
+
+	// управление API ключами мерчанта
+	merchant := api.Group("/merchant")
+	merchant.Use(middleware.AuthMiddleware(tm, denylist), middleware.RequireRole(model.RoleMerchant))
+	merchant.GET("/keys", merchantHandler.GetAPIKeys)
+	merchant.POST("/keys", merchantHandler.CreateAPIKey)
+	merchant.POST("/keys/:id/rotate", merchantHandler.RotateAPIKey)
+	merchant.DELETE("/keys/:id", merchantHandler.RevokeAPIKey)
+
+	// интеграции касс партнеров по API ключу
+	partner := api.Group("/partner")
+	partner.Use(middleware.APIKeyMiddleware(apiKeys), idempotent)
+	partner.POST("/orders", middleware.RequireScope(model.ScopeOrdersWrite), partnerHandler.LoadOrder)
+	partner.POST("/orders/:number/return", middleware.RequireScope(model.ScopeOrdersWrite), partnerHandler.ReturnOrder)
+	partner.PUT("/orders/:number/receipt", middleware.RequireScope(model.ScopeOrdersWrite), partnerHandler.SaveReceipt)
+	partner.POST("/redemptions", middleware.RequireScope(model.ScopeRedemptionsWrite), partnerHandler.Redeem)
+
+	// публичные ключи для проверки токенов
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	// Swagger UI
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
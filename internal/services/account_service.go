@@ -0,0 +1,459 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const exportFormat = "loyalityhub-export/v1"
+
+// AccountDeletionConfig настройки удаления аккаунтов
+type AccountDeletionConfig struct {
+	// сколько аккаунт ждет обезличивания после запроса на удаление
+	Grace time.Duration
+	// как часто фоновая задача ищет аккаунты к обезличиванию
+	Interval time.Duration
+	// сколько аккаунтов обрабатывается за один проход
+	BatchSize int
+}
+
+func NewAccountDeletionConfig() AccountDeletionConfig {
+	cfg := AccountDeletionConfig{
+		Grace:     30 * 24 * time.Hour,
+		Interval:  time.Hour,
+		BatchSize: 100,
+	}
+	if v, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE")); err == nil && v >= 0 {
+		cfg.Grace = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_INTERVAL")); err == nil && v > 0 {
+		cfg.Interval = v
+	}
+	return cfg
+}
+
+type AccountService struct {
+	repo     *repository.Repositories
+	deletion AccountDeletionConfig
+	logger   *zap.Logger
+}
+
+func NewAccountService(repo *repository.Repositories, deletion AccountDeletionConfig,
+	logger *zap.Logger) *AccountService {
+	return &AccountService{
+		repo:     repo,
+		deletion: deletion,
+		logger:   logger,
+	}
+}
+
+// Export собирает zip архив с данными пользователя: профиль, заказы,
+// списания и сессии в json
+func (s *AccountService) Export(ctx context.Context) ([]byte, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "AccountService.Export")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	// все выборки из одного снимка базы
+	tx, err := s.repo.BeginTx(ctx, pgx.RepeatableRead)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	user, err := s.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+	profile, err := s.repo.NewProfileRepo(tx).Get(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	orders, err := s.repo.NewOrderRepo(tx).GetAll(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("get orders: %w", err)
+	}
+	withdrawals, err := s.repo.NewBalanceRepo(tx).GetAllWithdrawals(ctx, userIDStr, nil)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("get withdrawals: %w", err)
+	}
+	sessions, err := s.repo.NewSessionRepo(tx).GetAllByUserID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", dto.NewProfileResponse(user, profile)},
+		{"orders.json", toExportOrders(orders)},
+		{"withdrawals.json", toExportWithdrawals(withdrawals)},
+		{"sessions.json", toSessionResponses(sessions)},
+	}
+
+	manifest := dto.ExportManifest{
+		Format:      exportFormat,
+		UserID:      userIDStr,
+		GeneratedAt: time.Now().UTC(),
+	}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.name)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if err := writeZipJSON(zw, "manifest.json", manifest); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	for _, f := range files {
+		if err := writeZipJSON(zw, f.name, f.data); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("close zip: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr), attribute.Int("export.size", buf.Len()))
+	s.logger.Info("user data exported", zap.String("user.id", userIDStr))
+	return buf.Bytes(), nil
+}
+
+func writeZipJSON(zw *zip.Writer, name string, data any) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", name, err)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	return nil
+}
+
+func toExportOrders(orders []model.Order) []dto.ExportOrder {
+	resp := make([]dto.ExportOrder, 0, len(orders))
+	for _, o := range orders {
+		resp = append(resp, dto.ExportOrder{
+			Number:     o.Number,
+			Status:     string(o.Status),
+			Accrual:    o.Accrual,
+			UploadedAt: o.UploadedAt,
+		})
+	}
+	return resp
+}
+
+func toExportWithdrawals(withdrawals []model.Withdrawal) []dto.Withdrawn {
+	resp := make([]dto.Withdrawn, 0, len(withdrawals))
+	for _, w := range withdrawals {
+		resp = append(resp, dto.Withdrawn{
+			Order:       w.OrderID,
+			Sum:         w.Amount,
+			ProcessedAt: w.ProcessedAt,
+		})
+	}
+	return resp
+}
+
+func toSessionResponses(sessions []model.Session) []dto.SessionResponse {
+	resp := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, dto.SessionResponse{
+			ID:        s.ID.String(),
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			AuthAt:    s.AuthAt,
+			ExpireAt:  s.ExpireAt,
+		})
+	}
+	return resp
+}
+
+// RequestDeletion планирует удаление аккаунта и завершает все его сессии.
+// До истечения grace периода удаление отменяется входом в аккаунт
+func (s *AccountService) RequestDeletion(ctx context.Context, req dto.DeleteAccountRequest,
+	client dto.ClientInfo) (dto.DeleteAccountResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "AccountService.RequestDeletion")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.DeleteAccountResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	userRepo := s.repo.NewUserRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	err = reauthenticate(ctx, s.repo.NewSessionRepo(tx), user, req.Password, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, err
+	}
+
+	scheduledAt, err := scheduleDeletion(ctx, userRepo, user, time.Now(), s.deletion.Grace)
+	if err != nil {
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, err
+	}
+
+	err = s.repo.NewSessionRepo(tx).DeleteAllByUserID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, fmt.Errorf("delete sessions: %w", err)
+	}
+
+	err = s.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:     uuid.New(),
+		UserID: &user.ID,
+		Type:   model.AuditEventAccountDeletionRequested,
+		IP:     client.IP,
+		Details: map[string]string{
+			"scheduled_at": scheduledAt.Format(time.RFC3339),
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return dto.DeleteAccountResponse{}, fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	s.logger.Info("account deletion requested", zap.String("user.id", userIDStr),
+		zap.Time("scheduled_at", scheduledAt))
+	return dto.DeleteAccountResponse{DeletionScheduledAt: scheduledAt}, nil
+}
+
+// RunDeletionWorker периодически обезличивает аккаунты, у которых истек
+// grace период. Работает до отмены ctx
+func (s *AccountService) RunDeletionWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.deletion.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.AnonymizeDue(ctx)
+			if err != nil {
+				s.logger.Error("account anonymization failed", zap.Error(err))
+			}
+			if n > 0 {
+				s.logger.Info("accounts anonymized", zap.Int("count", n))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AnonymizeDue обезличивает до BatchSize аккаунтов, каждый в своей транзакции
+func (s *AccountService) AnonymizeDue(ctx context.Context) (int, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "AccountService.AnonymizeDue")
+	defer span.End()
+
+	done := 0
+	for done < s.deletion.BatchSize {
+		ok, err := s.anonymizeNext(ctx)
+		if err != nil {
+			span.RecordError(err)
+			return done, err
+		}
+		if !ok {
+			break
+		}
+		done++
+	}
+
+	span.SetAttributes(attribute.Int("accounts.anonymized", done))
+	return done, nil
+}
+
+// anonymizeNext обезличивает один аккаунт. false - обрабатывать нечего
+func (s *AccountService) anonymizeNext(ctx context.Context) (bool, error) {
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	userRepo := s.repo.NewUserRepo(tx)
+
+	user, err := lockDueForDeletion(ctx, userRepo, time.Now(), s.deletion.Grace)
+	if err != nil || user == nil {
+		return false, err
+	}
+	userID := user.ID.String()
+
+	// заказы, списания и баланс остаются: они нужны для бухгалтерии
+	err = userRepo.Anonymize(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("anonymize user: %w", err)
+	}
+	err = s.repo.NewProfileRepo(tx).Delete(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.NewSessionRepo(tx).DeleteAllByUserID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete sessions: %w", err)
+	}
+	err = s.repo.NewMFARepo(tx).Delete(ctx, userID)
+	if err != nil && !errors.Is(err, model.ErrMFANotEnabled) {
+		return false, err
+	}
+	err = s.repo.NewAPIKeyRepo(tx).RevokeAll(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.NewPasswordResetRepo(tx).InvalidateAll(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.NewEmailVerificationRepo(tx).InvalidateAll(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.NewRedemptionCodeRepo(tx).InvalidateAll(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = s.repo.NewIdentityRepo(tx).DeleteAllByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	_, err = s.repo.NewAuthAttemptRepo(tx).Delete(ctx, loginguard.LoginKey(user.Login))
+	if err != nil {
+		return false, fmt.Errorf("delete auth attempts: %w", err)
+	}
+
+	auditRepo := s.repo.NewAuditRepo(tx)
+	err = auditRepo.ScrubUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	err = auditRepo.Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventAccountAnonymized,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("create audit event: %w", err)
+	}
+
+	s.logger.Info("account anonymized", zap.String("user.id", userID))
+	return true, nil
+}
+
+// scheduleDeletion помечает аккаунт к удалению и возвращает срок обезличивания.
+// Повторный запрос не сдвигает срок
+func scheduleDeletion(ctx context.Context, userRepo interfaces.UserRepositoryInterface, user *model.User,
+	now time.Time, grace time.Duration) (time.Time, error) {
+	if user.DeletionRequestedAt != nil {
+		return user.DeletionRequestedAt.Add(grace), nil
+	}
+
+	if err := userRepo.SetDeletionRequested(ctx, user.ID.String(), true); err != nil {
+		return time.Time{}, fmt.Errorf("request deletion: %w", err)
+	}
+	user.DeletionRequestedAt = &now
+	return now.Add(grace), nil
+}
+
+// cancelDeletion отменяет запрошенное удаление аккаунта. false - удаление
+// не было запрошено
+func cancelDeletion(ctx context.Context, userRepo interfaces.UserRepositoryInterface,
+	auditRepo interfaces.AuditRepository, user *model.User, ip string, now time.Time) (bool, error) {
+	if user.DeletionRequestedAt == nil {
+		return false, nil
+	}
+
+	if err := userRepo.SetDeletionRequested(ctx, user.ID.String(), false); err != nil {
+		return false, fmt.Errorf("cancel deletion: %w", err)
+	}
+	user.DeletionRequestedAt = nil
+
+	err := auditRepo.Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventAccountDeletionCancelled,
+		IP:        ip,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return false, fmt.Errorf("create audit event: %w", err)
+	}
+	return true, nil
+}
+
+// lockDueForDeletion блокирует следующий аккаунт, у которого истек grace период.
+// nil - обрабатывать нечего
+func lockDueForDeletion(ctx context.Context, userRepo interfaces.UserRepositoryInterface,
+	now time.Time, grace time.Duration) (*model.User, error) {
+	users, err := userRepo.LockDueForDeletion(ctx, now.Add(-grace), 1)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+	return &users[0], nil
+}
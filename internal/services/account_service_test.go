@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+)
+
+// fakeUserRepo пользователи в памяти; NOW() в запросах заменяет поле now
+type fakeUserRepo struct {
+	interfaces.UserRepositoryInterface
+	users map[string]*model.User
+	now   time.Time
+}
+
+func (r *fakeUserRepo) SetDeletionRequested(_ context.Context, userID string, requested bool) error {
+	user := r.users[userID]
+	user.DeletionRequestedAt = nil
+	if requested {
+		now := r.now
+		user.DeletionRequestedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeUserRepo) LockDueForDeletion(_ context.Context, before time.Time, limit int) ([]model.User, error) {
+	users := make([]model.User, 0)
+	for _, user := range r.users {
+		if len(users) < limit && user.DeletionRequestedAt != nil && user.DeletionRequestedAt.Before(before) {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+// fakeAuditRepo копит события, остальные методы не используются
+type fakeAuditRepo struct {
+	interfaces.AuditRepository
+	events []*model.AuditEvent
+}
+
+func (r *fakeAuditRepo) Create(_ context.Context, event *model.AuditEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestAccountDeletionGrace(t *testing.T) {
+	const grace = 30 * 24 * time.Hour
+	ctx := context.Background()
+	requestedAt := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	user := &model.User{ID: uuid.New(), Login: "user@example.com"}
+	repo := &fakeUserRepo{users: map[string]*model.User{user.ID.String(): user}, now: requestedAt}
+
+	scheduledAt, err := scheduleDeletion(ctx, repo, user, requestedAt, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheduledAt.Equal(requestedAt.Add(grace)) {
+		t.Errorf("expected deletion at %s, got %s", requestedAt.Add(grace), scheduledAt)
+	}
+
+	// повторный запрос не сдвигает срок
+	again, err := scheduleDeletion(ctx, repo, user, requestedAt.Add(24*time.Hour), grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equal(scheduledAt) || !user.DeletionRequestedAt.Equal(requestedAt) {
+		t.Errorf("expected repeated request to keep %s, got %s", scheduledAt, again)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		due  bool
+	}{
+		{name: "right after request", now: requestedAt},
+		{name: "before grace ends", now: scheduledAt.Add(-time.Second)},
+		{name: "grace just ended", now: scheduledAt},
+		{name: "after grace", now: scheduledAt.Add(time.Second), due: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, err := lockDueForDeletion(ctx, repo, tt.now, grace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (due != nil) != tt.due {
+				t.Fatalf("expected due %v, got %+v", tt.due, due)
+			}
+			if due != nil && due.ID != user.ID {
+				t.Errorf("expected user %s, got %s", user.ID, due.ID)
+			}
+		})
+	}
+}
+
+func TestAccountDeletionCancel(t *testing.T) {
+	const grace = time.Hour
+	ctx := context.Background()
+	requestedAt := time.Date(2025, 8, 1, 12, 0, 0, 0, time.UTC)
+	user := &model.User{ID: uuid.New(), Login: "user@example.com"}
+	repo := &fakeUserRepo{users: map[string]*model.User{user.ID.String(): user}, now: requestedAt}
+	audit := &fakeAuditRepo{}
+
+	// без запроса на удаление отменять нечего
+	cancelled, err := cancelDeletion(ctx, repo, audit, user, "10.0.0.1", requestedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled || len(audit.events) != 0 {
+		t.Fatalf("expected nothing to cancel, got %v and %d events", cancelled, len(audit.events))
+	}
+
+	if _, err := scheduleDeletion(ctx, repo, user, requestedAt, grace); err != nil {
+		t.Fatal(err)
+	}
+
+	// вход владельца в grace период отменяет удаление
+	loginAt := requestedAt.Add(grace / 2)
+	cancelled, err = cancelDeletion(ctx, repo, audit, user, "10.0.0.1", loginAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cancelled || user.DeletionRequestedAt != nil {
+		t.Fatalf("expected deletion to be cancelled, got %v %v", cancelled, user.DeletionRequestedAt)
+	}
+	if len(audit.events) != 1 || audit.events[0].Type != model.AuditEventAccountDeletionCancelled ||
+		*audit.events[0].UserID != user.ID || audit.events[0].IP != "10.0.0.1" {
+		t.Errorf("expected deletion cancelled audit event, got %+v", audit.events)
+	}
+
+	// отмененный аккаунт не обезличивается и после окончания grace периода
+	due, err := lockDueForDeletion(ctx, repo, requestedAt.Add(2*grace), grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if due != nil {
+		t.Fatalf("expected cancelled account not to be due, got %+v", due)
+	}
+
+	// новый запрос после отмены отсчитывает grace период заново
+	repo.now = loginAt.Add(time.Minute)
+	scheduledAt, err := scheduleDeletion(ctx, repo, user, repo.now, grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scheduledAt.Equal(repo.now.Add(grace)) {
+		t.Errorf("expected new deletion at %s, got %s", repo.now.Add(grace), scheduledAt)
+	}
+	due, err = lockDueForDeletion(ctx, repo, requestedAt.Add(grace).Add(time.Second), grace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if due != nil {
+		t.Errorf("expected old request time not to count, got %+v", due)
+	}
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/accrualrules"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// EngineAccrualProvider считает начисления сам по чекам заказов. Заказ без
+// чека регистрируется и ждет, пока магазин передаст чек
+type EngineAccrualProvider struct {
+	receipts interfaces.ReceiptRepository
+	rules    accrualrules.Rules
+	logger   *zap.Logger
+}
+
+func NewEngineAccrualProvider(receipts interfaces.ReceiptRepository, rules accrualrules.Rules,
+	logger *zap.Logger) *EngineAccrualProvider {
+	return &EngineAccrualProvider{
+		receipts: receipts,
+		rules:    rules,
+		logger:   logger.With(zap.String("provider", "accrual_engine")),
+	}
+}
+
+func (p *EngineAccrualProvider) GetAccrual(ctx context.Context,
+	order model.Order) (dto.AccrualServiceResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "EngineAccrualProvider.GetAccrual")
+	defer span.End()
+
+	receipt, err := p.receipts.Get(ctx, order.Number)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AccrualServiceResponse{}, fmt.Errorf("[receiptRepo.Get]: %w", err)
+	}
+	if receipt == nil {
+		return dto.AccrualServiceResponse{
+			OrderNumber: order.Number,
+			Status:      string(model.OrderStatusRegistered),
+		}, nil
+	}
+
+	accrual := p.rules.Calculate(*receipt)
+	span.SetAttributes(attribute.String("order_number", order.Number), attribute.String("accrual", accrual.String()))
+	p.logger.Debug("accrual calculated", zap.String("order_number", order.Number),
+		zap.String("accrual", accrual.String()))
+	return dto.AccrualServiceResponse{
+		OrderNumber: order.Number,
+		Status:      string(model.OrderStatusProcessed),
+		Accrual:     accrual,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/accrualrules"
+	"go.uber.org/zap"
+)
+
+// failingReceiptRepo репозиторий, который не может прочитать чек
+type failingReceiptRepo struct {
+	interfaces.ReceiptRepository
+}
+
+func (failingReceiptRepo) Get(context.Context, string) (*model.Receipt, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestEngineAccrualProvider(t *testing.T) {
+	rules, err := accrualrules.Parse("percent:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipts := &fakeReceiptRepo{receipts: map[string]*model.Receipt{
+		"2377225624": {
+			OrderNumber: "2377225624",
+			Total:       decimal.RequireFromString("333.33"),
+			Items: []model.ReceiptItem{{SKU: "coffee", Quantity: decimal.NewFromInt(1),
+				Price: decimal.RequireFromString("333.33")}},
+		},
+	}}
+	p := NewEngineAccrualProvider(receipts, rules, zap.NewNop())
+
+	// без чека заказ только регистрируется
+	resp, err := p.GetAccrual(context.Background(), model.Order{Number: "12345674", Status: model.OrderStatusNew})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.OrderNumber != "12345674" || resp.Status != string(model.OrderStatusRegistered) || !resp.Accrual.IsZero() {
+		t.Errorf("expected registered order without accrual, got %+v", resp)
+	}
+
+	// по чеку начисление рассчитывается без потери точности
+	resp, err = p.GetAccrual(context.Background(), model.Order{Number: "2377225624", Status: model.OrderStatusRegistered})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != string(model.OrderStatusProcessed) || resp.Accrual.String() != "9.99" {
+		t.Errorf("expected processed order with accrual 9.99, got %+v", resp)
+	}
+
+	p = NewEngineAccrualProvider(failingReceiptRepo{}, rules, zap.NewNop())
+	if _, err := p.GetAccrual(context.Background(), model.Order{Number: "2377225624"}); err == nil {
+		t.Error("expected repository error to be returned")
+	}
+}
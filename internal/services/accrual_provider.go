@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sony/gobreaker/v2"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/client/accrual"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/accrualrules"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+const (
+	// начисления считает внешний ACCRUAL_SERVICE
+	AccrualProviderExternal = "external"
+	// начисления считаются по чекам правилами из ACCRUAL_RULES
+	AccrualProviderEngine = "engine"
+)
+
+// AccrualConfig выбор источника начислений
+type AccrualConfig struct {
+	Provider string
+	// адрес внешнего сервиса
+	ServiceAddr string
+	// ограничение запросов к внешнему сервису в секунду
+	RPS int
+}
+
+func NewAccrualConfig() AccrualConfig {
+	cfg := AccrualConfig{
+		Provider:    AccrualProviderExternal,
+		ServiceAddr: os.Getenv("ACCRUAL_SERVICE"),
+		RPS:         100,
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("ACCRUAL_PROVIDER"))); v != "" {
+		cfg.Provider = v
+	}
+	return cfg
+}
+
+// NewAccrualProvider источник начислений по настройкам
+func NewAccrualProvider(cfg AccrualConfig, repos *repository.Repositories,
+	logger *zap.Logger) (interfaces.AccrualProvider, error) {
+	switch cfg.Provider {
+	case AccrualProviderExternal:
+		return NewExternalAccrualProvider(accrual.NewAccrualClient(cfg.RPS, cfg.ServiceAddr)), nil
+	case AccrualProviderEngine:
+		rules, err := accrualrules.NewFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewEngineAccrualProvider(repos.NewReceiptRepo(repos.Pool()), rules, logger), nil
+	default:
+		return nil, fmt.Errorf("ACCRUAL_PROVIDER: unknown provider %q", cfg.Provider)
+	}
+}
+
+// ExternalAccrualProvider запрашивает начисления у внешнего сервиса через circuit breaker
+type ExternalAccrualProvider struct {
+	client *accrual.AccrualClient
+	cb     *gobreaker.CircuitBreaker[dto.AccrualServiceResponse]
+}
+
+func NewExternalAccrualProvider(client *accrual.AccrualClient) *ExternalAccrualProvider {
+	return &ExternalAccrualProvider{
+		client: client,
+		cb: gobreaker.NewCircuitBreaker[dto.AccrualServiceResponse](gobreaker.Settings{
+			Name: "accrual service breaker",
+		}),
+	}
+}
+
+func (p *ExternalAccrualProvider) GetAccrual(ctx context.Context,
+	order model.Order) (dto.AccrualServiceResponse, error) {
+	_, span := otel.Tracer("service").Start(ctx, "ExternalAccrualProvider.GetAccrual")
+	defer span.End()
+
+	resp, err := p.cb.Execute(func() (dto.AccrualServiceResponse, error) {
+		return p.client.GetData(order.Number)
+	})
+	if err != nil {
+		span.RecordError(err)
+		return dto.AccrualServiceResponse{}, err
+	}
+	return resp, nil
+}
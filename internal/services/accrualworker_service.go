@@ -2,35 +2,30 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/jackc/pgx/v5"
-	"github.com/shopspring/decimal"
-	"github.com/sony/gobreaker/v2"
-	"github.com/vvjke314/itk-courses/loyalityhub/internal/client/accrual"
-	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/metrics"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 )
 
 type AccrualWorkerService struct {
-	repo   *repository.Repositories
-	client *accrual.AccrualClient
-	logger *zap.Logger
-	cb     *gobreaker.CircuitBreaker[dto.AccrualServiceResponse]
+	repo     *repository.Repositories
+	provider interfaces.AccrualProvider
+	logger   *zap.Logger
 }
 
 func NewAccrualWorkerService(repos *repository.Repositories,
-	logger *zap.Logger, client *accrual.AccrualClient) *AccrualWorkerService {
+	logger *zap.Logger, provider interfaces.AccrualProvider) *AccrualWorkerService {
 	return &AccrualWorkerService{
-		repo:   repos,
-		client: client,
-		logger: logger.With(zap.String("layer", "service")),
-		cb: gobreaker.NewCircuitBreaker[dto.AccrualServiceResponse](gobreaker.Settings{
-			Name: "accrual service breaker",
-		}),
+		repo:     repos,
+		provider: provider,
+		logger:   logger.With(zap.String("layer", "service")),
 	}
 }
 
@@ -60,10 +55,20 @@ func (s *AccrualWorkerService) UpdateOrders(ctx context.Context) error {
 		return fmt.Errorf("can't get all pending requests %w", err)
 	}
 
-	for _, orderNumber := range pendingOrders {
-		resp, err := s.cb.Execute(func() (dto.AccrualServiceResponse, error) {
-			return s.client.GetData(orderNumber)
-		})
+	for _, pending := range pendingOrders {
+		// блокируем заказ до расчета: чек, по которому считается начисление,
+		// не заменится, пока транзакция не завершится
+		locked, err := orderRepo.GetByNumberForUpdate(ctx, pending.Number)
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("can't lock order", zap.Error(err))
+			return fmt.Errorf("can't lock order %w", err)
+		}
+		if !locked.Status.Pending() {
+			continue
+		}
+
+		resp, err := s.provider.GetAccrual(ctx, pending)
 		if err != nil {
 			span.RecordError(err)
 			s.logger.Error("can't get order data", zap.Error(err))
@@ -73,9 +78,24 @@ func (s *AccrualWorkerService) UpdateOrders(ctx context.Context) error {
 		order := model.Order{
 			Number:  resp.OrderNumber,
 			Status:  model.OrderStatus(resp.Status),
-			Accrual: decimal.NewFromFloat(resp.Accrual),
+			Accrual: resp.Accrual,
 		}
+		// без изменений заказ не переписываем
+		if order.Status == pending.Status && order.Accrual.Equal(pending.Accrual) {
+			continue
+		}
+		// отмену и возврат расчетная система не присылает
+		if order.Status == model.OrderStatusCancelled || order.Status == model.OrderStatusReturned ||
+			!pending.Status.CanTransitionTo(order.Status) {
+			s.rejectTransition(pending, order.Status)
+			continue
+		}
+		// статус мог измениться после выборки, условие перехода проверяет и репозиторий
 		if err = orderRepo.Update(ctx, order); err != nil {
+			if errors.Is(err, model.ErrInvalidOrderTransition) {
+				s.rejectTransition(pending, order.Status)
+				continue
+			}
 			span.RecordError(err)
 			s.logger.Error("can't update order data", zap.Error(err))
 			return fmt.Errorf("can't update order data %w", err)
@@ -88,3 +108,17 @@ func (s *AccrualWorkerService) UpdateOrders(ctx context.Context) error {
 
 	return nil
 }
+
+// rejectTransition логирует и считает переход, который не допускает жизненный цикл заказа
+func (s *AccrualWorkerService) rejectTransition(order model.Order, next model.OrderStatus) {
+	// неизвестный статус от расчетной системы не должен плодить метки
+	label := string(next)
+	if !next.Valid() {
+		label = "unknown"
+	}
+	metrics.OrderTransitionsRejectedTotal.WithLabelValues(string(order.Status), label).Inc()
+	s.logger.Warn("order status transition rejected",
+		zap.String("order_number", order.Number),
+		zap.String("from", string(order.Status)),
+		zap.String("to", string(next)))
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	apiKeyPrefix = "lh_"
+	// сколько старый ключ продолжает работать после ротации,
+	// чтобы партнер успел обновить его на всех кассах
+	apiKeyRotationGrace = 24 * time.Hour
+	// last_used_at обновляется не чаще этого интервала
+	apiKeyTouchInterval = time.Minute
+)
+
+type APIKeyService struct {
+	repo   *repository.Repositories
+	logger *zap.Logger
+}
+
+func NewAPIKeyService(repo *repository.Repositories, logger *zap.Logger) *APIKeyService {
+	return &APIKeyService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Create выпускает новый ключ мерчанта. Ключ возвращается только здесь,
+// в базе хранится его хеш
+func (s *APIKeyService) Create(ctx context.Context, req dto.CreateAPIKeyRequest) (dto.CreateAPIKeyResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "APIKeyService.Create")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	if err := validateScopes(req.Scopes); err != nil {
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.CreateAPIKeyResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	resp, err := s.issue(ctx, s.repo.NewAPIKeyRepo(tx), uuid.MustParse(userIDStr),
+		strings.TrimSpace(req.Name), req.Scopes)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", resp.Prefix))
+	return resp, nil
+}
+
+// List возвращает действующие ключи мерчанта
+func (s *APIKeyService) List(ctx context.Context) (dto.GetAPIKeysResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "APIKeyService.List")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.GetAPIKeysResponse{}, err
+	}
+
+	keys, err := s.repo.NewAPIKeyRepo(s.repo.Pool()).GetAllByUserID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.GetAPIKeysResponse{}, fmt.Errorf("get api keys: %w", err)
+	}
+
+	resp := dto.GetAPIKeysResponse{Keys: make([]dto.APIKeyResponse, 0, len(keys))}
+	for _, k := range keys {
+		resp.Keys = append(resp.Keys, toAPIKeyResponse(&k))
+	}
+	return resp, nil
+}
+
+// Rotate выпускает ключ с теми же именем и правами. Старый ключ продолжает
+// работать еще apiKeyRotationGrace
+func (s *APIKeyService) Rotate(ctx context.Context, keyID string) (dto.CreateAPIKeyResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "APIKeyService.Rotate")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.CreateAPIKeyResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	keyRepo := s.repo.NewAPIKeyRepo(tx)
+
+	old, err := keyRepo.GetByID(ctx, userIDStr, keyID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+	if !old.Active(time.Now()) {
+		err = model.ErrAPIKeyNotFound
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	resp, err := s.issue(ctx, keyRepo, old.UserID, old.Name, old.Scopes)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	err = keyRepo.SetExpiresAt(ctx, keyID, time.Now().Add(apiKeyRotationGrace))
+	if err != nil {
+		span.RecordError(err)
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", resp.Prefix))
+	s.logger.Info("api key rotated", zap.String("old_prefix", old.Prefix), zap.String("prefix", resp.Prefix))
+	return resp, nil
+}
+
+// Revoke немедленно отзывает ключ
+func (s *APIKeyService) Revoke(ctx context.Context, keyID string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "APIKeyService.Revoke")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	if err := s.repo.NewAPIKeyRepo(s.repo.Pool()).Revoke(ctx, userIDStr, keyID); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// Authenticate проверяет ключ из заголовка X-Api-Key
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "APIKeyService.Authenticate")
+	defer span.End()
+
+	keyRepo := s.repo.NewAPIKeyRepo(s.repo.Pool())
+	key, err := findAPIKey(ctx, keyRepo, rawKey)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	// ошибка учета использования не должна ломать запрос партнера
+	if err := keyRepo.TouchLastUsed(ctx, key.ID.String(), apiKeyTouchInterval); err != nil {
+		s.logger.Warn("can't update api key usage", zap.String("prefix", key.Prefix), zap.Error(err))
+	}
+
+	span.SetAttributes(attribute.String("api_key.prefix", key.Prefix))
+	return key, nil
+}
+
+// findAPIKey ищет активный ключ по хешу переданного значения
+func findAPIKey(ctx context.Context, keyRepo interfaces.APIKeyRepository, rawKey string) (*model.APIKey, error) {
+	if !strings.HasPrefix(rawKey, apiKeyPrefix) {
+		return nil, model.ErrInvalidAPIKey
+	}
+
+	key, err := keyRepo.GetActiveByHash(ctx, hashAPIKey(rawKey))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, model.ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// issue генерирует и сохраняет новый ключ
+func (s *APIKeyService) issue(ctx context.Context, keyRepo interfaces.APIKeyRepository,
+	userID uuid.UUID, name string, scopes []string) (dto.CreateAPIKeyResponse, error) {
+	prefix, rawKey, err := generateAPIKey()
+	if err != nil {
+		return dto.CreateAPIKeyResponse{}, fmt.Errorf("generate api key: %w", err)
+	}
+
+	key := &model.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hashAPIKey(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := keyRepo.Create(ctx, key); err != nil {
+		return dto.CreateAPIKeyResponse{}, err
+	}
+
+	return dto.CreateAPIKeyResponse{
+		APIKeyResponse: toAPIKeyResponse(key),
+		Key:            rawKey,
+	}, nil
+}
+
+// generateAPIKey возвращает открытый префикс и полный ключ вида lh_<prefix>_<secret>
+func generateAPIKey() (string, string, error) {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(id)
+	return prefix, prefix + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashAPIKey(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return model.ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(model.APIKeyScopes, scope) {
+			return fmt.Errorf("%w: %s", model.ErrInvalidScope, scope)
+		}
+	}
+	return nil
+}
+
+func toAPIKeyResponse(k *model.APIKey) dto.APIKeyResponse {
+	return dto.APIKeyResponse{
+		ID:         k.ID.String(),
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		CreatedAt:  k.CreatedAt,
+		LastUsedAt: k.LastUsedAt,
+		ExpiresAt:  k.ExpiresAt,
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+)
+
+// fakeAPIKeyRepo ключи в памяти по хешу, остальные методы не используются
+type fakeAPIKeyRepo struct {
+	interfaces.APIKeyRepository
+	keys map[string]*model.APIKey
+}
+
+func (r *fakeAPIKeyRepo) Create(_ context.Context, key *model.APIKey) error {
+	r.keys[key.KeyHash] = key
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) GetActiveByHash(_ context.Context, hash string) (*model.APIKey, error) {
+	return r.keys[hash], nil
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		err    error
+	}{
+		{name: "single scope", scopes: []string{model.ScopeOrdersWrite}},
+		{name: "all scopes", scopes: model.APIKeyScopes},
+		{name: "no scopes", err: model.ErrInvalidScope},
+		{name: "empty list", scopes: []string{}, err: model.ErrInvalidScope},
+		{name: "unknown scope", scopes: []string{model.ScopeOrdersWrite, "orders:read"}, err: model.ErrInvalidScope},
+		// права сравниваются строго, без приведения регистра и пробелов
+		{name: "different case", scopes: []string{"Orders:Write"}, err: model.ErrInvalidScope},
+		{name: "padded scope", scopes: []string{" orders:write"}, err: model.ErrInvalidScope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateScopes(tt.scopes)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	prefix, rawKey, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(prefix, apiKeyPrefix) || !strings.HasPrefix(rawKey, prefix+"_") {
+		t.Errorf("unexpected key format: prefix %q, key %q", prefix, rawKey)
+	}
+
+	// в базе хранится только хеш, он не совпадает с ключом и детерминирован
+	hash := hashAPIKey(rawKey)
+	if hash == rawKey || strings.Contains(hash, rawKey) || hash != hashAPIKey(rawKey) {
+		t.Errorf("unexpected key hash %q", hash)
+	}
+
+	_, other, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == rawKey || hashAPIKey(other) == hash {
+		t.Error("expected generated keys to differ")
+	}
+}
+
+func TestFindAPIKey(t *testing.T) {
+	repo := &fakeAPIKeyRepo{keys: map[string]*model.APIKey{}}
+	issued, err := (&APIKeyService{}).issue(context.Background(), repo, uuid.New(), "pos-terminal-1",
+		[]string{model.ScopeOrdersWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := repo.keys[issued.Key]; ok {
+		t.Fatal("expected raw key not to be stored")
+	}
+
+	// ключ с тем же открытым префиксом, но другим секретом
+	prefix, secret, _ := strings.Cut(strings.TrimPrefix(issued.Key, apiKeyPrefix), "_")
+	forged := apiKeyPrefix + prefix + "_" + strings.Repeat("A", len(secret))
+
+	tests := []struct {
+		name   string
+		rawKey string
+		err    error
+	}{
+		{name: "issued key", rawKey: issued.Key},
+		{name: "wrong secret", rawKey: forged, err: model.ErrInvalidAPIKey},
+		{name: "prefix only", rawKey: issued.Prefix, err: model.ErrInvalidAPIKey},
+		{name: "truncated key", rawKey: issued.Key[:len(issued.Key)-1], err: model.ErrInvalidAPIKey},
+		{name: "key hash", rawKey: hashAPIKey(issued.Key), err: model.ErrInvalidAPIKey},
+		{name: "without lh prefix", rawKey: strings.TrimPrefix(issued.Key, apiKeyPrefix), err: model.ErrInvalidAPIKey},
+		{name: "empty", err: model.ErrInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := findAPIKey(context.Background(), repo, tt.rawKey)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if tt.err == nil && (key == nil || key.ID.String() != issued.ID || !key.HasScope(model.ScopeOrdersWrite)) {
+				t.Errorf("expected issued key, got %+v", key)
+			}
+		})
+	}
+}
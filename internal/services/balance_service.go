@@ -63,6 +63,7 @@ func (s *BalanceService) GetBalance(ctx context.Context) (dto.GetBalanceResponse
 	return dto.GetBalanceResponse{
 		Current:   balance.Current,
 		Withdrawn: balance.Withdrawn,
+		Debt:      balance.Debt,
 	}, nil
 }
 
@@ -85,6 +86,34 @@ func (s *BalanceService) Withdraw(ctx context.Context, req dto.NewWithdrawnReque
 		_ = tx.Commit(ctx)
 	}()
 
+	// списывать баллы можно только с подтвержденным email
+	user, err := s.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user error: %w", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		err = model.ErrEmailNotVerified
+		span.RecordError(err)
+		return err
+	}
+
+	// касса партнера списывает баллы только по коду, который показал покупатель.
+	// Код гасится в этой же транзакции и остается действовать, если списание не прошло
+	if key, _ := ctx.Value(contextkeys.APIKey).(*model.APIKey); key != nil {
+		err = consumeRedemptionCode(ctx, s.repo.NewRedemptionCodeRepo(tx), userIDStr, req.RedemptionCode)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	merchant, err := resolveMerchant(ctx, s.repo.NewMerchantRepo(tx), req.MerchantID)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
 	balanceRepo := s.repo.NewBalanceRepo(tx)
 
 	balance, err := balanceRepo.Get(ctx, userIDStr)
@@ -94,7 +123,7 @@ func (s *BalanceService) Withdraw(ctx context.Context, req dto.NewWithdrawnReque
 	}
 
 	if balance.Current < req.Sum {
-		err := model.ErrInsufficientFunds
+		err = model.ErrInsufficientFunds
 		span.RecordError(err)
 		return err
 	}
@@ -105,6 +134,7 @@ func (s *BalanceService) Withdraw(ctx context.Context, req dto.NewWithdrawnReque
 		UserID:      userID,
 		Amount:      decimal.NewFromFloat(req.Sum),
 		ProcessedAt: time.Now(),
+		MerchantID:  merchantRef(merchant),
 	}
 
 	err = balanceRepo.AddWithdraw(ctx, withdrawal)
@@ -116,7 +146,8 @@ func (s *BalanceService) Withdraw(ctx context.Context, req dto.NewWithdrawnReque
 	return nil
 }
 
-func (s *BalanceService) GetWithdrawals(ctx context.Context) (dto.GetAllWithdrawalsResponse, error) {
+// GetWithdrawals списания пользователя, merchantID оставляет только списания в этом магазине
+func (s *BalanceService) GetWithdrawals(ctx context.Context, merchantID string) (dto.GetAllWithdrawalsResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "BalanceService.GetWithdrawals")
 	defer span.End()
 
@@ -127,6 +158,16 @@ func (s *BalanceService) GetWithdrawals(ctx context.Context) (dto.GetAllWithdraw
 		return dto.GetAllWithdrawalsResponse{}, err
 	}
 
+	var merchant *uuid.UUID
+	if merchantID != "" {
+		id, err := uuid.Parse(merchantID)
+		if err != nil {
+			span.RecordError(err)
+			return dto.GetAllWithdrawalsResponse{}, dto.ErrInvalidMerchantID
+		}
+		merchant = &id
+	}
+
 	tx, err := s.repo.BeginTx(ctx, pgx.Serializable)
 	if err != nil {
 		span.RecordError(err)
@@ -141,7 +182,7 @@ func (s *BalanceService) GetWithdrawals(ctx context.Context) (dto.GetAllWithdraw
 
 	balanceRepo := s.repo.NewBalanceRepo(tx)
 
-	withdrawals, err := balanceRepo.GetAllWithdrawals(ctx, userIDStr)
+	withdrawals, err := balanceRepo.GetAllWithdrawals(ctx, userIDStr, merchant)
 	if err != nil {
 		span.RecordError(err)
 		return dto.GetAllWithdrawalsResponse{}, fmt.Errorf("get withdrawals error: %w", err)
@@ -149,11 +190,16 @@ func (s *BalanceService) GetWithdrawals(ctx context.Context) (dto.GetAllWithdraw
 
 	var res []dto.Withdrawn
 	for _, w := range withdrawals {
-		res = append(res, dto.Withdrawn{
+		item := dto.Withdrawn{
 			Order:       w.OrderID,
 			Sum:         w.Amount,
 			ProcessedAt: w.ProcessedAt,
-		})
+		}
+		if w.MerchantID != nil {
+			id := w.MerchantID.String()
+			item.MerchantID = &id
+		}
+		res = append(res, item)
 	}
 
 	return dto.GetAllWithdrawalsResponse{Withdrawals: res}, nil
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/notifier"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// EmailVerificationConfig настройки подтверждения email
+type EmailVerificationConfig struct {
+	// время жизни токена подтверждения
+	TTL time.Duration
+	// не чаще одного письма на пользователя за этот интервал
+	Interval time.Duration
+	// адрес страницы подтверждения на фронтенде, токен добавляется параметром
+	// token. Если не задан, в письмо попадает только сам токен
+	URL string
+}
+
+func NewEmailVerificationConfig() EmailVerificationConfig {
+	cfg := EmailVerificationConfig{
+		TTL:      24 * time.Hour,
+		Interval: time.Minute,
+		URL:      os.Getenv("EMAIL_VERIFICATION_URL"),
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_TTL")); err == nil && ttl > 0 {
+		cfg.TTL = ttl
+	}
+	if v, err := time.ParseDuration(os.Getenv("EMAIL_VERIFICATION_INTERVAL")); err == nil && v >= 0 {
+		cfg.Interval = v
+	}
+	return cfg
+}
+
+// contactEmail адрес для писем пользователю: email профиля, а если он не
+// заполнен - логин, когда логин является email. Пустая строка - адреса нет
+func contactEmail(user *model.User, profile *model.UserProfile) string {
+	if profile != nil && profile.Email != nil && *profile.Email != "" {
+		return *profile.Email
+	}
+	if email, err := dto.ParseEmail(user.Login); err == nil {
+		return email
+	}
+	return ""
+}
+
+// ResendVerification повторно отправляет письмо с токеном подтверждения на
+// текущий адрес пользователя. Ранее выданные токены перестают действовать
+func (u *UserService) ResendVerification(ctx context.Context) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.ResendVerification")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	user, err := u.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user.EmailVerifiedAt != nil {
+		err = model.ErrEmailAlreadyVerified
+		span.RecordError(err)
+		return err
+	}
+
+	profile, err := u.repo.NewProfileRepo(tx).Get(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	email := contactEmail(user, profile)
+	if email == "" {
+		err = model.ErrNoEmail
+		span.RecordError(err)
+		return err
+	}
+
+	last, err := u.repo.NewEmailVerificationRepo(tx).LastCreatedAt(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if last != nil && time.Since(*last) < u.verification.Interval {
+		err = model.ErrVerificationThrottled
+		span.RecordError(err)
+		return err
+	}
+
+	token, err := u.issueVerificationToken(ctx, tx, user, email)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// отправляем внутри транзакции: если доставка не удалась, токен не сохранится
+	err = u.notifier.Send(ctx, u.verificationMessage(email, token))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("send verification message: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	u.logger.Info("email verification sent", zap.String("user.id", userIDStr))
+	return nil
+}
+
+// VerifyEmail подтверждает email по токену из письма. Токен одноразовый и
+// действует, только пока адрес пользователя не изменился
+func (u *UserService) VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest,
+	client dto.ClientInfo) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.VerifyEmail")
+	defer span.End()
+
+	if req.Token == "" {
+		span.RecordError(model.ErrInvalidVerificationToken)
+		return model.ErrInvalidVerificationToken
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	token, err := u.repo.NewEmailVerificationRepo(tx).Consume(ctx, u.tm.HashToken(req.Token))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if token == nil {
+		err = model.ErrInvalidVerificationToken
+		span.RecordError(err)
+		return err
+	}
+	userID := token.UserID.String()
+
+	userRepo := u.repo.NewUserRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	profile, err := u.repo.NewProfileRepo(tx).Get(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	// токен выдан на адрес, который пользователь успел сменить
+	if contactEmail(user, profile) != token.Email {
+		err = model.ErrInvalidVerificationToken
+		span.RecordError(err)
+		return err
+	}
+
+	if user.EmailVerifiedAt == nil {
+		err = userRepo.SetEmailVerified(ctx, userID, true)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("set email verified: %w", err)
+		}
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventEmailVerified,
+		IP:        client.IP,
+		Details:   map[string]string{"email": token.Email},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	u.logger.Info("email verified", zap.String("user.id", userID))
+	return nil
+}
+
+// issueVerificationToken выпускает новый токен подтверждения для email,
+// предыдущие токены пользователя перестают действовать
+func (u *UserService) issueVerificationToken(ctx context.Context, tx repository.DBExecutor,
+	user *model.User, email string) (string, error) {
+	verificationRepo := u.repo.NewEmailVerificationRepo(tx)
+
+	err := verificationRepo.InvalidateAll(ctx, user.ID.String())
+	if err != nil {
+		return "", err
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return "", fmt.Errorf("generate verification token: %w", err)
+	}
+
+	now := time.Now()
+	err = verificationRepo.Create(ctx, &model.EmailVerificationToken{
+		TokenHash: u.tm.HashToken(token),
+		UserID:    user.ID,
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.verification.TTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (u *UserService) verificationMessage(email, token string) notifier.Message {
+	link := token
+	if u.verification.URL != "" {
+		link = u.verification.URL + "?token=" + url.QueryEscape(token)
+	}
+	return notifier.Message{
+		To:      email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Для подтверждения адреса перейдите по ссылке или используйте токен:\n\n%s\n\n"+
+			"Ссылка действует %s. Если вы не регистрировались, просто проигнорируйте это сообщение.",
+			link, u.verification.TTL),
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// IdempotencyConfig настройки ключей идемпотентности
+type IdempotencyConfig struct {
+	// сколько хранится ответ на запрос с ключом
+	TTL time.Duration
+	// через сколько незавершенный запрос считается брошенным и ключ можно занять снова
+	LockTimeout time.Duration
+	// как часто удаляются истекшие ключи
+	CleanupInterval time.Duration
+}
+
+func NewIdempotencyConfig() IdempotencyConfig {
+	cfg := IdempotencyConfig{
+		TTL:             24 * time.Hour,
+		LockTimeout:     time.Minute,
+		CleanupInterval: time.Hour,
+	}
+	if v, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil && v > 0 {
+		cfg.TTL = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_LOCK_TIMEOUT")); err == nil && v > 0 {
+		cfg.LockTimeout = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_CLEANUP_INTERVAL")); err == nil && v > 0 {
+		cfg.CleanupInterval = v
+	}
+	return cfg
+}
+
+// IdempotencyService хранит ответы на запросы с Idempotency-Key. Каждая
+// операция - один атомарный запрос, поэтому транзакции не нужны
+type IdempotencyService struct {
+	repo   *repository.Repositories
+	cfg    IdempotencyConfig
+	logger *zap.Logger
+}
+
+func NewIdempotencyService(repo *repository.Repositories, cfg IdempotencyConfig,
+	logger *zap.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Begin занимает ключ под запрос. nil - запрос нужно выполнить, иначе
+// возвращается сохраненный ответ на такой же запрос. Ключ с другим отпечатком
+// дает ErrIdempotencyKeyReused, незавершенный запрос - ErrIdempotencyInProgress
+func (s *IdempotencyService) Begin(ctx context.Context,
+	scope, key, fingerprint string) (*model.IdempotencyRecord, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "IdempotencyService.Begin")
+	defer span.End()
+
+	repo := s.repo.NewIdempotencyRepo(s.repo.Pool())
+	now := time.Now()
+	// ключ могут удалить между Reserve и Get, тогда пробуем занять еще раз
+	for range 2 {
+		reserved, err := repo.Reserve(ctx, &model.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			Fingerprint: fingerprint,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(s.cfg.TTL),
+		}, now.Add(-s.cfg.LockTimeout))
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if reserved {
+			return nil, nil
+		}
+
+		record, err := repo.Get(ctx, scope, key)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		if record == nil {
+			continue
+		}
+
+		switch {
+		case record.Fingerprint != fingerprint:
+			span.RecordError(model.ErrIdempotencyKeyReused)
+			return nil, model.ErrIdempotencyKeyReused
+		case record.StatusCode == 0:
+			span.RecordError(model.ErrIdempotencyInProgress)
+			return nil, model.ErrIdempotencyInProgress
+		}
+		span.SetAttributes(attribute.Bool("idempotency.replayed", true))
+		return record, nil
+	}
+	return nil, model.ErrIdempotencyInProgress
+}
+
+// Complete сохраняет ответ на запрос по ключу
+func (s *IdempotencyService) Complete(ctx context.Context, record *model.IdempotencyRecord) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "IdempotencyService.Complete")
+	defer span.End()
+
+	if err := s.repo.NewIdempotencyRepo(s.repo.Pool()).Complete(ctx, record); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// Release освобождает ключ, если запрос не удался и его можно повторить
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "IdempotencyService.Release")
+	defer span.End()
+
+	if err := s.repo.NewIdempotencyRepo(s.repo.Pool()).Delete(ctx, scope, key); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// RunCleanupWorker периодически удаляет истекшие ключи
+func (s *IdempotencyService) RunCleanupWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n, err := s.repo.NewIdempotencyRepo(s.repo.Pool()).DeleteExpired(ctx)
+			if err != nil {
+				s.logger.Error("idempotency keys cleanup failed", zap.Error(err))
+			}
+			if n > 0 {
+				s.logger.Info("expired idempotency keys deleted", zap.Int64("count", n))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
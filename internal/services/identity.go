@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/identity"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// сколько живет начатый вход через внешний провайдер
+const externalLoginTTL = 10 * time.Minute
+
+// StartExternalLogin начинает вход через внешний провайдер и возвращает адрес
+// его страницы входа
+func (u *UserService) StartExternalLogin(ctx context.Context, provider string) (dto.ExternalLoginResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.StartExternalLogin")
+	defer span.End()
+
+	resp, err := u.startExternal(ctx, provider, nil)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ExternalLoginResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("identity.provider", provider))
+	return resp, nil
+}
+
+// StartIdentityLink начинает привязку внешнего провайдера к текущему аккаунту
+func (u *UserService) StartIdentityLink(ctx context.Context, provider string) (dto.ExternalLoginResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.StartIdentityLink")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.ExternalLoginResponse{}, err
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ExternalLoginResponse{}, err
+	}
+
+	resp, err := u.startExternal(ctx, provider, &userID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ExternalLoginResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr), attribute.String("identity.provider", provider))
+	return resp, nil
+}
+
+func (u *UserService) startExternal(ctx context.Context, provider string,
+	userID *uuid.UUID) (dto.ExternalLoginResponse, error) {
+	p, ok := u.providers[provider]
+	if !ok {
+		return dto.ExternalLoginResponse{}, model.ErrUnknownProvider
+	}
+
+	state, err := identity.NewRandomToken()
+	if err != nil {
+		return dto.ExternalLoginResponse{}, fmt.Errorf("generate state: %w", err)
+	}
+	nonce, err := identity.NewRandomToken()
+	if err != nil {
+		return dto.ExternalLoginResponse{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	verifier, err := identity.NewRandomToken()
+	if err != nil {
+		return dto.ExternalLoginResponse{}, fmt.Errorf("generate code verifier: %w", err)
+	}
+
+	authURL, err := p.AuthURL(ctx, state, nonce, identity.CodeChallenge(verifier))
+	if err != nil {
+		u.logger.Error("can't build provider auth url", zap.String("provider", provider), zap.Error(err))
+		return dto.ExternalLoginResponse{}, err
+	}
+
+	now := time.Now()
+	err = u.repo.NewExternalLoginStateRepo(u.repo.Pool()).Create(ctx, &model.ExternalLoginState{
+		StateHash:    u.tm.HashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(externalLoginTTL),
+	})
+	if err != nil {
+		return dto.ExternalLoginResponse{}, err
+	}
+
+	return dto.ExternalLoginResponse{AuthURL: authURL, State: state}, nil
+}
+
+// CompleteExternalLogin завершает вход через внешний провайдер. Известная
+// учетная запись провайдера входит в привязанный аккаунт, новая - привязывается
+// к аккаунту, начавшему привязку, или получает новый аккаунт
+func (u *UserService) CompleteExternalLogin(ctx context.Context, provider string,
+	req dto.ExternalCallbackRequest, client dto.ClientInfo) (dto.AuthResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.CompleteExternalLogin")
+	defer span.End()
+
+	p, ok := u.providers[provider]
+	if !ok {
+		span.RecordError(model.ErrUnknownProvider)
+		return dto.AuthResponse{}, model.ErrUnknownProvider
+	}
+	if req.Code == "" || req.State == "" {
+		span.RecordError(model.ErrInvalidLoginState)
+		return dto.AuthResponse{}, model.ErrInvalidLoginState
+	}
+
+	// state одноразовый: удаляем до обмена кода, чтобы его нельзя было повторить
+	state, err := u.repo.NewExternalLoginStateRepo(u.repo.Pool()).Consume(ctx, provider,
+		u.tm.HashToken(req.State))
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+	if state == nil {
+		err = model.ErrInvalidLoginState
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	ident, err := p.Exchange(ctx, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		u.logger.Warn("external authentication failed", zap.String("provider", provider), zap.Error(err))
+		span.RecordError(err)
+		if errors.Is(err, identity.ErrExchangeFailed) {
+			return dto.AuthResponse{}, fmt.Errorf("%w: %w", model.ErrExternalAuthFailed, err)
+		}
+		return dto.AuthResponse{}, err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	user, err := u.resolveIdentity(ctx, tx, ident, state.UserID, client)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	if user.BannedAt != nil {
+		err = model.ErrUserBanned
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	// 2FA аккаунта действует и при входе через провайдер
+	mfa, err := u.repo.NewMFARepo(tx).Get(ctx, user.ID.String())
+	if err != nil {
+		u.logger.Error("failed to get user mfa", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+	if mfa.Enabled() {
+		var mfaToken string
+		mfaToken, err = u.tm.GenerateMFAToken(user.ID)
+		if err != nil {
+			u.logger.Error("error while creating mfa token", zap.Error(err))
+			return dto.AuthResponse{}, err
+		}
+		span.SetAttributes(attribute.Bool("auth.mfa_required", true))
+		return dto.AuthResponse{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	err = u.cancelAccountDeletion(ctx, tx, user, client)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	resp, err := u.startSession(ctx, u.repo.NewSessionRepo(tx), user, client)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", user.ID.String()), attribute.String("identity.provider", provider))
+	u.logger.Info("user authenticated with external provider", zap.String("user.id", user.ID.String()),
+		zap.String("provider", provider))
+	return resp, nil
+}
+
+// resolveIdentity находит или создает аккаунт для учетной записи провайдера.
+// linkUserID - аккаунт, к которому пользователь привязывает провайдер
+func (u *UserService) resolveIdentity(ctx context.Context, tx repository.DBExecutor, ident *identity.Identity,
+	linkUserID *uuid.UUID, client dto.ClientInfo) (*model.User, error) {
+	identityRepo := u.repo.NewIdentityRepo(tx)
+	userRepo := u.repo.NewUserRepo(tx)
+
+	linked, err := identityRepo.Get(ctx, ident.Provider, ident.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		if linkUserID != nil && *linkUserID != linked.UserID {
+			return nil, model.ErrIdentityLinked
+		}
+		if err := identityRepo.TouchLastLogin(ctx, ident.Provider, ident.Subject); err != nil {
+			return nil, err
+		}
+		return userRepo.GetByID(ctx, linked.UserID.String())
+	}
+
+	var user *model.User
+	if linkUserID != nil {
+		user, err = userRepo.GetByID(ctx, linkUserID.String())
+		if err != nil {
+			return nil, fmt.Errorf("get user: %w", err)
+		}
+	} else {
+		user, err = u.provisionExternalUser(ctx, tx, ident)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	link := &model.UserIdentity{
+		Provider:    ident.Provider,
+		Subject:     ident.Subject,
+		UserID:      user.ID,
+		CreatedAt:   now,
+		LastLoginAt: &now,
+	}
+	if ident.Email != "" {
+		link.Email = &ident.Email
+	}
+	if err := identityRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventIdentityLinked,
+		IP:        client.IP,
+		Details:   map[string]string{"provider": ident.Provider, "subject": ident.Subject},
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create audit event: %w", err)
+	}
+	return user, nil
+}
+
+// provisionExternalUser создает аккаунт без пароля. Логином становится
+// подтвержденный провайдером email, иначе provider:subject. Существующий
+// аккаунт с тем же логином автоматически не привязывается: его владелец
+// должен войти и привязать провайдер сам
+func (u *UserService) provisionExternalUser(ctx context.Context, tx repository.DBExecutor,
+	ident *identity.Identity) (*model.User, error) {
+	userRepo := u.repo.NewUserRepo(tx)
+
+	login := ident.Provider + ":" + ident.Subject
+	email, emailErr := dto.ParseEmail(ident.Email)
+	verified := ident.EmailVerified && emailErr == nil
+	if verified {
+		login = email
+	}
+
+	_, err := userRepo.GetByLogin(ctx, login)
+	if err == nil {
+		return nil, model.ErrIdentityConflict
+	}
+	if !errors.Is(err, repository.ErrNoUser) {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	user := &model.User{
+		ID:        uuid.New(),
+		Login:     login,
+		Balance:   decimal.Zero,
+		Withdrawn: decimal.Zero,
+	}
+	if err := userRepo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	if verified {
+		if err := userRepo.SetEmailVerified(ctx, user.ID.String(), true); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	u.logger.Info("user provisioned from external provider", zap.String("user.id", user.ID.String()),
+		zap.String("provider", ident.Provider))
+	return user, nil
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+)
+
+type AccountServiceInterface interface {
+	Export(ctx context.Context) ([]byte, error)
+	RequestDeletion(ctx context.Context, req dto.DeleteAccountRequest,
+		client dto.ClientInfo) (dto.DeleteAccountResponse, error)
+}
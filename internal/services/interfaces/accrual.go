@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+// AccrualProvider источник статуса и начисления для заказа, ждущего расчета
+type AccrualProvider interface {
+	GetAccrual(ctx context.Context, order model.Order) (dto.AccrualServiceResponse, error)
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type APIKeyServiceInterface interface {
+	Create(ctx context.Context, req dto.CreateAPIKeyRequest) (dto.CreateAPIKeyResponse, error)
+	List(ctx context.Context) (dto.GetAPIKeysResponse, error)
+	Rotate(ctx context.Context, keyID string) (dto.CreateAPIKeyResponse, error)
+	Revoke(ctx context.Context, keyID string) error
+	Authenticate(ctx context.Context, rawKey string) (*model.APIKey, error)
+}
+
+type PartnerServiceInterface interface {
+	LoadOrder(ctx context.Context, req dto.PartnerOrderRequest) (dto.AddOrderResponse, error)
+	Redeem(ctx context.Context, req dto.PartnerRedemptionRequest) error
+	ReturnOrder(ctx context.Context, orderNumber string) (dto.CancelOrderResponse, error)
+	SaveReceipt(ctx context.Context, orderNumber string, req dto.ReceiptRequest) (dto.ReceiptResponse, error)
+}
@@ -9,5 +9,6 @@ import (
 type BalanceServiceInterface interface {
 	GetBalance(ctx context.Context) (dto.GetBalanceResponse, error)
 	Withdraw(ctx context.Context, req dto.NewWithdrawnRequest) error
-	GetWithdrawals(ctx context.Context) (dto.GetAllWithdrawalsResponse, error)
+	GetWithdrawals(ctx context.Context, merchantID string) (dto.GetAllWithdrawalsResponse, error)
+	IssueRedemptionCode(ctx context.Context) (dto.RedemptionCodeResponse, error)
 }
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+)
+
+type MerchantServiceInterface interface {
+	Create(ctx context.Context, req dto.CreateMerchantRequest) (dto.MerchantResponse, error)
+	Get(ctx context.Context, id string) (dto.MerchantResponse, error)
+	List(ctx context.Context) (dto.GetMerchantsResponse, error)
+	Update(ctx context.Context, id string, req dto.UpdateMerchantRequest) (dto.MerchantResponse, error)
+	Delete(ctx context.Context, id string) error
+	Report(ctx context.Context, id string, req dto.MerchantReportRequest) (dto.MerchantReportResponse, error)
+}
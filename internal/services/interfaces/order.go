@@ -3,10 +3,18 @@ package interfaces
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 )
 
 type OrderServiceInterface interface {
-	Load(ctx context.Context, orderNumber string) (dto.AddOrderResponse, error)
-	GetAll(ctx context.Context, userID string) (dto.GetAllOrdersResponse, error)
+	Load(ctx context.Context, orderNumber, merchantID string) (dto.AddOrderResponse, error)
+	LoadBatch(ctx context.Context, userID string, numbers []string, merchantID string) (dto.BatchOrdersResponse, error)
+	GetAll(ctx context.Context, userID string, req dto.ListOrdersRequest) (dto.GetAllOrdersResponse, error)
+	GetOrder(ctx context.Context, userID, orderNumber string) (dto.OrderDetailResponse, error)
+	GetOrderForSupport(ctx context.Context, orderNumber string) (dto.OrderDetailResponse, error)
+	CancelOrder(ctx context.Context, userID, orderNumber string) (dto.CancelOrderResponse, error)
+	ReturnOrder(ctx context.Context, orderNumber string, actorID uuid.UUID) (dto.CancelOrderResponse, error)
+	SaveReceipt(ctx context.Context, orderNumber string, req dto.ReceiptRequest) (dto.ReceiptResponse, error)
 }
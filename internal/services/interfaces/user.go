@@ -4,10 +4,33 @@ import (
 	"context"
 
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 )
 
 type UserServiceInterface interface {
-	Register(ctx context.Context, credentials dto.RegisterRequest) (dto.AuthResponse, error)
-	Auth(ctx context.Context, credentials dto.AuthRequest) (dto.AuthResponse, error)
-	GetNewAccessToken(ctx context.Context, refresh string) (dto.RefreshResponse, error)
+	Register(ctx context.Context, credentials dto.RegisterRequest, client dto.ClientInfo) (dto.AuthResponse, error)
+	Auth(ctx context.Context, credentials dto.AuthRequest, client dto.ClientInfo) (dto.AuthResponse, error)
+	GetNewAccessToken(ctx context.Context, refresh string, client dto.ClientInfo) (dto.RefreshResponse, error)
+	Logout(ctx context.Context, refresh string) error
+	GetSessions(ctx context.Context) (dto.GetSessionsResponse, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeOtherSessions(ctx context.Context, refresh string) error
+	UnlockAccount(ctx context.Context, login string) error
+	SetUserRole(ctx context.Context, userID string, role model.Role) error
+	SetUserBanned(ctx context.Context, userID string, banned bool) error
+	ChangePassword(ctx context.Context, req dto.ChangePasswordRequest, client dto.ClientInfo) error
+	ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req dto.ResetPasswordRequest, client dto.ClientInfo) error
+	ResendVerification(ctx context.Context) error
+	VerifyEmail(ctx context.Context, req dto.VerifyEmailRequest, client dto.ClientInfo) error
+	StartExternalLogin(ctx context.Context, provider string) (dto.ExternalLoginResponse, error)
+	StartIdentityLink(ctx context.Context, provider string) (dto.ExternalLoginResponse, error)
+	CompleteExternalLogin(ctx context.Context, provider string, req dto.ExternalCallbackRequest,
+		client dto.ClientInfo) (dto.AuthResponse, error)
+	GetProfile(ctx context.Context) (dto.ProfileResponse, error)
+	UpdateProfile(ctx context.Context, req dto.UpdateProfileRequest) (dto.ProfileResponse, error)
+	EnrollMFA(ctx context.Context) (dto.MFAEnrollResponse, error)
+	ConfirmMFA(ctx context.Context, code string, client dto.ClientInfo) error
+	DisableMFA(ctx context.Context, req dto.MFADisableRequest, client dto.ClientInfo) error
+	VerifyMFA(ctx context.Context, req dto.MFAVerifyRequest, client dto.ClientInfo) (dto.AuthResponse, error)
 }
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/metrics"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// checkLockout учитывает попытку входа для логина и IP адреса до проверки
+// пароля или кода. Проверка задержки и инкремент счетчика выполняются одним
+// запросом, поэтому параллельные попытки не проходят в одно окно. Если попытка
+// сейчас запрещена, возвращает *model.LockoutError. Успешную попытку нужно
+// вернуть через releaseAttempt
+func (u *UserService) checkLockout(ctx context.Context, login, ip string) error {
+	attemptRepo := u.repo.NewAuthAttemptRepo(u.repo.Pool())
+	now := time.Now()
+
+	var acquired []guardKey
+	var retryAfter time.Duration
+	for _, gk := range guardKeys(login, ip) {
+		policy := u.policy(gk.scope)
+		attempt, err := attemptRepo.Acquire(ctx, gk.key, policy, now)
+		if err != nil {
+			return fmt.Errorf("acquire auth attempt: %w", err)
+		}
+		if attempt != nil {
+			acquired = append(acquired, gk)
+			continue
+		}
+
+		metrics.AuthThrottledTotal.WithLabelValues(gk.scope).Inc()
+		attempt, err = attemptRepo.Get(ctx, gk.key)
+		if err != nil {
+			return fmt.Errorf("get auth attempt: %w", err)
+		}
+		// счетчик мог сброситься между запросами, поэтому ждать хотя бы секунду
+		retryAfter = max(retryAfter, policy.RetryAfter(attempt, now), time.Second)
+	}
+
+	if retryAfter == 0 {
+		return nil
+	}
+
+	// отклоненная попытка не должна расходовать лимит другого ключа
+	for _, gk := range acquired {
+		if err := attemptRepo.Release(ctx, gk.key, u.policy(gk.scope)); err != nil {
+			return fmt.Errorf("release auth attempt: %w", err)
+		}
+	}
+	return &model.LockoutError{RetryAfter: retryAfter}
+}
+
+// releaseAttempt возвращает попытку, учтенную checkLockout, когда она оказалась
+// успешной. С resetLogin счетчик логина сбрасывается целиком
+func (u *UserService) releaseAttempt(ctx context.Context, exec repository.DBExecutor,
+	login, ip string, resetLogin bool) error {
+	attemptRepo := u.repo.NewAuthAttemptRepo(exec)
+
+	for _, gk := range guardKeys(login, ip) {
+		if resetLogin && gk.scope == loginguard.ScopeLogin {
+			if _, err := attemptRepo.Delete(ctx, gk.key); err != nil {
+				return fmt.Errorf("reset auth attempts: %w", err)
+			}
+			continue
+		}
+		if err := attemptRepo.Release(ctx, gk.key, u.policy(gk.scope)); err != nil {
+			return fmt.Errorf("release auth attempt: %w", err)
+		}
+	}
+	return nil
+}
+
+// registerAuthFailure фиксирует неудачную попытку, уже учтенную checkLockout:
+// пишет метрики и, если попытка привела к блокировке, событие аудита.
+// Возвращает ошибку, которую нужно отдать клиенту
+func (u *UserService) registerAuthFailure(ctx context.Context, userID *uuid.UUID, login, ip string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.registerAuthFailure")
+	defer span.End()
+
+	// транзакция самого входа откатывается, поэтому пишем вне ее
+	attemptRepo := u.repo.NewAuthAttemptRepo(u.repo.Pool())
+	auditRepo := u.repo.NewAuditRepo(u.repo.Pool())
+	now := time.Now()
+
+	var lockout *model.LockoutError
+	for _, gk := range guardKeys(login, ip) {
+		scope, key := gk.scope, gk.key
+		metrics.AuthFailedAttemptsTotal.WithLabelValues(scope).Inc()
+
+		attempt, err := attemptRepo.Get(ctx, key)
+		if err != nil {
+			span.RecordError(err)
+			return model.ErrInvalidCredentials
+		}
+		if attempt == nil || attempt.LockedUntil == nil || !attempt.LockedUntil.After(now) {
+			continue
+		}
+
+		metrics.AuthLockoutsTotal.WithLabelValues(scope).Inc()
+		lockout = &model.LockoutError{RetryAfter: attempt.LockedUntil.Sub(now)}
+		u.logger.Warn("auth locked out", zap.String("scope", scope), zap.String("key", key),
+			zap.Int("failures", attempt.Failures))
+
+		err = auditRepo.Create(ctx, &model.AuditEvent{
+			ID:     uuid.New(),
+			UserID: userID,
+			Type:   model.AuditEventAccountLocked,
+			IP:     ip,
+			Details: map[string]string{
+				"scope":        scope,
+				"key":          key,
+				"locked_until": attempt.LockedUntil.Format(time.RFC3339),
+			},
+			CreatedAt: now,
+		})
+		if err != nil {
+			span.RecordError(err)
+			return model.ErrInvalidCredentials
+		}
+	}
+
+	span.SetAttributes(attribute.Bool("auth.locked", lockout != nil))
+	if lockout != nil {
+		return lockout
+	}
+	return model.ErrInvalidCredentials
+}
+
+type guardKey struct {
+	scope string
+	key   string
+}
+
+// guardKeys счетчики, которые учитываются при входе: по логину и по IP
+func guardKeys(login, ip string) []guardKey {
+	return []guardKey{
+		{scope: loginguard.ScopeLogin, key: loginguard.LoginKey(login)},
+		{scope: loginguard.ScopeIP, key: loginguard.IPKey(ip)},
+	}
+}
+
+func (u *UserService) policy(scope string) loginguard.Policy {
+	if scope == loginguard.ScopeIP {
+		return u.guard.IP
+	}
+	return u.guard.Login
+}
+
+// UnlockAccount снимает блокировку входа для логина
+func (u *UserService) UnlockAccount(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.UnlockAccount")
+	defer span.End()
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		span.RecordError(err)
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	deleted, err := u.repo.NewAuthAttemptRepo(tx).Delete(ctx, loginguard.LoginKey(login))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete auth attempt: %w", err)
+	}
+	if !deleted {
+		return model.ErrNotLocked
+	}
+
+	// логин может и не существовать - блокировка ставится и на неизвестные логины
+	var userID *uuid.UUID
+	user, err := u.repo.NewUserRepo(tx).GetByLogin(ctx, login)
+	switch {
+	case err == nil:
+		userID = &user.ID
+	case !errors.Is(err, repository.ErrNoUser):
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      model.AuditEventAccountUnlocked,
+		Details:   map[string]string{"login": login},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	u.logger.Info("account unlocked", zap.String("login", login))
+	return nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// MerchantService управление магазинами партнеров для администраторов
+type MerchantService struct {
+	repo   *repository.Repositories
+	logger *zap.Logger
+}
+
+func NewMerchantService(repo *repository.Repositories, logger *zap.Logger) *MerchantService {
+	return &MerchantService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *MerchantService) Create(ctx context.Context, req dto.CreateMerchantRequest) (dto.MerchantResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.Create")
+	defer span.End()
+
+	merchant, err := req.Validate(time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+		_ = tx.Commit(ctx)
+	}()
+
+	if err = s.checkOwner(ctx, tx, merchant.OwnerID); err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	if err = s.repo.NewMerchantRepo(tx).Create(ctx, merchant); err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", merchant.ID.String()))
+	return dto.NewMerchantResponse(merchant), nil
+}
+
+func (s *MerchantService) Get(ctx context.Context, id string) (dto.MerchantResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.Get")
+	defer span.End()
+
+	merchant, err := s.repo.NewMerchantRepo(s.repo.Pool()).GetByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+	return dto.NewMerchantResponse(merchant), nil
+}
+
+func (s *MerchantService) List(ctx context.Context) (dto.GetMerchantsResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.List")
+	defer span.End()
+
+	merchants, err := s.repo.NewMerchantRepo(s.repo.Pool()).List(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return dto.GetMerchantsResponse{}, err
+	}
+
+	resp := dto.GetMerchantsResponse{Merchants: make([]dto.MerchantResponse, 0, len(merchants))}
+	for i := range merchants {
+		resp.Merchants = append(resp.Merchants, dto.NewMerchantResponse(&merchants[i]))
+	}
+	return resp, nil
+}
+
+func (s *MerchantService) Update(ctx context.Context, id string,
+	req dto.UpdateMerchantRequest) (dto.MerchantResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.Update")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.RepeatableRead)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+		_ = tx.Commit(ctx)
+	}()
+
+	merchantRepo := s.repo.NewMerchantRepo(tx)
+	merchant, err := merchantRepo.GetByID(ctx, id)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	req.Apply(merchant, time.Now())
+	if req.OwnerID != nil {
+		if err = s.checkOwner(ctx, tx, merchant.OwnerID); err != nil {
+			span.RecordError(err)
+			return dto.MerchantResponse{}, err
+		}
+	}
+
+	if err = merchantRepo.Update(ctx, merchant); err != nil {
+		span.RecordError(err)
+		return dto.MerchantResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id))
+	return dto.NewMerchantResponse(merchant), nil
+}
+
+// Delete удаляет мерчанта, заказы и списания остаются без привязки к магазину
+func (s *MerchantService) Delete(ctx context.Context, id string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.Delete")
+	defer span.End()
+
+	if err := s.repo.NewMerchantRepo(s.repo.Pool()).Delete(ctx, id); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id))
+	return nil
+}
+
+// Report сводка по заказам и списаниям мерчанта за период
+func (s *MerchantService) Report(ctx context.Context, id string,
+	req dto.MerchantReportRequest) (dto.MerchantReportResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "MerchantService.Report")
+	defer span.End()
+
+	from, to, err := req.Range()
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantReportResponse{}, err
+	}
+
+	// отчет и проверка мерчанта из одного снимка
+	tx, err := s.repo.BeginTx(ctx, pgx.RepeatableRead)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantReportResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+		_ = tx.Commit(ctx)
+	}()
+
+	merchantRepo := s.repo.NewMerchantRepo(tx)
+	if _, err = merchantRepo.GetByID(ctx, id); err != nil {
+		span.RecordError(err)
+		return dto.MerchantReportResponse{}, err
+	}
+
+	report, err := merchantRepo.Report(ctx, id, from, to)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MerchantReportResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("merchant.id", id), attribute.Int("orders_count", report.Orders))
+	return dto.NewMerchantReportResponse(id, from, to, report), nil
+}
+
+// checkOwner владельцем магазина может быть только пользователь с ролью merchant
+func (s *MerchantService) checkOwner(ctx context.Context, tx repository.DBExecutor, ownerID *uuid.UUID) error {
+	if ownerID == nil {
+		return nil
+	}
+	user, err := s.repo.NewUserRepo(tx).GetByID(ctx, ownerID.String())
+	if err != nil {
+		if errors.Is(err, repository.ErrNoUser) {
+			return model.ErrInvalidMerchantOwner
+		}
+		return fmt.Errorf("get user: %w", err)
+	}
+	if user.Role != model.RoleMerchant {
+		return model.ErrInvalidMerchantOwner
+	}
+	return nil
+}
+
+// resolveMerchant магазин, к которому относится операция. Для запросов по API
+// ключу это магазин владельца ключа, иначе магазин из merchantID, если он задан
+func resolveMerchant(ctx context.Context, merchants interfaces.MerchantRepository,
+	merchantID string) (*model.Merchant, error) {
+	if key, ok := ctx.Value(contextkeys.APIKey).(*model.APIKey); ok && key != nil {
+		return merchants.GetByOwner(ctx, key.UserID.String())
+	}
+	if merchantID == "" {
+		return nil, nil
+	}
+	if _, err := uuid.Parse(merchantID); err != nil {
+		return nil, dto.ErrInvalidMerchantID
+	}
+	return merchants.GetByID(ctx, merchantID)
+}
+
+func merchantRef(m *model.Merchant) *uuid.UUID {
+	if m == nil {
+		return nil
+	}
+	return &m.ID
+}
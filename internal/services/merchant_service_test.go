@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/ordernumber"
+)
+
+// fakeMerchantRepo мерчанты в памяти, остальные методы не используются
+type fakeMerchantRepo struct {
+	interfaces.MerchantRepository
+	merchants []model.Merchant
+}
+
+func (r *fakeMerchantRepo) GetByID(_ context.Context, id string) (*model.Merchant, error) {
+	for i := range r.merchants {
+		if r.merchants[i].ID.String() == id {
+			return &r.merchants[i], nil
+		}
+	}
+	return nil, model.ErrMerchantNotFound
+}
+
+func (r *fakeMerchantRepo) GetByOwner(_ context.Context, ownerID string) (*model.Merchant, error) {
+	for i := range r.merchants {
+		if r.merchants[i].OwnerID != nil && r.merchants[i].OwnerID.String() == ownerID {
+			return &r.merchants[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func withAPIKey(ctx context.Context, userID uuid.UUID) context.Context {
+	return context.WithValue(ctx, contextkeys.APIKey, &model.APIKey{ID: uuid.New(), UserID: userID})
+}
+
+func TestResolveMerchant(t *testing.T) {
+	owner := uuid.New()
+	shop := model.Merchant{ID: uuid.New(), Name: "Coffee Point", OwnerID: &owner}
+	other := model.Merchant{ID: uuid.New(), Name: "Bakery"}
+	repo := &fakeMerchantRepo{merchants: []model.Merchant{shop, other}}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		merchantID string
+		want       *uuid.UUID
+		err        error
+	}{
+		{name: "no merchant", ctx: context.Background()},
+		{name: "explicit merchant", ctx: context.Background(), merchantID: other.ID.String(), want: &other.ID},
+		{name: "bad merchant id", ctx: context.Background(), merchantID: "shop-1", err: dto.ErrInvalidMerchantID},
+		{name: "unknown merchant", ctx: context.Background(), merchantID: uuid.NewString(), err: model.ErrMerchantNotFound},
+		{name: "api key owner", ctx: withAPIKey(context.Background(), owner), want: &shop.ID},
+		// мерчант ключа важнее переданного в запросе
+		{name: "api key ignores explicit id", ctx: withAPIKey(context.Background(), owner),
+			merchantID: other.ID.String(), want: &shop.ID},
+		{name: "api key without merchant", ctx: withAPIKey(context.Background(), uuid.New())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := resolveMerchant(tt.ctx, repo, tt.merchantID)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			got := merchantRef(m)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("expected merchant %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOrderServiceValidator(t *testing.T) {
+	owner := uuid.New()
+	keyOwner := uuid.New()
+	byID := model.Merchant{ID: uuid.New()}
+	gtin, err := ordernumber.Parse("gtin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	luhn, err := ordernumber.Parse("luhn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os := &OrderService{validators: ordernumber.NewRegistry(luhn, map[uuid.UUID]ordernumber.OrderNumberValidator{
+		byID.ID:  gtin,
+		owner:    gtin,
+		keyOwner: gtin,
+	})}
+
+	// номер проходит gtin, но не luhn
+	const number = "4006381333931"
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		merchant *model.Merchant
+		gtin     bool
+	}{
+		{name: "no merchant", ctx: context.Background()},
+		{name: "merchant card rules", ctx: context.Background(),
+			merchant: &model.Merchant{ID: uuid.New(), OrderNumberValidator: "gtin"}, gtin: true},
+		{name: "rules by merchant id", ctx: context.Background(), merchant: &byID, gtin: true},
+		{name: "legacy rules by owner id", ctx: context.Background(),
+			merchant: &model.Merchant{ID: uuid.New(), OwnerID: &owner}, gtin: true},
+		{name: "merchant without rules", ctx: context.Background(), merchant: &model.Merchant{ID: uuid.New()}},
+		{name: "api key without merchant", ctx: withAPIKey(context.Background(), keyOwner), gtin: true},
+		{name: "api key without rules", ctx: withAPIKey(context.Background(), uuid.New())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := os.validator(tt.ctx, tt.merchant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := v.Validate(number); (err == nil) != tt.gtin {
+				t.Errorf("expected gtin rules %v, got validation error %v", tt.gtin, err)
+			}
+		})
+	}
+}
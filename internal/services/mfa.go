@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/totp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	mfaIssuer = "LoyaltyHub"
+	// допуск в шагах TOTP на расхождение часов
+	mfaSkew            = 1
+	recoveryCodesCount = 10
+)
+
+// EnrollMFA выдает новый секрет TOTP и коды восстановления. 2FA включается
+// только после подтверждения кодом в ConfirmMFA
+func (u *UserService) EnrollMFA(ctx context.Context) (dto.MFAEnrollResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.EnrollMFA")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.MFAEnrollResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	mfaRepo := u.repo.NewMFARepo(tx)
+
+	user, err := u.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, fmt.Errorf("generate secret: %w", err)
+	}
+
+	err = mfaRepo.SavePending(ctx, &model.UserMFA{
+		UserID:    user.ID,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, err
+	}
+
+	codes, hashes, err := u.generateRecoveryCodes()
+	if err != nil {
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, fmt.Errorf("generate recovery codes: %w", err)
+	}
+	err = mfaRepo.ReplaceRecoveryCodes(ctx, userIDStr, hashes)
+	if err != nil {
+		span.RecordError(err)
+		return dto.MFAEnrollResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	u.logger.Info("mfa enrollment started", zap.String("user.id", userIDStr))
+	return dto.MFAEnrollResponse{
+		Secret:        secret,
+		URI:           totp.URI(mfaIssuer, user.Login, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmMFA включает 2FA, если код из приложения совпал с выданным секретом
+func (u *UserService) ConfirmMFA(ctx context.Context, code string, client dto.ClientInfo) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.ConfirmMFA")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	mfaRepo := u.repo.NewMFARepo(tx)
+
+	mfa, err := mfaRepo.GetForUpdate(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	switch {
+	case mfa == nil:
+		err = model.ErrMFANotEnrolled
+	case mfa.Enabled():
+		err = model.ErrMFAAlreadyEnabled
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now(), mfaSkew)
+	if !ok {
+		err = model.ErrInvalidMFACode
+		span.RecordError(err)
+		return err
+	}
+
+	err = mfaRepo.Enable(ctx, userIDStr, step)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &mfa.UserID,
+		Type:      model.AuditEventMFAEnabled,
+		IP:        client.IP,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	u.logger.Info("mfa enabled", zap.String("user.id", userIDStr))
+	return nil
+}
+
+// DisableMFA отключает 2FA. Требует пароль (для аккаунта без пароля - недавний
+// вход через провайдер) и действующий код
+func (u *UserService) DisableMFA(ctx context.Context, req dto.MFADisableRequest, client dto.ClientInfo) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.DisableMFA")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	mfaRepo := u.repo.NewMFARepo(tx)
+
+	user, err := u.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	// перебор пароля и кодов учитываем так же, как при входе
+	if err := u.checkLockout(ctx, user.Login, client.IP); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	mfa, err := mfaRepo.GetForUpdate(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if !mfa.Enabled() {
+		err = model.ErrMFANotEnabled
+		span.RecordError(err)
+		return err
+	}
+
+	if err := reauthenticate(ctx, u.repo.NewSessionRepo(tx), user, req.Password, time.Now()); err != nil {
+		span.RecordError(err)
+		if errors.Is(err, model.ErrInvalidCredentials) {
+			return u.registerAuthFailure(ctx, &user.ID, user.Login, client.IP)
+		}
+		return err
+	}
+
+	_, err = u.verifyMFACode(ctx, mfaRepo, mfa, req.Code)
+	if errors.Is(err, model.ErrInvalidMFACode) {
+		span.RecordError(err)
+		err = nil
+		return mfaFailure(u.registerAuthFailure(ctx, &user.ID, user.Login, client.IP))
+	}
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	err = mfaRepo.Delete(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	err = u.releaseAttempt(ctx, tx, user.Login, client.IP, false)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventMFADisabled,
+		IP:        client.IP,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	u.logger.Info("mfa disabled", zap.String("user.id", userIDStr))
+	return nil
+}
+
+// VerifyMFA второй шаг входа: обменивает mfa_token и код на пару токенов
+func (u *UserService) VerifyMFA(ctx context.Context, req dto.MFAVerifyRequest,
+	client dto.ClientInfo) (dto.AuthResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.VerifyMFA")
+	defer span.End()
+
+	userID, err := u.tm.ParseMFAToken(req.MFAToken)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, model.ErrInvalidMFAToken
+	}
+
+	user, err := u.repo.NewUserRepo(u.repo.Pool()).GetByID(ctx, userID.String())
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	if err := u.checkLockout(ctx, user.Login, client.IP); err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	mfaRepo := u.repo.NewMFARepo(tx)
+
+	mfa, err := mfaRepo.GetForUpdate(ctx, userID.String())
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+	// 2FA могли отключить, пока пользователь вводил код
+	if !mfa.Enabled() {
+		err = model.ErrInvalidMFAToken
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	recovery, err := u.verifyMFACode(ctx, mfaRepo, mfa, req.Code)
+	if errors.Is(err, model.ErrInvalidMFACode) {
+		span.RecordError(err)
+		err = nil
+		return dto.AuthResponse{}, mfaFailure(u.registerAuthFailure(ctx, &user.ID, user.Login, client.IP))
+	}
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	if recovery {
+		err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+			ID:        uuid.New(),
+			UserID:    &user.ID,
+			Type:      model.AuditEventMFARecoveryCodeUsed,
+			IP:        client.IP,
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			span.RecordError(err)
+			return dto.AuthResponse{}, fmt.Errorf("create audit event: %w", err)
+		}
+	}
+
+	// успешный вход сбрасывает счетчик неудач по логину
+	err = u.releaseAttempt(ctx, tx, user.Login, client.IP, true)
+	if err != nil {
+		u.logger.Error("failed to reset auth attempts", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+
+	err = u.cancelAccountDeletion(ctx, tx, user, client)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	resp, err := u.startSession(ctx, u.repo.NewSessionRepo(tx), user, client)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", user.ID.String()), attribute.Bool("mfa.recovery", recovery))
+	u.logger.Info("user authenticated with mfa", zap.String("user.id", user.ID.String()))
+	return resp, nil
+}
+
+// verifyMFACode проверяет код из приложения или код восстановления.
+// Возвращает true, если был использован код восстановления
+func (u *UserService) verifyMFACode(ctx context.Context, mfaRepo interfaces.MFARepository,
+	mfa *model.UserMFA, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(mfa.Secret, code, time.Now(), mfaSkew)
+		// код с уже использованного шага повторно не принимаем
+		if !ok || step <= mfa.LastUsedStep {
+			return false, model.ErrInvalidMFACode
+		}
+		if err := mfaRepo.UpdateLastUsedStep(ctx, mfa.UserID.String(), step); err != nil {
+			return false, err
+		}
+		return false, nil
+	}
+
+	used, err := mfaRepo.UseRecoveryCode(ctx, mfa.UserID.String(), u.tm.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, err
+	}
+	if !used {
+		return false, model.ErrInvalidMFACode
+	}
+	return true, nil
+}
+
+// mfaFailure подменяет ошибку неверного пароля на ошибку неверного кода,
+// блокировку оставляет как есть
+func mfaFailure(err error) error {
+	if errors.Is(err, model.ErrInvalidCredentials) {
+		return model.ErrInvalidMFACode
+	}
+	return err
+}
+
+// generateRecoveryCodes возвращает коды вида xxxxx-xxxxx и их хеши для хранения
+func (u *UserService) generateRecoveryCodes() ([]string, []string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+	for range recoveryCodesCount {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, u.tm.HashToken(raw))
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// код ошибки Postgres, когда serializable транзакция конфликтует с параллельной
+	pgSerializationFailure = "40001"
+	// сколько раз выполняется отмена, если транзакция не прошла из-за конфликта
+	cancelAttempts = 3
+)
+
+// CancelOrder отменяет заказ пользователя, пока начисление еще не рассчитано
+func (os *OrderService) CancelOrder(ctx context.Context,
+	userID, orderNumber string) (dto.CancelOrderResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.CancelOrder")
+	defer span.End()
+
+	actorID, err := uuid.Parse(userID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CancelOrderResponse{}, fmt.Errorf("[uuid.Parse]: %w", err)
+	}
+
+	resp, err := os.cancelWithRetry(ctx, orderNumber, &actorID, nil, actorID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CancelOrderResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user_id", userID), attribute.String("order_number", orderNumber))
+	return resp, nil
+}
+
+// ReturnOrder отменяет заказ по запросу мерчанта или администратора. Заказ
+// с начислением переводится в RETURNED, а начисленные баллы списываются обратно.
+// Касса партнера по API ключу возвращает только заказы своего магазина
+func (os *OrderService) ReturnOrder(ctx context.Context,
+	orderNumber string, actorID uuid.UUID) (dto.CancelOrderResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.ReturnOrder")
+	defer span.End()
+
+	var merchantID *uuid.UUID
+	if key, ok := ctx.Value(contextkeys.APIKey).(*model.APIKey); ok && key != nil {
+		merchant, err := resolveMerchant(ctx, os.repo.NewMerchantRepo(os.repo.Pool()), "")
+		if err != nil {
+			span.RecordError(err)
+			return dto.CancelOrderResponse{}, err
+		}
+		// без магазина у ключа нет своих заказов
+		if merchant == nil {
+			span.RecordError(model.ErrOrderNotFound)
+			return dto.CancelOrderResponse{}, model.ErrOrderNotFound
+		}
+		merchantID = &merchant.ID
+	}
+
+	resp, err := os.cancelWithRetry(ctx, orderNumber, nil, merchantID, actorID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.CancelOrderResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("order_number", orderNumber), attribute.String("status", string(resp.Status)),
+		attribute.String("clawback", resp.Clawback.String()))
+	return resp, nil
+}
+
+// cancelWithRetry повторяет отмену, если serializable транзакция не прошла
+// из-за конфликта с параллельной
+func (os *OrderService) cancelWithRetry(ctx context.Context, orderNumber string,
+	owner, merchant *uuid.UUID, actorID uuid.UUID) (dto.CancelOrderResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := os.cancel(ctx, orderNumber, owner, merchant, actorID)
+		if err == nil || !isSerializationFailure(err) || attempt == cancelAttempts {
+			return resp, err
+		}
+		os.logger.Warn("order cancel serialization failure, retrying",
+			zap.String("order_number", orderNumber), zap.Int("attempt", attempt))
+	}
+}
+
+// isSerializationFailure сообщает, что транзакцию можно повторить целиком
+func isSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgSerializationFailure
+}
+
+// cancel переводит заказ в CANCELLED или, если owner не задан и заказ уже
+// рассчитан, в RETURNED. Баланс читается и меняется в serializable транзакции
+// вместе с заказом, поэтому параллельное списание баллов не пройдет мимо возврата.
+// merchant ограничивает отмену заказами одного магазина
+func (os *OrderService) cancel(ctx context.Context, orderNumber string,
+	owner, merchant *uuid.UUID, actorID uuid.UUID) (resp dto.CancelOrderResponse, err error) {
+	tx, err := os.repo.BeginTx(ctx, pgx.Serializable)
+	if err != nil {
+		return dto.CancelOrderResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	// ошибка фиксации возвращается, чтобы конфликт можно было повторить
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return
+		}
+		if err = tx.Commit(ctx); err != nil {
+			resp = dto.CancelOrderResponse{}
+			err = fmt.Errorf("commit: %w", err)
+		}
+	}()
+
+	orderRepo := os.repo.NewOrderRepo(tx)
+	order, err := orderRepo.GetByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = model.ErrOrderNotFound
+			return dto.CancelOrderResponse{}, err
+		}
+		return dto.CancelOrderResponse{}, fmt.Errorf("[orderRepo.GetByNumber]: %w", err)
+	}
+	if owner != nil && order.UserID != *owner {
+		err = model.ErrOrderNotFound
+		return dto.CancelOrderResponse{}, err
+	}
+	// заказ другого магазина не отличается от несуществующего
+	if merchant != nil && (order.MerchantID == nil || *order.MerchantID != *merchant) {
+		err = model.ErrOrderNotFound
+		return dto.CancelOrderResponse{}, err
+	}
+
+	// покупатель отменяет только нерассчитанный заказ, возврат - через мерчанта
+	next := model.OrderStatusCancelled
+	if order.Status == model.OrderStatusProcessed && owner == nil {
+		next = model.OrderStatusReturned
+	}
+	if !order.Status.CanTransitionTo(next) {
+		err = model.ErrOrderNotCancellable
+		return dto.CancelOrderResponse{}, err
+	}
+
+	err = orderRepo.Update(ctx, model.Order{Number: order.Number, Status: next, Accrual: order.Accrual})
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidOrderTransition) {
+			err = model.ErrOrderNotCancellable
+			return dto.CancelOrderResponse{}, err
+		}
+		return dto.CancelOrderResponse{}, fmt.Errorf("[orderRepo.Update]: %w", err)
+	}
+
+	// начисление заказа остается в истории, баланс уменьшает корректировка
+	balanceRepo := os.repo.NewBalanceRepo(tx)
+	resp = dto.CancelOrderResponse{Number: order.Number, Status: next, Clawback: order.Accrual}
+	if order.Accrual.IsPositive() {
+		err = balanceRepo.AddAdjustment(ctx, &model.BalanceAdjustment{
+			ID:          uuid.New(),
+			UserID:      order.UserID,
+			OrderNumber: order.Number,
+			Amount:      order.Accrual.Neg(),
+			Reason:      model.BalanceAdjustmentOrderReturned,
+			CreatedAt:   time.Now(),
+		})
+		if err != nil {
+			return dto.CancelOrderResponse{}, fmt.Errorf("[balanceRepo.AddAdjustment]: %w", err)
+		}
+	}
+
+	balance, err := balanceRepo.Get(ctx, order.UserID.String())
+	if err != nil {
+		return dto.CancelOrderResponse{}, fmt.Errorf("[balanceRepo.Get]: %w", err)
+	}
+	resp.Debt = balance.Debt
+
+	eventType := model.AuditEventOrderCancelled
+	if next == model.OrderStatusReturned {
+		eventType = model.AuditEventOrderReturned
+	}
+	err = os.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:     uuid.New(),
+		UserID: &order.UserID,
+		Type:   eventType,
+		Details: map[string]string{
+			"order":    order.Number,
+			"actor":    actorID.String(),
+			"clawback": order.Accrual.String(),
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return dto.CancelOrderResponse{}, fmt.Errorf("create audit event: %w", err)
+	}
+
+	os.logger.Info("order cancelled", zap.String("order_number", order.Number),
+		zap.String("status", string(next)), zap.String("clawback", order.Accrual.String()),
+		zap.Float64("debt", balance.Debt))
+	return resp, nil
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsSerializationFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "serialization failure",
+			err:  &pgconn.PgError{Code: pgSerializationFailure},
+			want: true,
+		},
+		{
+			name: "wrapped serialization failure",
+			err:  fmt.Errorf("commit: %w", &pgconn.PgError{Code: pgSerializationFailure}),
+			want: true,
+		},
+		{
+			name: "other postgres error",
+			err:  &pgconn.PgError{Code: "23505"},
+			want: false,
+		},
+		{
+			name: "plain error",
+			err:  errors.New("boom"),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSerializationFailure(tt.err); got != tt.want {
+				t.Errorf("isSerializationFailure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
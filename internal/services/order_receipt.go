@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// SaveReceipt сохраняет чек заказа от магазина, загрузившего заказ. Пока
+// начисление не рассчитано, чек можно прислать повторно - он заменит прежний
+func (os *OrderService) SaveReceipt(ctx context.Context,
+	orderNumber string, req dto.ReceiptRequest) (dto.ReceiptResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.SaveReceipt")
+	defer span.End()
+
+	receipt, err := req.Validate(orderNumber, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		return dto.ReceiptResponse{}, err
+	}
+
+	merchant, err := resolveMerchant(ctx, os.repo.NewMerchantRepo(os.repo.Pool()), "")
+	if err != nil {
+		span.RecordError(err)
+		return dto.ReceiptResponse{}, err
+	}
+	if merchant == nil {
+		span.RecordError(model.ErrMerchantNotFound)
+		return dto.ReceiptResponse{}, model.ErrMerchantNotFound
+	}
+	receipt.MerchantID = &merchant.ID
+
+	resp, err := os.saveReceipt(ctx, receipt)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ReceiptResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("order_number", orderNumber),
+		attribute.String("merchant.id", merchant.ID.String()), attribute.Int("items_count", len(receipt.Items)))
+	return resp, nil
+}
+
+// saveReceipt проверяет заказ и пишет чек в одной транзакции. READ COMMITTED:
+// блокировка заказа дожидается воркера начислений и видит уже новый статус
+func (os *OrderService) saveReceipt(ctx context.Context,
+	receipt *model.Receipt) (resp dto.ReceiptResponse, err error) {
+	tx, err := os.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		return dto.ReceiptResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	return storeReceipt(ctx, os.repo.NewOrderRepo(tx), os.repo.NewReceiptRepo(tx), receipt)
+}
+
+// storeReceipt заменяет чек заказа, пока начисление по нему не рассчитано.
+// Строка заказа блокируется до проверки статуса, чтобы воркер начислений не
+// рассчитал заказ по прежнему чеку, пока чек заменяется.
+// Заказ другого магазина не отличается от несуществующего
+func storeReceipt(ctx context.Context, orders interfaces.OrderRepository,
+	receipts interfaces.ReceiptRepository, receipt *model.Receipt) (dto.ReceiptResponse, error) {
+	order, err := orders.GetByNumberForUpdate(ctx, receipt.OrderNumber)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.ReceiptResponse{}, model.ErrOrderNotFound
+		}
+		return dto.ReceiptResponse{}, fmt.Errorf("[orderRepo.GetByNumberForUpdate]: %w", err)
+	}
+	if order.MerchantID == nil || receipt.MerchantID == nil || *order.MerchantID != *receipt.MerchantID {
+		return dto.ReceiptResponse{}, model.ErrOrderNotFound
+	}
+	// по рассчитанному или закрытому заказу чек уже не пересчитать
+	if !order.Status.Pending() {
+		return dto.ReceiptResponse{}, model.ErrReceiptLocked
+	}
+
+	if err := receipts.Save(ctx, receipt); err != nil {
+		return dto.ReceiptResponse{}, fmt.Errorf("[receiptRepo.Save]: %w", err)
+	}
+
+	return dto.NewReceiptResponse(receipt), nil
+}
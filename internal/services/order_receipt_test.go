@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+)
+
+// fakeOrderRepo заказы в памяти, остальные методы не используются
+type fakeOrderRepo struct {
+	interfaces.OrderRepository
+	orders map[string]*model.Order
+	// заказы, строки которых блокировались
+	locked []string
+}
+
+func (r *fakeOrderRepo) GetByNumber(_ context.Context, orderNumber string) (*model.Order, error) {
+	order, ok := r.orders[orderNumber]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return order, nil
+}
+
+// fakeReceiptRepo чеки в памяти: Save заменяет чек заказа целиком
+type fakeReceiptRepo struct {
+	receipts map[string]*model.Receipt
+}
+
+func (r *fakeReceiptRepo) Save(_ context.Context, receipt *model.Receipt) error {
+	r.receipts[receipt.OrderNumber] = receipt
+	return nil
+}
+
+func (r *fakeReceiptRepo) Get(_ context.Context, orderNumber string) (*model.Receipt, error) {
+	return r.receipts[orderNumber], nil
+}
+
+func (r *fakeOrderRepo) GetByNumberForUpdate(ctx context.Context, orderNumber string) (*model.Order, error) {
+	r.locked = append(r.locked, orderNumber)
+	return r.GetByNumber(ctx, orderNumber)
+}
+
+func newReceipt(orderNumber string, merchantID uuid.UUID, skus ...string) *model.Receipt {
+	receipt := &model.Receipt{OrderNumber: orderNumber, MerchantID: &merchantID, Currency: "RUB",
+		CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	for _, sku := range skus {
+		item := model.ReceiptItem{SKU: sku, Quantity: decimal.NewFromInt(1), Price: decimal.NewFromInt(100)}
+		receipt.Items = append(receipt.Items, item)
+		receipt.Total = receipt.Total.Add(item.Amount())
+	}
+	return receipt
+}
+
+func TestStoreReceipt(t *testing.T) {
+	shop := uuid.New()
+	other := uuid.New()
+	orders := &fakeOrderRepo{orders: map[string]*model.Order{
+		"2377225624":       {Number: "2377225624", Status: model.OrderStatusNew, MerchantID: &shop},
+		"12345674":         {Number: "12345674", Status: model.OrderStatusProcessing, MerchantID: &shop},
+		"4561261212345467": {Number: "4561261212345467", Status: model.OrderStatusNew},
+		"79927398712":      {Number: "79927398712", Status: model.OrderStatusNew, MerchantID: &other},
+	}}
+	for _, status := range []model.OrderStatus{model.OrderStatusProcessed, model.OrderStatusInvalid,
+		model.OrderStatusCancelled, model.OrderStatusReturned} {
+		orders.orders[string(status)] = &model.Order{Number: string(status), Status: status, MerchantID: &shop}
+	}
+	receipts := &fakeReceiptRepo{receipts: map[string]*model.Receipt{}}
+
+	tests := []struct {
+		name    string
+		receipt *model.Receipt
+		err     error
+	}{
+		{name: "new order", receipt: newReceipt("2377225624", shop, "coffee")},
+		{name: "order in processing", receipt: newReceipt("12345674", shop, "coffee")},
+		{name: "unknown order", receipt: newReceipt("0", shop, "coffee"), err: model.ErrOrderNotFound},
+		// заказ без магазина или чужого магазина выглядит как несуществующий
+		{name: "order without merchant", receipt: newReceipt("4561261212345467", shop, "coffee"),
+			err: model.ErrOrderNotFound},
+		{name: "order of another merchant", receipt: newReceipt("79927398712", shop, "coffee"),
+			err: model.ErrOrderNotFound},
+		{name: "processed order", receipt: newReceipt(string(model.OrderStatusProcessed), shop, "coffee"),
+			err: model.ErrReceiptLocked},
+		{name: "invalid order", receipt: newReceipt(string(model.OrderStatusInvalid), shop, "coffee"),
+			err: model.ErrReceiptLocked},
+		{name: "cancelled order", receipt: newReceipt(string(model.OrderStatusCancelled), shop, "coffee"),
+			err: model.ErrReceiptLocked},
+		{name: "returned order", receipt: newReceipt(string(model.OrderStatusReturned), shop, "coffee"),
+			err: model.ErrReceiptLocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := storeReceipt(context.Background(), orders, receipts, tt.receipt)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			// статус проверяется по заблокированной строке заказа
+			if len(orders.locked) == 0 || orders.locked[len(orders.locked)-1] != tt.receipt.OrderNumber {
+				t.Errorf("expected order %s to be locked, got %v", tt.receipt.OrderNumber, orders.locked)
+			}
+			saved := receipts.receipts[tt.receipt.OrderNumber]
+			if tt.err != nil {
+				if saved != nil {
+					t.Errorf("expected rejected receipt not to be saved")
+				}
+				return
+			}
+			if saved != tt.receipt || resp.OrderNumber != tt.receipt.OrderNumber {
+				t.Errorf("expected receipt to be saved and returned, got %+v", resp)
+			}
+		})
+	}
+
+	// повторный чек до расчета начисления заменяет прежний
+	replacement := newReceipt("2377225624", shop, "tea", "cake")
+	resp, err := storeReceipt(context.Background(), orders, receipts, replacement)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipts.receipts["2377225624"] != replacement || len(resp.Items) != 2 || !resp.Total.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("expected receipt to be replaced, got %+v", resp)
+	}
+
+	// после расчета начисления чек уже не меняется
+	orders.orders["2377225624"].Status = model.OrderStatusProcessed
+	if _, err := storeReceipt(context.Background(), orders, receipts, newReceipt("2377225624", shop, "coffee")); !errors.Is(err, model.ErrReceiptLocked) {
+		t.Fatalf("expected %v, got %v", model.ErrReceiptLocked, err)
+	}
+	if receipts.receipts["2377225624"] != replacement {
+		t.Error("expected locked receipt to stay unchanged")
+	}
+}
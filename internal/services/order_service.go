@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,32 +13,92 @@ import (
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
-	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/lunavalidate"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/ordernumber"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
 type OrderService struct {
-	repo   *repository.Repositories
-	logger *zap.Logger
+	repo       *repository.Repositories
+	validators *ordernumber.Registry
+	logger     *zap.Logger
 }
 
-func NewOrderService(repo *repository.Repositories,
+func NewOrderService(repo *repository.Repositories, validators *ordernumber.Registry,
 	logger *zap.Logger) *OrderService {
 	return &OrderService{
-		repo:   repo,
-		logger: logger,
+		repo:       repo,
+		validators: validators,
+		logger:     logger,
 	}
 }
 
-func (os *OrderService) Load(ctx context.Context, orderNumber string) (dto.AddOrderResponse, error) {
+// validator проверка номеров для мерчанта, от которого пришел заказ: правила
+// из карточки мерчанта, затем из настроек сервиса по id мерчанта или по id
+// его владельца. В настройках до появления карточек мерчантов правила
+// задавались по id владельца API ключа, поэтому для ключа без карточки
+// мерчанта ищется он. Остальные заказы проверяются правилом по умолчанию
+func (os *OrderService) validator(ctx context.Context,
+	merchant *model.Merchant) (ordernumber.OrderNumberValidator, error) {
+	var ids []uuid.UUID
+	switch {
+	case merchant != nil:
+		if merchant.OrderNumberValidator != "" {
+			v, err := ordernumber.Parse(merchant.OrderNumberValidator)
+			if err != nil {
+				return nil, fmt.Errorf("merchant %s validator: %w", merchant.ID, err)
+			}
+			return v, nil
+		}
+		ids = append(ids, merchant.ID)
+		if merchant.OwnerID != nil {
+			ids = append(ids, *merchant.OwnerID)
+		}
+	default:
+		if key, ok := ctx.Value(contextkeys.APIKey).(*model.APIKey); ok && key != nil {
+			ids = append(ids, key.UserID)
+		}
+	}
+
+	for _, id := range ids {
+		if v, ok := os.validators.Lookup(id); ok {
+			return v, nil
+		}
+	}
+	return os.validators.For(nil), nil
+}
+
+// merchant мерчант загрузки и проверка его номеров
+func (os *OrderService) merchant(ctx context.Context,
+	merchantID string) (*model.Merchant, ordernumber.OrderNumberValidator, error) {
+	merchant, err := resolveMerchant(ctx, os.repo.NewMerchantRepo(os.repo.Pool()), merchantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	validator, err := os.validator(ctx, merchant)
+	if err != nil {
+		return nil, nil, err
+	}
+	return merchant, validator, nil
+}
+
+// Load загружает заказ пользователя. merchantID задает магазин явно, для
+// запросов по API ключу магазин берется из ключа
+func (os *OrderService) Load(ctx context.Context, orderNumber, merchantID string) (dto.AddOrderResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.Load")
 	defer span.End()
 
-	// проверить заказ по алгоритму Луна
-	if !lunavalidate.Validate(orderNumber) {
-		return dto.AddOrderResponse{}, model.ErrBadOrderNumber
+	merchant, validator, err := os.merchant(ctx, merchantID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AddOrderResponse{}, err
+	}
+
+	// проверить номер по правилам мерчанта
+	if err := validator.Validate(orderNumber); err != nil {
+		span.RecordError(err)
+		return dto.AddOrderResponse{}, err
 	}
 
 	tx, err := os.repo.BeginTx(ctx, pgx.ReadUncommitted)
@@ -70,6 +131,7 @@ func (os *OrderService) Load(ctx context.Context, orderNumber string) (dto.AddOr
 		Status:     model.OrderStatusNew,
 		Accrual:    decimal.Zero,
 		UploadedAt: time.Now(),
+		MerchantID: merchantRef(merchant),
 	}
 
 	err = orderRepo.Create(ctx, &order)
@@ -84,12 +146,132 @@ func (os *OrderService) Load(ctx context.Context, orderNumber string) (dto.AddOr
 	}, nil
 }
 
+// LoadBatch загружает пакет заказов пользователя одной транзакцией. Каждый номер
+// получает свой результат: ошибка в одном не отменяет загрузку остальных
+func (os *OrderService) LoadBatch(ctx context.Context,
+	userID string, numbers []string, merchantID string) (dto.BatchOrdersResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.LoadBatch")
+	defer span.End()
+
+	owner, err := uuid.Parse(userID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.BatchOrdersResponse{}, fmt.Errorf("[uuid.Parse]: %w", err)
+	}
+
+	merchant, validator, err := os.merchant(ctx, merchantID)
+	if err != nil {
+		span.RecordError(err)
+		return dto.BatchOrdersResponse{}, err
+	}
+
+	// повторы внутри пакета и неверные номера в базу не отправляем
+	statuses := make(map[string]dto.BatchOrderStatus, len(numbers))
+	rejected := make(map[string]string)
+	seen := make(map[string]bool, len(numbers))
+	candidates := make([]string, 0, len(numbers))
+	orders := make([]model.Order, 0, len(numbers))
+	now := time.Now()
+	for _, number := range numbers {
+		if seen[number] || rejected[number] != "" {
+			continue
+		}
+		if err := validator.Validate(number); err != nil {
+			rejected[number] = err.Error()
+			continue
+		}
+		seen[number] = true
+		candidates = append(candidates, number)
+		orders = append(orders, model.Order{
+			Number:     number,
+			UserID:     owner,
+			Status:     model.OrderStatusNew,
+			Accrual:    decimal.Zero,
+			UploadedAt: now,
+			MerchantID: merchantRef(merchant),
+		})
+	}
+
+	if len(orders) > 0 {
+		tx, err := os.repo.BeginTx(ctx, pgx.ReadCommitted)
+		if err != nil {
+			span.RecordError(err)
+			return dto.BatchOrdersResponse{}, fmt.Errorf("error while starting transaction %w", err)
+		}
+		defer func() {
+			if err != nil {
+				_ = tx.Rollback(ctx)
+			}
+			_ = tx.Commit(ctx)
+		}()
+
+		orderRepo := os.repo.NewOrderRepo(tx)
+		created, err := orderRepo.CreateBatch(ctx, orders)
+		if err != nil {
+			span.RecordError(err)
+			return dto.BatchOrdersResponse{}, fmt.Errorf("[orderRepo.CreateBatch]: %w", err)
+		}
+		for _, number := range created {
+			statuses[number] = dto.BatchOrderAccepted
+		}
+
+		// не вставленные номера уже кому-то принадлежат
+		existing := make([]string, 0, len(candidates)-len(created))
+		for _, number := range candidates {
+			if _, ok := statuses[number]; !ok {
+				existing = append(existing, number)
+			}
+		}
+		if len(existing) > 0 {
+			var owners map[string]uuid.UUID
+			owners, err = orderRepo.GetOwners(ctx, existing)
+			if err != nil {
+				span.RecordError(err)
+				return dto.BatchOrdersResponse{}, fmt.Errorf("[orderRepo.GetOwners]: %w", err)
+			}
+			for _, number := range existing {
+				statuses[number] = dto.BatchOrderConflict
+				if id, ok := owners[number]; ok && id == owner {
+					statuses[number] = dto.BatchOrderDuplicate
+				}
+			}
+		}
+	}
+
+	resp := dto.BatchOrdersResponse{Results: make([]dto.BatchOrderResult, 0, len(numbers))}
+	reported := make(map[string]bool, len(numbers))
+	for _, number := range numbers {
+		switch status, ok := statuses[number]; {
+		case !ok:
+			resp.Add(number, dto.BatchOrderInvalid, rejected[number])
+		case reported[number]:
+			resp.Add(number, dto.BatchOrderDuplicate, "")
+		default:
+			resp.Add(number, status, "")
+		}
+		reported[number] = true
+	}
+
+	span.SetAttributes(attribute.String("user_id", userID), attribute.Int("orders_count", len(numbers)),
+		attribute.Int("accepted_count", resp.Accepted))
+	return resp, nil
+}
+
+// GetAll возвращает страницу заказов пользователя по фильтрам запроса
+// вместе с общим числом заказов и курсором следующей страницы
 func (os *OrderService) GetAll(ctx context.Context,
-	userID string) (dto.GetAllOrdersResponse, error) {
+	userID string, req dto.ListOrdersRequest) (dto.GetAllOrdersResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.GetAll")
 	defer span.End()
 
-	tx, err := os.repo.BeginTx(ctx, pgx.ReadUncommitted)
+	q, err := req.ToQuery()
+	if err != nil {
+		span.RecordError(err)
+		return dto.GetAllOrdersResponse{}, err
+	}
+
+	// страница и общее число из одного снимка
+	tx, err := os.repo.BeginTx(ctx, pgx.RepeatableRead)
 	if err != nil {
 		span.RecordError(err)
 		return dto.GetAllOrdersResponse{}, fmt.Errorf("error while starting transaction %w", err)
@@ -101,16 +283,90 @@ func (os *OrderService) GetAll(ctx context.Context,
 		_ = tx.Commit(ctx)
 	}()
 
-	orderService := os.repo.NewOrderRepo(tx)
+	orderRepo := os.repo.NewOrderRepo(tx)
 
-	orders, err := orderService.GetAll(ctx, userID)
+	// берем на один заказ больше, чтобы понять, есть ли следующая страница
+	limit := q.Limit
+	q.Limit++
+	orders, err := orderRepo.List(ctx, userID, q)
 	if err != nil {
 		span.RecordError(err)
-		return dto.GetAllOrdersResponse{}, fmt.Errorf("[orderService.GetAll]: %w", err)
+		return dto.GetAllOrdersResponse{}, fmt.Errorf("[orderRepo.List]: %w", err)
 	}
 
-	span.SetAttributes(attribute.String("user_id", userID), attribute.Int("orders_count", len(orders)))
-	return dto.GetAllOrdersResponse{
-		Orders: orders,
-	}, nil
+	total, err := orderRepo.Count(ctx, userID, q)
+	if err != nil {
+		span.RecordError(err)
+		return dto.GetAllOrdersResponse{}, fmt.Errorf("[orderRepo.Count]: %w", err)
+	}
+
+	resp := dto.GetAllOrdersResponse{Orders: orders, TotalCount: total}
+	if len(orders) > limit {
+		resp.Orders = orders[:limit]
+		resp.NextCursor = dto.EncodeOrderCursor(q.Sort, orders[limit-1])
+	}
+
+	span.SetAttributes(attribute.String("user_id", userID), attribute.Int("orders_count", len(resp.Orders)),
+		attribute.Int("orders_total", total))
+	return resp, nil
+}
+
+// GetOrder возвращает заказ пользователя с историей. Чужой заказ не отличается
+// от несуществующего, чтобы не раскрывать занятые номера
+func (os *OrderService) GetOrder(ctx context.Context,
+	userID, orderNumber string) (dto.OrderDetailResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.GetOrder")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user_id", userID), attribute.String("order_number", orderNumber))
+	return os.getOrder(ctx, orderNumber, userID)
+}
+
+// GetOrderForSupport возвращает любой заказ с историей для поддержки
+func (os *OrderService) GetOrderForSupport(ctx context.Context,
+	orderNumber string) (dto.OrderDetailResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "OrderService.GetOrderForSupport")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("order_number", orderNumber))
+	return os.getOrder(ctx, orderNumber, "")
+}
+
+// getOrder читает заказ и историю из одного снимка, ownerID пустой - без проверки владельца
+func (os *OrderService) getOrder(ctx context.Context,
+	orderNumber, ownerID string) (resp dto.OrderDetailResponse, err error) {
+	tx, err := os.repo.BeginTx(ctx, pgx.RepeatableRead)
+	if err != nil {
+		return dto.OrderDetailResponse{}, fmt.Errorf("error while starting transaction %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		}
+		_ = tx.Commit(ctx)
+	}()
+
+	orderRepo := os.repo.NewOrderRepo(tx)
+	order, err := orderRepo.GetByNumber(ctx, orderNumber)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return dto.OrderDetailResponse{}, model.ErrOrderNotFound
+		}
+		return dto.OrderDetailResponse{}, fmt.Errorf("[orderRepo.GetByNumber]: %w", err)
+	}
+	if ownerID != "" && order.UserID.String() != ownerID {
+		return dto.OrderDetailResponse{}, model.ErrOrderNotFound
+	}
+
+	history, err := orderRepo.GetHistory(ctx, orderNumber)
+	if err != nil {
+		return dto.OrderDetailResponse{}, fmt.Errorf("[orderRepo.GetHistory]: %w", err)
+	}
+
+	receipt, err := os.repo.NewReceiptRepo(tx).Get(ctx, orderNumber)
+	if err != nil {
+		return dto.OrderDetailResponse{}, fmt.Errorf("[receiptRepo.Get]: %w", err)
+	}
+
+	return dto.NewOrderDetailResponse(*order, history, receipt), nil
 }
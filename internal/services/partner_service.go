@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/services/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// PartnerService операции касс партнеров от имени покупателя. Покупатель
+// определяется по логину, дальше работают обычные сервисы заказов и баланса
+type PartnerService struct {
+	repo    *repository.Repositories
+	orders  interfaces.OrderServiceInterface
+	balance interfaces.BalanceServiceInterface
+	logger  *zap.Logger
+}
+
+func NewPartnerService(repo *repository.Repositories, orders interfaces.OrderServiceInterface,
+	balance interfaces.BalanceServiceInterface, logger *zap.Logger) *PartnerService {
+	return &PartnerService{
+		repo:    repo,
+		orders:  orders,
+		balance: balance,
+		logger:  logger,
+	}
+}
+
+// LoadOrder загружает заказ покупателя
+func (s *PartnerService) LoadOrder(ctx context.Context, req dto.PartnerOrderRequest) (dto.AddOrderResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "PartnerService.LoadOrder")
+	defer span.End()
+
+	ctx, err := s.customerContext(ctx, req.Login)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AddOrderResponse{}, err
+	}
+	span.SetAttributes(attribute.String("user.id", ctx.Value(contextkeys.UserKeyID).(string)))
+
+	return s.orders.Load(ctx, req.Order, "")
+}
+
+// Redeem списывает баллы покупателя в счет заказа
+func (s *PartnerService) Redeem(ctx context.Context, req dto.PartnerRedemptionRequest) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "PartnerService.Redeem")
+	defer span.End()
+
+	ctx, err := s.customerContext(ctx, req.Login)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	span.SetAttributes(attribute.String("user.id", ctx.Value(contextkeys.UserKeyID).(string)))
+
+	err = s.balance.Withdraw(ctx, dto.NewWithdrawnRequest{Order: req.Order, Sum: req.Sum,
+		RedemptionCode: req.Code})
+	if err != nil {
+		span.RecordError(err)
+		// неверный код учитываем вне транзакции списания, чтобы код нельзя было подобрать
+		if errors.Is(err, model.ErrInvalidRedemptionCode) {
+			userID := ctx.Value(contextkeys.UserKeyID).(string)
+			codeRepo := s.repo.NewRedemptionCodeRepo(s.repo.Pool())
+			if err := codeRepo.RegisterFailure(ctx, userID, redemptionCodeMaxFailures); err != nil {
+				s.logger.Error("can't register redemption code failure", zap.Error(err))
+			}
+		}
+		return err
+	}
+
+	// списание чужих баллов фиксируем в аудите вместе с ключом
+	key, _ := ctx.Value(contextkeys.APIKey).(*model.APIKey)
+	if key != nil {
+		userID := uuid.MustParse(ctx.Value(contextkeys.UserKeyID).(string))
+		err := s.repo.NewAuditRepo(s.repo.Pool()).Create(ctx, &model.AuditEvent{
+			ID:     uuid.New(),
+			UserID: &userID,
+			Type:   model.AuditEventPartnerRedemption,
+			Details: map[string]string{
+				"api_key":  key.Prefix,
+				"merchant": key.UserID.String(),
+				"order":    req.Order,
+				"sum":      fmt.Sprint(req.Sum),
+			},
+			CreatedAt: time.Now(),
+		})
+		if err != nil {
+			s.logger.Error("can't record partner redemption", zap.Error(err))
+		}
+	}
+
+	return nil
+}
+
+// ReturnOrder отменяет заказ покупателя или оформляет возврат уже рассчитанного
+func (s *PartnerService) ReturnOrder(ctx context.Context, orderNumber string) (dto.CancelOrderResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "PartnerService.ReturnOrder")
+	defer span.End()
+
+	key, _ := ctx.Value(contextkeys.APIKey).(*model.APIKey)
+	if key == nil {
+		span.RecordError(model.ErrInvalidAPIKey)
+		return dto.CancelOrderResponse{}, model.ErrInvalidAPIKey
+	}
+	span.SetAttributes(attribute.String("api_key", key.Prefix))
+
+	return s.orders.ReturnOrder(ctx, orderNumber, key.UserID)
+}
+
+// SaveReceipt сохраняет чек заказа, загруженного магазином владельца ключа
+func (s *PartnerService) SaveReceipt(ctx context.Context,
+	orderNumber string, req dto.ReceiptRequest) (dto.ReceiptResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "PartnerService.SaveReceipt")
+	defer span.End()
+
+	key, _ := ctx.Value(contextkeys.APIKey).(*model.APIKey)
+	if key == nil {
+		span.RecordError(model.ErrInvalidAPIKey)
+		return dto.ReceiptResponse{}, model.ErrInvalidAPIKey
+	}
+	span.SetAttributes(attribute.String("api_key", key.Prefix))
+
+	return s.orders.SaveReceipt(ctx, orderNumber, req)
+}
+
+// customerContext подставляет в контекст id покупателя, как это делает AuthMiddleware
+func (s *PartnerService) customerContext(ctx context.Context, login string) (context.Context, error) {
+	user, err := s.repo.NewUserRepo(s.repo.Pool()).GetByLogin(ctx, login)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoUser) {
+			return ctx, model.ErrUserNotFound
+		}
+		return ctx, fmt.Errorf("get user: %w", err)
+	}
+	// баллы начисляются и списываются только у покупателей
+	if user.Role != model.RoleCustomer {
+		return ctx, model.ErrUserNotFound
+	}
+
+	ctx = context.WithValue(ctx, contextkeys.UserKeyID, user.ID.String())
+	ctx = context.WithValue(ctx, contextkeys.UserRoleKey, user.Role)
+	return ctx, nil
+}
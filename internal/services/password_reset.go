@@ -0,0 +1,257 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/notifier"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// PasswordResetConfig настройки сброса пароля
+type PasswordResetConfig struct {
+	// время жизни токена сброса
+	TTL time.Duration
+	// не чаще одного токена на пользователя за этот интервал
+	Interval time.Duration
+	// адрес страницы сброса на фронтенде, токен добавляется параметром token.
+	// Если не задан, в сообщение попадает только сам токен
+	URL string
+}
+
+func NewPasswordResetConfig() PasswordResetConfig {
+	cfg := PasswordResetConfig{
+		TTL:      30 * time.Minute,
+		Interval: time.Minute,
+		URL:      os.Getenv("PASSWORD_RESET_URL"),
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && ttl > 0 {
+		cfg.TTL = ttl
+	}
+	return cfg
+}
+
+// ForgotPassword выпускает токен сброса и отправляет его пользователю.
+// Для неизвестного логина молча ничего не делает, а сбой доставки только
+// логирует, чтобы по ответу нельзя было проверить существование аккаунта
+func (u *UserService) ForgotPassword(ctx context.Context, req dto.ForgotPasswordRequest) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.ForgotPassword")
+	defer span.End()
+
+	if err := req.Validate(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	user, err := u.repo.NewUserRepo(u.repo.Pool()).GetByLogin(ctx, req.Login)
+	if err != nil {
+		if errors.Is(err, repository.ErrNoUser) {
+			u.logger.Info("password reset for unknown login", zap.String("login", req.Login))
+			return nil
+		}
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	profile, err := u.repo.NewProfileRepo(u.repo.Pool()).Get(ctx, user.ID.String())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	// без адреса письмо отправить некуда, ответ при этом не меняется
+	email := contactEmail(user, profile)
+	if email == "" {
+		u.logger.Warn("password reset for user without email", zap.String("user.id", user.ID.String()))
+		return nil
+	}
+
+	resetRepo := u.repo.NewPasswordResetRepo(u.repo.Pool())
+
+	last, err := resetRepo.LastCreatedAt(ctx, user.ID.String())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if last != nil && time.Since(*last) < u.reset.Interval {
+		u.logger.Info("password reset throttled", zap.String("user.id", user.ID.String()))
+		return nil
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("generate reset token: %w", err)
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	resetRepo = u.repo.NewPasswordResetRepo(tx)
+
+	// действует только последний выданный токен
+	err = resetRepo.InvalidateAll(ctx, user.ID.String())
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	now := time.Now()
+	err = resetRepo.Create(ctx, &model.PasswordResetToken{
+		TokenHash: u.tm.HashToken(token),
+		UserID:    user.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(u.reset.TTL),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// отправляем внутри транзакции: если доставка не удалась, токен не сохранится.
+	// Ошибку доставки только логируем: иначе по ответу было бы видно, что логин
+	// существует
+	err = u.notifier.Send(ctx, u.resetMessage(email, token))
+	if err != nil {
+		span.RecordError(err)
+		u.logger.Error("failed to send reset message", zap.String("user.id", user.ID.String()), zap.Error(err))
+		return nil
+	}
+
+	span.SetAttributes(attribute.String("user.id", user.ID.String()))
+	u.logger.Info("password reset requested", zap.String("user.id", user.ID.String()))
+	return nil
+}
+
+// ResetPassword устанавливает новый пароль по токену сброса и завершает
+// все сессии пользователя. Токен одноразовый
+func (u *UserService) ResetPassword(ctx context.Context, req dto.ResetPasswordRequest,
+	client dto.ClientInfo) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.ResetPassword")
+	defer span.End()
+
+	if err := req.Validate(u.passPolicy); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	hash, err := passmanager.HashPassword(req.NewPassword)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	token, err := u.repo.NewPasswordResetRepo(tx).Consume(ctx, u.tm.HashToken(req.Token))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if token == nil {
+		err = model.ErrInvalidResetToken
+		span.RecordError(err)
+		return err
+	}
+	userID := token.UserID.String()
+
+	userRepo := u.repo.NewUserRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	err = userRepo.UpdatePassword(ctx, userID, hash)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	err = u.repo.NewSessionRepo(tx).DeleteAllByUserID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	// пользователь подтвердил владение аккаунтом - снимаем блокировку входа
+	_, err = u.repo.NewAuthAttemptRepo(tx).Delete(ctx, loginguard.LoginKey(user.Login))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("reset auth attempts: %w", err)
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventPasswordReset,
+		IP:        client.IP,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID))
+	u.logger.Info("password reset", zap.String("user.id", userID))
+	return nil
+}
+
+func (u *UserService) resetMessage(email, token string) notifier.Message {
+	link := token
+	if u.reset.URL != "" {
+		link = u.reset.URL + "?token=" + url.QueryEscape(token)
+	}
+	return notifier.Message{
+		To:      email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Для сброса пароля перейдите по ссылке или используйте токен:\n\n%s\n\n"+
+			"Ссылка действует %s. Если вы не запрашивали сброс, просто проигнорируйте это сообщение.",
+			link, u.reset.TTL),
+	}
+}
+
+func generateResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
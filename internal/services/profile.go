@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// GetProfile возвращает текущего пользователя вместе с профилем
+func (u *UserService) GetProfile(ctx context.Context) (dto.ProfileResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.GetProfile")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+
+	pool := u.repo.Pool()
+
+	user, err := u.repo.NewUserRepo(pool).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	profile, err := u.repo.NewProfileRepo(pool).Get(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	return dto.NewProfileResponse(user, profile), nil
+}
+
+// UpdateProfile частично обновляет профиль, при первом обращении создает его
+func (u *UserService) UpdateProfile(ctx context.Context, req dto.UpdateProfileRequest) (dto.ProfileResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+
+	now := time.Now()
+	if err := req.Validate(now); err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.ProfileResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	profileRepo := u.repo.NewProfileRepo(tx)
+
+	user, err := u.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	profile, err := profileRepo.Get(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+	if profile == nil {
+		profile = &model.UserProfile{UserID: user.ID, CreatedAt: now}
+	}
+
+	oldEmail := contactEmail(user, profile)
+	req.Apply(profile, now)
+
+	err = profileRepo.Upsert(ctx, profile)
+	if err != nil {
+		span.RecordError(err)
+		return dto.ProfileResponse{}, err
+	}
+
+	// подтверждение относится к адресу: новый адрес нужно подтвердить заново
+	if user.EmailVerifiedAt != nil && contactEmail(user, profile) != oldEmail {
+		err = u.repo.NewUserRepo(tx).SetEmailVerified(ctx, userIDStr, false)
+		if err != nil {
+			span.RecordError(err)
+			return dto.ProfileResponse{}, fmt.Errorf("reset email verification: %w", err)
+		}
+		user.EmailVerifiedAt = nil
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	u.logger.Info("profile updated", zap.String("user.id", userIDStr))
+	return dto.NewProfileResponse(user, profile), nil
+}
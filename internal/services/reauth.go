@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+)
+
+// насколько недавним должен быть вход в аккаунт без пароля, чтобы
+// подтвердить им удаление аккаунта или отключение 2FA
+const recentLoginWindow = 5 * time.Minute
+
+// reauthenticate подтверждает, что действие выполняет владелец аккаунта:
+// проверяет текущий пароль. У аккаунта без пароля (вход только через
+// внешний провайдер) сессия запроса должна быть открыта не раньше
+// recentLoginWindow назад, то есть пользователь только что вошел через провайдер
+func reauthenticate(ctx context.Context, sessions interfaces.SessionRepository,
+	user *model.User, password string, now time.Time) error {
+	if user.Password != "" {
+		if password == "" {
+			return dto.ErrEmptyPassword
+		}
+		if !passmanager.CheckPass(password, user.Password) {
+			return model.ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	sessionID, _ := ctx.Value(contextkeys.SessionIDKey).(string)
+	if sessionID == "" {
+		return model.ErrReauthRequired
+	}
+	session, err := sessions.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != user.ID || now.Sub(session.AuthAt) > recentLoginWindow {
+		return model.ErrReauthRequired
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+)
+
+// fakeSessionRepo сессии в памяти, остальные методы не используются
+type fakeSessionRepo struct {
+	interfaces.SessionRepository
+	sessions map[string]*model.Session
+}
+
+func (r *fakeSessionRepo) GetByID(_ context.Context, sessionID string) (*model.Session, error) {
+	return r.sessions[sessionID], nil
+}
+
+func TestReauthenticate(t *testing.T) {
+	now := time.Now()
+	hash, err := passmanager.HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withPassword := &model.User{ID: uuid.New(), Password: hash}
+	external := &model.User{ID: uuid.New()}
+
+	fresh := &model.Session{ID: uuid.New(), UserID: external.ID, AuthAt: now.Add(-time.Minute)}
+	stale := &model.Session{ID: uuid.New(), UserID: external.ID, AuthAt: now.Add(-recentLoginWindow - time.Second)}
+	foreign := &model.Session{ID: uuid.New(), UserID: uuid.New(), AuthAt: now}
+	repo := &fakeSessionRepo{sessions: map[string]*model.Session{
+		fresh.ID.String():   fresh,
+		stale.ID.String():   stale,
+		foreign.ID.String(): foreign,
+	}}
+	session := func(s *model.Session) context.Context {
+		return context.WithValue(context.Background(), contextkeys.SessionIDKey, s.ID.String())
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		user     *model.User
+		password string
+		err      error
+	}{
+		{name: "valid password", ctx: context.Background(), user: withPassword, password: "password123"},
+		{name: "wrong password", ctx: context.Background(), user: withPassword, password: "password124",
+			err: model.ErrInvalidCredentials},
+		{name: "empty password", ctx: session(fresh), user: withPassword, err: dto.ErrEmptyPassword},
+		{name: "recent external login", ctx: session(fresh), user: external},
+		// пароль не заменяет свежий вход, если его у аккаунта нет
+		{name: "password for external account", ctx: session(stale), user: external, password: "password123",
+			err: model.ErrReauthRequired},
+		{name: "stale external login", ctx: session(stale), user: external, err: model.ErrReauthRequired},
+		{name: "session of another user", ctx: session(foreign), user: external, err: model.ErrReauthRequired},
+		{name: "no session", ctx: context.Background(), user: external, err: model.ErrReauthRequired},
+		{name: "revoked session", ctx: session(&model.Session{ID: uuid.New()}), user: external,
+			err: model.ErrReauthRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reauthenticate(tt.ctx, repo, tt.user, tt.password, now)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
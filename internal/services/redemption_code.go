@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	// сколько действует код списания: покупатель показывает его на кассе сразу
+	redemptionCodeTTL = 5 * time.Minute
+	// сколько неверных кодов касса может прислать, пока код действует
+	redemptionCodeMaxFailures = 5
+	redemptionCodeDigits      = 8
+)
+
+// IssueRedemptionCode выпускает одноразовый код, которым покупатель подтверждает
+// списание баллов на кассе партнера. Прежние коды перестают действовать
+func (s *BalanceService) IssueRedemptionCode(ctx context.Context) (dto.RedemptionCodeResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "BalanceService.IssueRedemptionCode")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.RedemptionCodeResponse{}, err
+	}
+
+	tx, err := s.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		s.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.RedemptionCodeResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	user, err := s.repo.NewUserRepo(tx).GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.RedemptionCodeResponse{}, fmt.Errorf("get user: %w", err)
+	}
+
+	codeRepo := s.repo.NewRedemptionCodeRepo(tx)
+	err = codeRepo.InvalidateAll(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.RedemptionCodeResponse{}, err
+	}
+
+	code, err := generateRedemptionCode()
+	if err != nil {
+		span.RecordError(err)
+		return dto.RedemptionCodeResponse{}, fmt.Errorf("generate redemption code: %w", err)
+	}
+
+	now := time.Now()
+	err = codeRepo.Create(ctx, &model.RedemptionCode{
+		UserID:    user.ID,
+		CodeHash:  hashRedemptionCode(code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(redemptionCodeTTL),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return dto.RedemptionCodeResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	return dto.RedemptionCodeResponse{Code: code, ExpiresAt: now.Add(redemptionCodeTTL)}, nil
+}
+
+// consumeRedemptionCode гасит действующий код покупателя
+func consumeRedemptionCode(ctx context.Context, codeRepo interfaces.RedemptionCodeRepository,
+	userID, code string) error {
+	if len(code) != redemptionCodeDigits {
+		return model.ErrInvalidRedemptionCode
+	}
+
+	ok, err := codeRepo.Consume(ctx, userID, hashRedemptionCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return model.ErrInvalidRedemptionCode
+	}
+	return nil
+}
+
+// generateRedemptionCode код из redemptionCodeDigits цифр, чтобы его было удобно ввести на кассе
+func generateRedemptionCode() (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(redemptionCodeDigits), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", redemptionCodeDigits, n), nil
+}
+
+func hashRedemptionCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+)
+
+// fakeRedemptionCodeRepo действующие коды в памяти по пользователю и хешу
+type fakeRedemptionCodeRepo struct {
+	interfaces.RedemptionCodeRepository
+	active map[string]bool
+}
+
+func (r *fakeRedemptionCodeRepo) Consume(_ context.Context, userID, codeHash string) (bool, error) {
+	if !r.active[userID+"/"+codeHash] {
+		return false, nil
+	}
+	delete(r.active, userID+"/"+codeHash)
+	return true, nil
+}
+
+func TestGenerateRedemptionCode(t *testing.T) {
+	code, err := generateRedemptionCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != redemptionCodeDigits {
+		t.Fatalf("expected %d digits, got %q", redemptionCodeDigits, code)
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			t.Fatalf("expected only digits, got %q", code)
+		}
+	}
+	if hashRedemptionCode(code) == code || hashRedemptionCode(code) != hashRedemptionCode(code) {
+		t.Errorf("unexpected code hash %q", hashRedemptionCode(code))
+	}
+}
+
+func TestConsumeRedemptionCode(t *testing.T) {
+	const customer, other = "customer", "other"
+	repo := &fakeRedemptionCodeRepo{active: map[string]bool{
+		customer + "/" + hashRedemptionCode("40817263"): true,
+	}}
+
+	tests := []struct {
+		name   string
+		userID string
+		code   string
+		err    error
+	}{
+		{name: "no code", userID: customer, err: model.ErrInvalidRedemptionCode},
+		{name: "wrong code", userID: customer, code: "40817264", err: model.ErrInvalidRedemptionCode},
+		{name: "short code", userID: customer, code: "4081726", err: model.ErrInvalidRedemptionCode},
+		// код действует только для покупателя, который его получил
+		{name: "code of another customer", userID: other, code: "40817263", err: model.ErrInvalidRedemptionCode},
+		{name: "valid code", userID: customer, code: "40817263"},
+		{name: "code reused", userID: customer, code: "40817263", err: model.ErrInvalidRedemptionCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := consumeRedemptionCode(context.Background(), repo, tt.userID, tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/denylist"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+const (
+	revokedTokensChannel = "revoked_tokens"
+	// пауза перед переподключением LISTEN
+	revocationReconnectDelay = 5 * time.Second
+)
+
+// TokenRevocationService держит denylist в памяти в актуальном состоянии:
+// получает новые записи через LISTEN/NOTIFY и периодически перечитывает
+// таблицу на случай пропущенных уведомлений
+type TokenRevocationService struct {
+	repo           *repository.Repositories
+	denylist       *denylist.Denylist
+	resyncInterval time.Duration
+	logger         *zap.Logger
+}
+
+func NewTokenRevocationService(repo *repository.Repositories, dl *denylist.Denylist,
+	logger *zap.Logger) *TokenRevocationService {
+	resync, err := time.ParseDuration(os.Getenv("TOKEN_DENYLIST_RESYNC"))
+	if err != nil || resync <= 0 {
+		resync = time.Minute
+	}
+	return &TokenRevocationService{
+		repo:           repo,
+		denylist:       dl,
+		resyncInterval: resync,
+		logger:         logger.With(zap.String("service", "token_revocation")),
+	}
+}
+
+// Start загружает denylist и запускает синхронизацию в фоне до отмены ctx
+func (s *TokenRevocationService) Start(ctx context.Context) error {
+	if err := s.sync(ctx); err != nil {
+		return err
+	}
+	go s.listen(ctx)
+	go s.resync(ctx)
+	return nil
+}
+
+// sync дочитывает из базы все записи, которые еще могут быть нужны
+func (s *TokenRevocationService) sync(ctx context.Context) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "TokenRevocationService.sync")
+	defer span.End()
+
+	tokens, err := s.repo.NewRevokedTokenRepo(s.repo.Pool()).
+		GetSince(ctx, time.Now().Add(-s.denylist.TTL()))
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	for _, t := range tokens {
+		s.denylist.Add(t.Kind, t.Value, t.RevokedAt)
+	}
+
+	span.SetAttributes(attribute.Int("denylist.size", s.denylist.Len()))
+	return nil
+}
+
+func (s *TokenRevocationService) listen(ctx context.Context) {
+	for {
+		// после (пере)подключения перечитываем таблицу: пока LISTEN не был
+		// активен, уведомления могли потеряться
+		err := s.repo.Listen(ctx, revokedTokensChannel, func() {
+			if err := s.sync(ctx); err != nil {
+				s.logger.Error("can't sync token denylist", zap.Error(err))
+			}
+		}, func(payload string) {
+			if !s.denylist.AddPayload(payload, time.Now()) {
+				s.logger.Warn("unexpected revoked token notification", zap.String("payload", payload))
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		s.logger.Error("token revocation listener stopped", zap.Error(err))
+
+		select {
+		case <-time.After(revocationReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *TokenRevocationService) resync(ctx context.Context) {
+	ticker := time.NewTicker(s.resyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.sync(ctx); err != nil {
+				s.logger.Error("can't sync token denylist", zap.Error(err))
+			}
+			s.denylist.Prune(time.Now())
+
+			// записи старше времени жизни access токена больше не нужны
+			_, err := s.repo.NewRevokedTokenRepo(s.repo.Pool()).
+				DeleteBefore(ctx, time.Now().Add(-s.denylist.TTL()))
+			if err != nil {
+				s.logger.Error("can't delete expired revoked tokens", zap.Error(err))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -8,10 +8,16 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/contextkeys"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/dto"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/notifier"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/repository/interfaces"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/identity"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/loginguard"
 	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passmanager"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/passpolicy"
 	tokenmanager "github.com/vvjke314/itk-courses/loyalityhub/internal/utils/tokenManager"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -19,21 +25,36 @@ import (
 )
 
 type UserService struct {
-	repo   *repository.Repositories
-	tm     *tokenmanager.TokenManager
-	logger *zap.Logger
+	repo         *repository.Repositories
+	tm           *tokenmanager.TokenManager
+	guard        loginguard.Config
+	passPolicy   *passpolicy.Policy
+	reset        PasswordResetConfig
+	verification EmailVerificationConfig
+	notifier     notifier.Notifier
+	providers    map[string]identity.Provider
+	logger       *zap.Logger
 }
 
-func NewUserService(logger *zap.Logger,
-	repos *repository.Repositories) *UserService {
+func NewUserService(logger *zap.Logger, repos *repository.Repositories,
+	tm *tokenmanager.TokenManager, guard loginguard.Config, policy *passpolicy.Policy,
+	reset PasswordResetConfig, verification EmailVerificationConfig, n notifier.Notifier,
+	providers map[string]identity.Provider) *UserService {
 	return &UserService{
-		logger: logger,
-		repo:   repos,
-		tm:     tokenmanager.NewTokenManager(tokenmanager.NewTokenManagerConfig()),
+		logger:       logger,
+		repo:         repos,
+		tm:           tm,
+		guard:        guard,
+		passPolicy:   policy,
+		reset:        reset,
+		verification: verification,
+		notifier:     n,
+		providers:    providers,
 	}
 }
 
-func (u *UserService) createTokens(userID uuid.UUID) (string, string, error) {
+func (u *UserService) createTokens(userID uuid.UUID, role model.Role,
+	sessionID uuid.UUID) (string, string, error) {
 	// генерация рефреш токена
 	refreshToken, err := u.tm.GenerateRefreshToken()
 	if err != nil {
@@ -41,7 +62,7 @@ func (u *UserService) createTokens(userID uuid.UUID) (string, string, error) {
 	}
 
 	// генерация ацесс токена
-	accessToken, err := u.tm.GenerateAccessToken(userID)
+	accessToken, err := u.tm.GenerateAccessToken(userID, string(role), sessionID)
 	if err != nil {
 		return "", "", fmt.Errorf("can not generate access token %w", err)
 	}
@@ -49,19 +70,56 @@ func (u *UserService) createTokens(userID uuid.UUID) (string, string, error) {
 }
 
 func (u *UserService) Register(ctx context.Context,
-	req dto.RegisterRequest) (dto.AuthResponse, error) {
+	req dto.RegisterRequest, client dto.ClientInfo) (dto.AuthResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "UserService.Register")
 	defer span.End()
 
-	tx, err := u.repo.BeginTx(ctx, pgx.ReadUncommitted)
+	// проверка пароля по политике
+	if err := req.Validate(u.passPolicy); err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	resp, user, verification, err := u.register(ctx, req, client)
 	if err != nil {
+		span.RecordError(err)
 		return dto.AuthResponse{}, err
 	}
+
+	// письмо отправляем после фиксации: SMTP может отвечать долго, а токен в
+	// письме должен быть уже сохранен. Ошибка доставки не мешает регистрации:
+	// письмо можно запросить повторно
+	if verification != nil {
+		if sendErr := u.notifier.Send(ctx, *verification); sendErr != nil {
+			u.logger.Warn("can't send verification message", zap.Error(sendErr))
+		}
+	}
+
+	// генерируем трейсы в спанах
+	span.SetAttributes(attribute.String("user.id", user.ID.String()))
+	u.logger.Info("user registered successfully", zap.String("user.id", user.ID.String()))
+	return resp, nil
+}
+
+// register создает пользователя и его первую сессию в одной транзакции.
+// Для логина-email возвращает письмо подтверждения, которое нужно отправить
+// после фиксации
+func (u *UserService) register(ctx context.Context, req dto.RegisterRequest,
+	client dto.ClientInfo) (resp dto.AuthResponse, user *model.User, verification *notifier.Message, err error) {
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadUncommitted)
+	if err != nil {
+		return dto.AuthResponse{}, nil, nil, err
+	}
+	// ошибка фиксации возвращается: иначе пользователь получил бы токены и
+	// письмо для несохраненного аккаунта
 	defer func() {
 		if err != nil {
 			_ = tx.Rollback(ctx)
-		} else {
-			_ = tx.Commit(ctx)
+			return
+		}
+		if err = tx.Commit(ctx); err != nil {
+			resp, user, verification = dto.AuthResponse{}, nil, nil
+			err = fmt.Errorf("commit: %w", err)
 		}
 	}()
 
@@ -70,10 +128,10 @@ func (u *UserService) Register(ctx context.Context,
 	sessionRepo := u.repo.NewSessionRepo(tx)
 
 	// маппим dto->model
-	user, err := req.ToModel()
+	user, err = req.ToModel()
 	if err != nil {
 		u.logger.Error("can't parse req to model", zap.Error(err))
-		return dto.AuthResponse{}, err
+		return dto.AuthResponse{}, nil, nil, err
 	}
 
 	// проверка на отстутсвие пользователя
@@ -81,54 +139,50 @@ func (u *UserService) Register(ctx context.Context,
 	if !errors.Is(err, repository.ErrNoUser) {
 		if tempUser != nil {
 			u.logger.Error("login already taken", zap.Error(err))
-			return dto.AuthResponse{}, model.ErrAlreadyExits
+			err = model.ErrAlreadyExits
+			return dto.AuthResponse{}, nil, nil, err
 		}
 		u.logger.Error("error while gettins user login", zap.Error(err))
-		return dto.AuthResponse{}, err
+		return dto.AuthResponse{}, nil, nil, err
 	}
 
 	// создание пользователя
 	err = userRepo.Create(ctx, user)
 	if err != nil {
 		u.logger.Error("error while creating user", zap.Error(err))
-		return dto.AuthResponse{}, err
+		return dto.AuthResponse{}, nil, nil, err
 	}
 
-	accessToken, refreshToken, err := u.createTokens(user.ID)
-	if err != nil {
-		u.logger.Error("error while creating tokens", zap.Error(err))
-		return dto.AuthResponse{}, err
+	// логин-email сразу подтверждаем письмом
+	if email := contactEmail(user, nil); email != "" {
+		var token string
+		token, err = u.issueVerificationToken(ctx, tx, user, email)
+		if err != nil {
+			u.logger.Error("error while creating verification token", zap.Error(err))
+			return dto.AuthResponse{}, nil, nil, err
+		}
+		message := u.verificationMessage(email, token)
+		verification = &message
 	}
 
-	session := &model.Session{
-		ID:                 uuid.New(),
-		UserID:             user.ID,
-		HashedRefreshToken: u.tm.HashToken(refreshToken),
-		AuthAt:             time.Now(),
-		ExpireAt:           time.Now().Add(u.tm.GetRefreshTokenTTL()),
-	}
-	// создаем сессию
-	err = sessionRepo.Create(ctx, session)
+	resp, err = u.startSession(ctx, sessionRepo, user, client)
 	if err != nil {
-		u.logger.Error("error while creating session", zap.Error(err))
-		return dto.AuthResponse{}, fmt.Errorf("session.Repo %w", err)
+		return dto.AuthResponse{}, nil, nil, err
 	}
-
-	// генерируем трейсы в спанах
-	span.SetAttributes(attribute.String("user.id", user.ID.String()))
-	u.logger.Info("user registered successfully", zap.String("user.id", user.ID.String()))
-	// формируем ответ
-	return dto.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return resp, user, verification, nil
 }
 
 func (u *UserService) Auth(ctx context.Context,
-	credentials dto.AuthRequest) (dto.AuthResponse, error) {
+	credentials dto.AuthRequest, client dto.ClientInfo) (dto.AuthResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "UserService.Auth")
 	defer span.End()
 
+	// проверяем, не заблокирован ли вход для логина или IP
+	if err := u.checkLockout(ctx, credentials.Login, client.IP); err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
 	tx, err := u.repo.BeginTx(ctx, pgx.ReadUncommitted)
 	if err != nil {
 		u.logger.Error("failed to begin tx", zap.Error(err))
@@ -147,25 +201,113 @@ func (u *UserService) Auth(ctx context.Context,
 
 	user, err := userRepo.GetByLogin(ctx, credentials.Login)
 	if err != nil {
+		if !errors.Is(err, repository.ErrNoUser) {
+			u.logger.Error("failed to get user", zap.Error(err))
+			return dto.AuthResponse{}, err
+		}
 		u.logger.Error("user not found", zap.Error(err))
-		return dto.AuthResponse{}, errors.New("invalid login or password")
+		return dto.AuthResponse{}, u.registerAuthFailure(ctx, nil, credentials.Login, client.IP)
 	}
 
 	if !passmanager.CheckPass(credentials.Password, user.Password) {
 		u.logger.Error("invalid password", zap.String("login", credentials.Login))
-		return dto.AuthResponse{}, errors.New("invalid login or password")
+		return dto.AuthResponse{}, u.registerAuthFailure(ctx, &user.ID, credentials.Login, client.IP)
+	}
+
+	// о блокировке сообщаем только после проверки пароля
+	if user.BannedAt != nil {
+		err = model.ErrUserBanned
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	// старые bcrypt хеши прозрачно переводим на argon2id. Пишем вне
+	// транзакции входа, чтобы ошибка не прервала ее
+	if passmanager.NeedsRehash(user.Password) {
+		u.rehashPassword(ctx, u.repo.NewUserRepo(u.repo.Pool()), user.ID.String(), credentials.Password)
 	}
 
-	accessToken, refreshToken, err := u.createTokens(user.ID)
+	// при включенной 2FA вместо токенов выдаем токен второго шага,
+	// счетчик неудач сбросится после ввода кода
+	mfa, err := u.repo.NewMFARepo(tx).Get(ctx, user.ID.String())
+	if err != nil {
+		u.logger.Error("failed to get user mfa", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+	if mfa.Enabled() {
+		var mfaToken string
+		mfaToken, err = u.tm.GenerateMFAToken(user.ID)
+		if err != nil {
+			u.logger.Error("error while creating mfa token", zap.Error(err))
+			return dto.AuthResponse{}, err
+		}
+		// пароль верный, попытку возвращаем, а счетчик логина сбросится после ввода кода
+		err = u.releaseAttempt(ctx, tx, credentials.Login, client.IP, false)
+		if err != nil {
+			u.logger.Error("failed to release auth attempt", zap.Error(err))
+			return dto.AuthResponse{}, err
+		}
+		span.SetAttributes(attribute.Bool("auth.mfa_required", true))
+		return dto.AuthResponse{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	// успешный вход сбрасывает счетчик неудач по логину
+	err = u.releaseAttempt(ctx, tx, credentials.Login, client.IP, true)
+	if err != nil {
+		u.logger.Error("failed to reset auth attempts", zap.Error(err))
+		return dto.AuthResponse{}, err
+	}
+
+	err = u.cancelAccountDeletion(ctx, tx, user, client)
+	if err != nil {
+		span.RecordError(err)
+		return dto.AuthResponse{}, err
+	}
+
+	resp, err := u.startSession(ctx, sessionRepo, user, client)
+	if err != nil {
+		return dto.AuthResponse{}, err
+	}
+
+	span.SetAttributes(attribute.String("user.id", user.ID.String()))
+	u.logger.Info("user authenticated successfully", zap.String("user.id", user.ID.String()))
+	return resp, nil
+}
+
+// cancelAccountDeletion отменяет запрошенное удаление аккаунта: вход
+// владельца в течение grace периода означает, что аккаунт еще нужен
+func (u *UserService) cancelAccountDeletion(ctx context.Context, tx repository.DBExecutor,
+	user *model.User, client dto.ClientInfo) error {
+	cancelled, err := cancelDeletion(ctx, u.repo.NewUserRepo(tx), u.repo.NewAuditRepo(tx), user,
+		client.IP, time.Now())
+	if err != nil || !cancelled {
+		return err
+	}
+
+	u.logger.Info("account deletion cancelled", zap.String("user.id", user.ID.String()))
+	return nil
+}
+
+// startSession выдает пару токенов и создает для них сессию
+func (u *UserService) startSession(ctx context.Context, sessionRepo interfaces.SessionRepository,
+	user *model.User, client dto.ClientInfo) (dto.AuthResponse, error) {
+	if user.BannedAt != nil {
+		return dto.AuthResponse{}, model.ErrUserBanned
+	}
+
+	sessionID := uuid.New()
+	accessToken, refreshToken, err := u.createTokens(user.ID, user.Role, sessionID)
 	if err != nil {
 		u.logger.Error("error while creating tokens", zap.Error(err))
 		return dto.AuthResponse{}, err
 	}
 
 	session := &model.Session{
-		ID:                 uuid.New(),
+		ID:                 sessionID,
 		UserID:             user.ID,
 		HashedRefreshToken: u.tm.HashToken(refreshToken),
+		UserAgent:          client.UserAgent,
+		IP:                 client.IP,
 		AuthAt:             time.Now(),
 		ExpireAt:           time.Now().Add(u.tm.GetRefreshTokenTTL()),
 	}
@@ -176,20 +318,21 @@ func (u *UserService) Auth(ctx context.Context,
 		return dto.AuthResponse{}, fmt.Errorf("session.Repo %w", err)
 	}
 
-	span.SetAttributes(attribute.String("user.id", user.ID.String()))
-	u.logger.Info("user authenticated successfully", zap.String("user.id", user.ID.String()))
 	return dto.AuthResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 	}, nil
 }
 
+// GetNewAccessToken выдает новую пару токенов по refresh токену (ротация).
+// Старый refresh токен при этом становится недействительным; его повторное
+// предъявление считается компрометацией и приводит к отзыву всей сессии.
 func (u *UserService) GetNewAccessToken(ctx context.Context,
-	refreshToken string) (dto.RefreshResponse, error) {
+	refreshToken string, client dto.ClientInfo) (dto.RefreshResponse, error) {
 	ctx, span := otel.Tracer("service").Start(ctx, "UserService.GetNewAccessToken")
 	defer span.End()
 
-	tx, err := u.repo.BeginTx(ctx, pgx.ReadUncommitted)
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
 	if err != nil {
 		u.logger.Error("failed to begin tx", zap.Error(err))
 		return dto.RefreshResponse{}, err
@@ -205,25 +348,584 @@ func (u *UserService) GetNewAccessToken(ctx context.Context,
 	sessionRepo := u.repo.NewSessionRepo(tx)
 	hashedRefresh := u.tm.HashToken(refreshToken)
 	session, err := sessionRepo.GetByRefreshToken(ctx, hashedRefresh)
-	if err != nil || session == nil {
-		u.logger.Error("session not found or error", zap.Error(err))
-		return dto.RefreshResponse{}, errors.New("invalid refresh token")
+	if err != nil {
+		u.logger.Error("failed to get session", zap.Error(err))
+		return dto.RefreshResponse{}, err
+	}
+
+	if session == nil {
+		var reused bool
+		// err общий с defer: при ошибке проверки транзакция откатывается
+		reused, err = u.revokeReusedSession(ctx, tx, hashedRefresh, client)
+		if err != nil {
+			u.logger.Error("failed to check refresh token reuse", zap.Error(err))
+			return dto.RefreshResponse{}, err
+		}
+		// err == nil, поэтому отзыв сессии будет закоммичен
+		if reused {
+			span.RecordError(model.ErrRefreshTokenReused)
+			return dto.RefreshResponse{}, model.ErrRefreshTokenReused
+		}
+		u.logger.Error("session not found")
+		return dto.RefreshResponse{}, model.ErrInvalidRefreshToken
 	}
 
 	if session.ExpireAt.Before(time.Now()) {
 		u.logger.Error("refresh token expired", zap.String("session_id", session.ID.String()))
-		return dto.RefreshResponse{}, errors.New("refresh token expired")
+		return dto.RefreshResponse{}, model.ErrRefreshTokenExpired
+	}
+
+	// роль берем из базы: ее могли поменять с момента прошлого входа
+	user, err := u.repo.NewUserRepo(tx).GetByID(ctx, session.UserID.String())
+	if err != nil {
+		u.logger.Error("failed to get user", zap.Error(err))
+		return dto.RefreshResponse{}, err
+	}
+
+	if user.BannedAt != nil {
+		err = model.ErrUserBanned
+		span.RecordError(err)
+		return dto.RefreshResponse{}, err
 	}
 
-	accessToken, err := u.tm.GenerateAccessToken(session.UserID)
+	accessToken, newRefreshToken, err := u.createTokens(user.ID, user.Role, session.ID)
 	if err != nil {
-		u.logger.Error("error while creating access token", zap.Error(err))
+		u.logger.Error("error while creating tokens", zap.Error(err))
+		return dto.RefreshResponse{}, err
+	}
+
+	err = sessionRepo.Rotate(ctx, session.ID.String(), hashedRefresh, u.tm.HashToken(newRefreshToken))
+	if err != nil {
+		u.logger.Error("error while rotating refresh token", zap.Error(err))
+		if errors.Is(err, model.ErrSessionNotFound) {
+			// токен успели ротировать параллельным запросом
+			return dto.RefreshResponse{}, model.ErrInvalidRefreshToken
+		}
 		return dto.RefreshResponse{}, err
 	}
 
 	span.SetAttributes(attribute.String("user.id", session.UserID.String()))
-	u.logger.Info("access token refreshed successfully", zap.String("user.id", session.UserID.String()))
+	u.logger.Info("tokens refreshed successfully", zap.String("user.id", session.UserID.String()))
 	return dto.RefreshResponse{
-		AccessToken: accessToken,
+		AccessToken:  accessToken,
+		RefreshToken: newRefreshToken,
 	}, nil
 }
+
+// revokeReusedSession проверяет, не был ли токен уже ротирован. Если был -
+// удаляет всю сессию (семейство токенов) и пишет событие аудита.
+func (u *UserService) revokeReusedSession(ctx context.Context, tx repository.DBExecutor,
+	hashedRefresh string, client dto.ClientInfo) (bool, error) {
+	sessionRepo := u.repo.NewSessionRepo(tx)
+	auditRepo := u.repo.NewAuditRepo(tx)
+
+	session, err := sessionRepo.GetByRotatedToken(ctx, hashedRefresh)
+	if err != nil {
+		return false, fmt.Errorf("get session by rotated token: %w", err)
+	}
+	if session == nil {
+		return false, nil
+	}
+
+	if err := sessionRepo.Delete(ctx, session.ID.String()); err != nil {
+		return false, fmt.Errorf("delete session: %w", err)
+	}
+
+	err = auditRepo.Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &session.UserID,
+		Type:      model.AuditEventRefreshTokenReuse,
+		SessionID: &session.ID,
+		IP:        client.IP,
+		Details: map[string]string{
+			"user_agent":      client.UserAgent,
+			"session_ip":      session.IP,
+			"session_auth_at": session.AuthAt.Format(time.RFC3339),
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("create audit event: %w", err)
+	}
+
+	u.logger.Warn("refresh token reuse detected, session revoked",
+		zap.String("user.id", session.UserID.String()),
+		zap.String("session.id", session.ID.String()),
+		zap.String("ip", client.IP))
+	return true, nil
+}
+
+// Logout завершает сессию, к которой привязан refresh токен
+func (u *UserService) Logout(ctx context.Context, refreshToken string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.Logout")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	sessionRepo := u.repo.NewSessionRepo(tx)
+	session, err := sessionRepo.GetByRefreshToken(ctx, u.tm.HashToken(refreshToken))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get session: %w", err)
+	}
+	if session == nil || session.UserID.String() != userIDStr {
+		span.RecordError(model.ErrSessionNotFound)
+		return model.ErrSessionNotFound
+	}
+
+	// токены сессии отзовет триггер на удаление, но access токен запроса
+	// мог быть выдан другой сессии - отзываем и его
+	err = sessionRepo.Delete(ctx, session.ID.String())
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete session: %w", err)
+	}
+
+	if jti, ok := ctx.Value(contextkeys.TokenIDKey).(string); ok && jti != "" {
+		err = u.repo.NewRevokedTokenRepo(tx).Create(ctx, &model.RevokedToken{
+			Kind:      model.RevokedTokenJTI,
+			Value:     jti,
+			RevokedAt: time.Now(),
+		})
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("revoke access token: %w", err)
+		}
+	}
+
+	span.SetAttributes(attribute.String("session.id", session.ID.String()))
+	u.logger.Info("user logged out", zap.String("user.id", userIDStr),
+		zap.String("session.id", session.ID.String()))
+	return nil
+}
+
+// GetSessions возвращает активные сессии пользователя
+func (u *UserService) GetSessions(ctx context.Context) (dto.GetSessionsResponse, error) {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.GetSessions")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return dto.GetSessionsResponse{}, err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return dto.GetSessionsResponse{}, err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	sessionRepo := u.repo.NewSessionRepo(tx)
+	sessions, err := sessionRepo.GetAllByUserID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return dto.GetSessionsResponse{}, fmt.Errorf("get sessions: %w", err)
+	}
+
+	res := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, dto.SessionResponse{
+			ID:        s.ID.String(),
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			AuthAt:    s.AuthAt,
+			ExpireAt:  s.ExpireAt,
+		})
+	}
+
+	span.SetAttributes(attribute.Int("sessions_count", len(res)))
+	return dto.GetSessionsResponse{Sessions: res}, nil
+}
+
+// RevokeSession завершает указанную сессию пользователя
+func (u *UserService) RevokeSession(ctx context.Context, sessionID string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.RevokeSession")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	sessionRepo := u.repo.NewSessionRepo(tx)
+	session, err := sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get session: %w", err)
+	}
+	// чужую сессию не раскрываем, отвечаем как на несуществующую
+	if session == nil || session.UserID.String() != userIDStr {
+		span.RecordError(model.ErrSessionNotFound)
+		return model.ErrSessionNotFound
+	}
+
+	err = sessionRepo.Delete(ctx, sessionID)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete session: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("session.id", sessionID))
+	u.logger.Info("session revoked", zap.String("user.id", userIDStr), zap.String("session.id", sessionID))
+	return nil
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме текущей
+func (u *UserService) RevokeOtherSessions(ctx context.Context, refreshToken string) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.RevokeOtherSessions")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	sessionRepo := u.repo.NewSessionRepo(tx)
+	current, err := sessionRepo.GetByRefreshToken(ctx, u.tm.HashToken(refreshToken))
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get session: %w", err)
+	}
+	if current == nil || current.UserID.String() != userIDStr {
+		span.RecordError(model.ErrSessionNotFound)
+		return model.ErrSessionNotFound
+	}
+
+	err = sessionRepo.DeleteOthers(ctx, userIDStr, current.ID.String())
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("session.id", current.ID.String()))
+	u.logger.Info("other sessions revoked", zap.String("user.id", userIDStr))
+	return nil
+}
+
+// rehashPassword пересохраняет пароль текущим алгоритмом. Ошибки не
+// прерывают вход - попробуем при следующем входе
+func (u *UserService) rehashPassword(ctx context.Context, userRepo interfaces.UserRepositoryInterface,
+	userID, password string) {
+	hash, err := passmanager.HashPassword(password)
+	if err != nil {
+		u.logger.Warn("can't rehash password", zap.String("user.id", userID), zap.Error(err))
+		return
+	}
+	if err := userRepo.UpdatePassword(ctx, userID, hash); err != nil {
+		u.logger.Warn("can't save rehashed password", zap.String("user.id", userID), zap.Error(err))
+		return
+	}
+	u.logger.Info("password rehashed", zap.String("user.id", userID))
+}
+
+// ChangePassword меняет пароль пользователя и завершает остальные его сессии.
+// Если передан refresh токен текущей сессии, она остается активной
+func (u *UserService) ChangePassword(ctx context.Context, req dto.ChangePasswordRequest,
+	client dto.ClientInfo) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	userIDStr, ok := ctx.Value(contextkeys.UserKeyID).(string)
+	if !ok {
+		err := fmt.Errorf("userID not found in context")
+		span.RecordError(err)
+		return err
+	}
+
+	if err := req.Validate(u.passPolicy); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	userRepo := u.repo.NewUserRepo(tx)
+	sessionRepo := u.repo.NewSessionRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userIDStr)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	// подбор старого пароля учитываем так же, как при входе
+	if err := u.checkLockout(ctx, user.Login, client.IP); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if !passmanager.CheckPass(req.OldPassword, user.Password) {
+		err = u.registerAuthFailure(ctx, &user.ID, user.Login, client.IP)
+		span.RecordError(err)
+		return err
+	}
+
+	err = u.releaseAttempt(ctx, tx, user.Login, client.IP, true)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	hash, err := passmanager.HashPassword(req.NewPassword)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	err = userRepo.UpdatePassword(ctx, userIDStr, hash)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	// завершаем остальные сессии; текущую оставляем, если знаем ее
+	var keepSessionID *uuid.UUID
+	if req.RefreshToken != "" {
+		var current *model.Session
+		current, err = sessionRepo.GetByRefreshToken(ctx, u.tm.HashToken(req.RefreshToken))
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("get session: %w", err)
+		}
+		if current == nil || current.UserID.String() != userIDStr {
+			err = model.ErrSessionNotFound
+			span.RecordError(err)
+			return err
+		}
+		keepSessionID = &current.ID
+		err = sessionRepo.DeleteOthers(ctx, userIDStr, current.ID.String())
+	} else {
+		err = sessionRepo.DeleteAllByUserID(ctx, userIDStr)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      model.AuditEventPasswordChanged,
+		SessionID: keepSessionID,
+		IP:        client.IP,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userIDStr))
+	u.logger.Info("password changed", zap.String("user.id", userIDStr))
+	return nil
+}
+
+// SetUserRole меняет роль пользователя. Новая роль попадет в access токен
+// при следующем обновлении токенов
+func (u *UserService) SetUserRole(ctx context.Context, userID string, role model.Role) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.SetUserRole")
+	defer span.End()
+
+	if !role.Valid() {
+		span.RecordError(model.ErrInvalidRole)
+		return model.ErrInvalidRole
+	}
+
+	adminID, _ := ctx.Value(contextkeys.UserKeyID).(string)
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	userRepo := u.repo.NewUserRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoUser) {
+			return model.ErrUserNotFound
+		}
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	err = userRepo.UpdateRole(ctx, userID, role)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("update role: %w", err)
+	}
+
+	// роль записана в access токенах: удаление сессий отзывает их через
+	// revoked_tokens, и старые права перестают действовать сразу
+	if user.Role != role {
+		err = u.repo.NewSessionRepo(tx).DeleteAllByUserID(ctx, userID)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("delete sessions: %w", err)
+		}
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:     uuid.New(),
+		UserID: &user.ID,
+		Type:   model.AuditEventRoleChanged,
+		Details: map[string]string{
+			"previous":   string(user.Role),
+			"role":       string(role),
+			"changed_by": adminID,
+		},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.String("user.role", string(role)))
+	u.logger.Info("user role changed", zap.String("user.id", userID), zap.String("role", string(role)),
+		zap.String("changed_by", adminID))
+	return nil
+}
+
+// SetUserBanned блокирует или разблокирует пользователя. При блокировке все
+// его сессии удаляются, и выданные им access токены сразу перестают действовать
+func (u *UserService) SetUserBanned(ctx context.Context, userID string, banned bool) error {
+	ctx, span := otel.Tracer("service").Start(ctx, "UserService.SetUserBanned")
+	defer span.End()
+
+	adminID, _ := ctx.Value(contextkeys.UserKeyID).(string)
+
+	tx, err := u.repo.BeginTx(ctx, pgx.ReadCommitted)
+	if err != nil {
+		u.logger.Error("failed to begin tx", zap.Error(err))
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+		} else {
+			_ = tx.Commit(ctx)
+		}
+	}()
+
+	userRepo := u.repo.NewUserRepo(tx)
+
+	user, err := userRepo.GetByID(ctx, userID)
+	if err != nil {
+		span.RecordError(err)
+		if errors.Is(err, repository.ErrNoUser) {
+			return model.ErrUserNotFound
+		}
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	err = userRepo.SetBanned(ctx, userID, banned)
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("set banned: %w", err)
+	}
+
+	eventType := model.AuditEventUserUnbanned
+	if banned {
+		eventType = model.AuditEventUserBanned
+		// удаление сессий отзывает их токены через revoked_tokens
+		err = u.repo.NewSessionRepo(tx).DeleteAllByUserID(ctx, userID)
+		if err != nil {
+			span.RecordError(err)
+			return fmt.Errorf("delete sessions: %w", err)
+		}
+	}
+
+	err = u.repo.NewAuditRepo(tx).Create(ctx, &model.AuditEvent{
+		ID:        uuid.New(),
+		UserID:    &user.ID,
+		Type:      eventType,
+		Details:   map[string]string{"changed_by": adminID},
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("create audit event: %w", err)
+	}
+
+	span.SetAttributes(attribute.String("user.id", userID), attribute.Bool("user.banned", banned))
+	u.logger.Info("user ban updated", zap.String("user.id", userID), zap.Bool("banned", banned),
+		zap.String("changed_by", adminID))
+	return nil
+}
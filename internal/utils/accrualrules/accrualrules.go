@@ -0,0 +1,144 @@
+package accrualrules
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+// DefaultSpec правила, если ACCRUAL_RULES не задана: 5% от суммы чека
+const DefaultSpec = "percent:5"
+
+var hundred = decimal.NewFromInt(100)
+
+// Rules правила начисления баллов по чеку. Для каждой позиции действует
+// самое точное правило: баллы за SKU, затем процент категории, затем общий
+// процент. Итог за заказ ограничивается Min и Max
+type Rules struct {
+	// процент от стоимости позиций без отдельного правила
+	Percent decimal.Decimal
+	// процент от стоимости позиций категории вместо общего
+	Categories map[string]decimal.Decimal
+	// фиксированные баллы за единицу товара
+	SKUs map[string]decimal.Decimal
+	// не меньше Min баллов за чек с ненулевой суммой, ноль - без нижней границы
+	Min decimal.Decimal
+	// не больше Max баллов за чек, ноль - без верхней границы
+	Max decimal.Decimal
+}
+
+// Calculate начисление по чеку, округленное вниз до копеек
+func (r Rules) Calculate(receipt model.Receipt) decimal.Decimal {
+	accrual := decimal.Zero
+	for _, item := range receipt.Items {
+		if points, ok := r.SKUs[item.SKU]; ok {
+			accrual = accrual.Add(points.Mul(item.Quantity))
+			continue
+		}
+		percent := r.Percent
+		if p, ok := r.Categories[item.Category]; ok {
+			percent = p
+		}
+		accrual = accrual.Add(item.Amount().Mul(percent).Div(hundred))
+	}
+
+	if r.Min.IsPositive() && receipt.Total.IsPositive() && accrual.LessThan(r.Min) {
+		accrual = r.Min
+	}
+	if r.Max.IsPositive() && accrual.GreaterThan(r.Max) {
+		accrual = r.Max
+	}
+	return accrual.Truncate(2)
+}
+
+// Parse собирает правила из описания: правила через "+", например
+// "percent:5+category:coffee=10+sku:4607001771425=50+min:1+max:1000".
+// Правила: percent:P, category:NAME=P, sku:SKU=POINTS, min:POINTS и max:POINTS
+func Parse(spec string) (Rules, error) {
+	r := Rules{
+		Categories: make(map[string]decimal.Decimal),
+		SKUs:       make(map[string]decimal.Decimal),
+	}
+	if strings.TrimSpace(spec) == "" {
+		return Rules{}, fmt.Errorf("empty accrual rules spec")
+	}
+
+	for _, rule := range strings.Split(spec, "+") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), ":")
+
+		var err error
+		switch strings.ToLower(name) {
+		case "percent":
+			r.Percent, err = parsePercent(arg)
+		case "category":
+			var category string
+			var percent decimal.Decimal
+			category, percent, err = parsePair(arg, parsePercent)
+			r.Categories[strings.ToLower(category)] = percent
+		case "sku":
+			var sku string
+			var points decimal.Decimal
+			sku, points, err = parsePair(arg, parsePoints)
+			r.SKUs[sku] = points
+		case "min":
+			r.Min, err = parsePoints(arg)
+		case "max":
+			r.Max, err = parsePoints(arg)
+		default:
+			err = fmt.Errorf("unknown accrual rule %q", name)
+		}
+		if err != nil {
+			return Rules{}, err
+		}
+	}
+
+	if r.Min.IsPositive() && r.Max.IsPositive() && r.Min.GreaterThan(r.Max) {
+		return Rules{}, fmt.Errorf("min accrual %s is greater than max %s", r.Min, r.Max)
+	}
+	return r, nil
+}
+
+// NewFromEnv правила из ACCRUAL_RULES или правила по умолчанию
+func NewFromEnv() (Rules, error) {
+	spec := os.Getenv("ACCRUAL_RULES")
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultSpec
+	}
+	r, err := Parse(spec)
+	if err != nil {
+		return Rules{}, fmt.Errorf("ACCRUAL_RULES: %w", err)
+	}
+	return r, nil
+}
+
+func parsePair(arg string, parseValue func(string) (decimal.Decimal, error)) (string, decimal.Decimal, error) {
+	key, value, ok := strings.Cut(arg, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", decimal.Zero, fmt.Errorf("invalid accrual rule argument %q", arg)
+	}
+	v, err := parseValue(value)
+	if err != nil {
+		return "", decimal.Zero, err
+	}
+	return key, v, nil
+}
+
+func parsePercent(arg string) (decimal.Decimal, error) {
+	p, err := decimal.NewFromString(strings.TrimSpace(arg))
+	if err != nil || p.IsNegative() || p.GreaterThan(hundred) {
+		return decimal.Zero, fmt.Errorf("invalid percent %q", arg)
+	}
+	return p, nil
+}
+
+func parsePoints(arg string) (decimal.Decimal, error) {
+	p, err := decimal.NewFromString(strings.TrimSpace(arg))
+	if err != nil || p.IsNegative() {
+		return decimal.Zero, fmt.Errorf("invalid points %q", arg)
+	}
+	return p, nil
+}
@@ -0,0 +1,76 @@
+package accrualrules
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func receipt(items ...model.ReceiptItem) model.Receipt {
+	r := model.Receipt{Currency: "RUB", Items: items}
+	for _, item := range items {
+		r.Total = r.Total.Add(item.Amount())
+	}
+	return r
+}
+
+func item(sku, category, quantity, price string) model.ReceiptItem {
+	return model.ReceiptItem{SKU: sku, Category: category,
+		Quantity: decimal.RequireFromString(quantity), Price: decimal.RequireFromString(price)}
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		receipt model.Receipt
+		want    string
+	}{
+		{"percent of total", "percent:5", receipt(item("A", "", "2", "149.5"), item("B", "", "1", "100")), "19.95"},
+		{"rounded down", "percent:3", receipt(item("A", "", "1", "99.99")), "2.99"},
+		{
+			"category overrides percent", "percent:5+category:coffee=10",
+			receipt(item("A", "coffee", "2", "150"), item("B", "bakery", "1", "100")), "35",
+		},
+		{
+			"sku points per unit", "percent:5+category:coffee=10+sku:A=20",
+			receipt(item("A", "coffee", "3", "150"), item("B", "", "1", "100")), "65",
+		},
+		{"min cap", "percent:1+min:10", receipt(item("A", "", "1", "50")), "10"},
+		{"min skips empty receipt", "percent:1+min:10", receipt(item("A", "", "1", "0")), "0"},
+		{"max cap", "percent:10+max:100", receipt(item("A", "", "1", "5000")), "100"},
+		{"category names are case insensitive", "category:Coffee=10", receipt(item("A", "coffee", "1", "100")), "10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := rules.Calculate(tt.receipt)
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"bonus:5",
+		"percent:abc",
+		"percent:101",
+		"percent:-1",
+		"category:coffee",
+		"category:=10",
+		"sku:A=-5",
+		"min:50+max:10",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
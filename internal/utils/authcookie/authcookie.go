@@ -0,0 +1,143 @@
+// Package authcookie настройки cookie для браузерного режима аутентификации:
+// refresh токен хранится в HttpOnly cookie, а для защиты от CSRF рядом
+// кладется читаемый из JS csrf токен (double-submit)
+package authcookie
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	RefreshCookie = "refresh_token"
+	CSRFCookie    = "csrf_token"
+	// state начатого входа через внешний провайдер: callback принимается
+	// только от браузера, который начал вход
+	LoginStateCookie = "oidc_state"
+	loginStatePath   = "/api/v1/auth/oidc"
+	// заголовок, в котором клиент повторяет значение CSRFCookie
+	CSRFHeader = "X-CSRF-Token"
+	// заголовок, которым клиент включает режим cookie
+	ModeHeader = "X-Auth-Mode"
+	ModeCookie = "cookie"
+	// путь, на котором refresh cookie выдавалась до появления браузерного режима
+	legacyPath = "/"
+)
+
+type Config struct {
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+	MaxAge   time.Duration
+}
+
+// NewConfigFromEnv читает AUTH_COOKIE_*. maxAge - время жизни refresh токена
+func NewConfigFromEnv(maxAge time.Duration) Config {
+	cfg := Config{
+		Domain:   os.Getenv("AUTH_COOKIE_DOMAIN"),
+		Path:     os.Getenv("AUTH_COOKIE_PATH"),
+		Secure:   os.Getenv("AUTH_COOKIE_SECURE") != "false",
+		SameSite: parseSameSite(os.Getenv("AUTH_COOKIE_SAMESITE")),
+		MaxAge:   maxAge,
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/api/v1/refresh"
+	}
+	// браузеры отбрасывают SameSite=None без Secure
+	if cfg.SameSite == http.SameSiteNoneMode {
+		cfg.Secure = true
+	}
+	return cfg
+}
+
+func parseSameSite(v string) http.SameSite {
+	switch strings.ToLower(v) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+// Refresh HttpOnly cookie с refresh токеном, видна только на пути refresh
+func (cfg Config) Refresh(token string) *http.Cookie {
+	return cfg.cookie(RefreshCookie, token, cfg.Path, true)
+}
+
+// CSRF cookie с csrf токеном. Должна читаться фронтендом, поэтому не HttpOnly
+func (cfg Config) CSRF(token string) *http.Cookie {
+	return cfg.cookie(CSRFCookie, token, "/", false)
+}
+
+// LoginState HttpOnly cookie со state входа через провайдер. Живет до
+// закрытия браузера, срок state проверяет сервер. Провайдер возвращает
+// браузер переходом с другого сайта, поэтому SameSite не строже Lax
+func (cfg Config) LoginState(state string) *http.Cookie {
+	c := cfg.cookie(LoginStateCookie, state, loginStatePath, true)
+	c.MaxAge = 0
+	if c.SameSite == http.SameSiteStrictMode {
+		c.SameSite = http.SameSiteLaxMode
+	}
+	return c
+}
+
+// ClearLoginState удаляет cookie со state входа
+func (cfg Config) ClearLoginState() *http.Cookie {
+	c := cfg.LoginState("")
+	c.MaxAge = -1
+	return c
+}
+
+// Clear cookie, удаляющие обе cookie в браузере, включая refresh cookie
+// со старого пути
+func (cfg Config) Clear() []*http.Cookie {
+	cookies := []*http.Cookie{cfg.Refresh(""), cfg.CSRF("")}
+	for _, c := range cookies {
+		c.MaxAge = -1
+	}
+	if legacy := cfg.ClearLegacy(); legacy != nil {
+		cookies = append(cookies, legacy)
+	}
+	return cookies
+}
+
+// ClearLegacy удаляет refresh cookie, выданную раньше с путем "/": иначе
+// браузер продолжает отправлять ее на все запросы API. nil, если путь
+// совпадает с текущим
+func (cfg Config) ClearLegacy() *http.Cookie {
+	if cfg.Path == legacyPath {
+		return nil
+	}
+	c := cfg.cookie(RefreshCookie, "", legacyPath, true)
+	c.MaxAge = -1
+	return c
+}
+
+func (cfg Config) cookie(name, value, path string, httpOnly bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   cfg.Domain,
+		MaxAge:   int(cfg.MaxAge.Seconds()),
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	}
+}
+
+// NewCSRFToken случайный csrf токен
+func NewCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
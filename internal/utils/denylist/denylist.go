@@ -0,0 +1,96 @@
+// Package denylist хранит в памяти отозванные access токены. Запись нужна,
+// пока может быть жив отозванный ею токен, то есть не дольше времени жизни
+// access токена с момента отзыва
+package denylist
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+type Denylist struct {
+	mu  sync.RWMutex
+	ttl time.Duration
+	// kind:value -> время отзыва
+	entries map[string]time.Time
+}
+
+// New создает denylist, записи которого живут ttl (время жизни access токена)
+func New(ttl time.Duration) *Denylist {
+	return &Denylist{
+		ttl:     ttl,
+		entries: make(map[string]time.Time),
+	}
+}
+
+func key(kind model.RevokedTokenKind, value string) string {
+	return string(kind) + ":" + value
+}
+
+// Add добавляет отозванный токен или сессию
+func (d *Denylist) Add(kind model.RevokedTokenKind, value string, revokedAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[key(kind, value)] = revokedAt
+}
+
+// AddPayload добавляет запись из уведомления вида "kind:value"
+func (d *Denylist) AddPayload(payload string, revokedAt time.Time) bool {
+	kind, value, ok := strings.Cut(payload, ":")
+	if !ok || value == "" {
+		return false
+	}
+	switch model.RevokedTokenKind(kind) {
+	case model.RevokedTokenJTI, model.RevokedTokenSID:
+		d.Add(model.RevokedTokenKind(kind), value, revokedAt)
+		return true
+	default:
+		return false
+	}
+}
+
+// Revoked проверяет, отозван ли токен с данными jti и sid. Пустые значения
+// не проверяются
+func (d *Denylist) Revoked(jti, sid string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if jti != "" {
+		if _, ok := d.entries[key(model.RevokedTokenJTI, jti)]; ok {
+			return true
+		}
+	}
+	if sid != "" {
+		if _, ok := d.entries[key(model.RevokedTokenSID, sid)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Prune удаляет записи, токены которых к now уже истекли сами
+func (d *Denylist) Prune(now time.Time) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	removed := 0
+	for k, revokedAt := range d.entries {
+		if now.Sub(revokedAt) > d.ttl {
+			delete(d.entries, k)
+			removed++
+		}
+	}
+	return removed
+}
+
+func (d *Denylist) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.entries)
+}
+
+// TTL сколько нужно помнить отозванный токен
+func (d *Denylist) TTL() time.Duration {
+	return d.ttl
+}
@@ -0,0 +1,51 @@
+package denylist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestDenylist(t *testing.T) {
+	now := time.Now()
+	d := New(time.Minute)
+
+	d.Add(model.RevokedTokenJTI, "jti-1", now)
+	if !d.AddPayload("sid:session-1", now.Add(-2*time.Minute)) {
+		t.Fatal("valid payload rejected")
+	}
+	for _, payload := range []string{"", "sid", "sid:", "user:1"} {
+		if d.AddPayload(payload, now) {
+			t.Errorf("payload %q accepted", payload)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		jti     string
+		sid     string
+		revoked bool
+	}{
+		{name: "revoked jti", jti: "jti-1", sid: "session-2", revoked: true},
+		{name: "revoked session", jti: "jti-2", sid: "session-1", revoked: true},
+		{name: "active token", jti: "jti-2", sid: "session-2"},
+		{name: "token without claims"},
+		{name: "jti is not sid", sid: "jti-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.Revoked(tt.jti, tt.sid); got != tt.revoked {
+				t.Errorf("Revoked(%q, %q) = %v, want %v", tt.jti, tt.sid, got, tt.revoked)
+			}
+		})
+	}
+
+	// запись о сессии старше ttl - ее токены уже истекли
+	if removed := d.Prune(now); removed != 1 {
+		t.Fatalf("Prune removed %d entries, want 1", removed)
+	}
+	if d.Revoked("", "session-1") || !d.Revoked("jti-1", "") {
+		t.Error("Prune removed wrong entries")
+	}
+}
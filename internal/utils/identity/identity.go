@@ -0,0 +1,83 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrExchangeFailed провайдер не подтвердил код авторизации или вернул
+// некорректный id_token
+var ErrExchangeFailed = errors.New("identity provider rejected authorization")
+
+// Identity пользователь, подтвержденный внешним провайдером. Subject
+// уникален в пределах провайдера и не меняется
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider внешний провайдер входа (корпоративный IdP и т.п.)
+type Provider interface {
+	Name() string
+	// AuthURL адрес страницы входа провайдера, на который отправляется браузер
+	AuthURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange меняет код авторизации на личность пользователя. codeVerifier
+	// и nonce - значения, выданные при формировании AuthURL
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+// NewRandomToken случайная строка для state, nonce и PKCE code_verifier
+func NewRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge PKCE code_challenge по методу S256 (RFC 7636)
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// NewFromEnv создает провайдеры из OIDC_PROVIDERS - списка имен через запятую.
+// Для каждого имени читаются OIDC_<ИМЯ>_ISSUER, _CLIENT_ID, _CLIENT_SECRET,
+// _REDIRECT_URL и _SCOPES
+func NewFromEnv() (map[string]Provider, error) {
+	providers := make(map[string]Provider)
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		cfg := OIDCConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+		}
+		if scopes := os.Getenv(prefix + "SCOPES"); scopes != "" {
+			cfg.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+
+		p, err := NewOIDCProvider(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %w", name, err)
+		}
+		providers[name] = p
+	}
+	return providers, nil
+}
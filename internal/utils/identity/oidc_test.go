@@ -0,0 +1,178 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "loyalityhub"
+
+// mockIdP минимальный OIDC провайдер: выдает код на указанный
+// code_challenge и проверяет code_verifier при обмене
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		grant, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		idp.mu.Unlock()
+
+		if !ok || CodeChallenge(r.FormValue("code_verifier")) != grant.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            idp.URL,
+			"sub":            "employee-42",
+			"aud":            testClientID,
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          grant.nonce,
+			"email":          "ivan@corp.example",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize имитирует вход пользователя на странице провайдера
+func (idp *mockIdP) authorize(t *testing.T, authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != testClientID {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	code := "code-" + q.Get("state")
+	idp.codes[code] = mockGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	return code
+}
+
+func TestOIDCProvider(t *testing.T) {
+	idp := newMockIdP(t)
+	ctx := context.Background()
+
+	p, err := NewOIDCProvider(OIDCConfig{
+		Name:        "corp",
+		Issuer:      idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost/callback",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := func(state string) (code, verifier, nonce string) {
+		verifier, _ = NewRandomToken()
+		nonce, _ = NewRandomToken()
+		authURL, err := p.AuthURL(ctx, state, nonce, CodeChallenge(verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return idp.authorize(t, authURL), verifier, nonce
+	}
+
+	t.Run("success", func(t *testing.T) {
+		code, verifier, nonce := start("ok")
+		ident, err := p.Exchange(ctx, code, verifier, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ident.Provider != "corp" || ident.Subject != "employee-42" ||
+			ident.Email != "ivan@corp.example" || !ident.EmailVerified {
+			t.Errorf("unexpected identity %+v", ident)
+		}
+	})
+
+	t.Run("wrong verifier", func(t *testing.T) {
+		code, _, nonce := start("verifier")
+		_, err := p.Exchange(ctx, code, "another-verifier", nonce)
+		if !errors.Is(err, ErrExchangeFailed) {
+			t.Errorf("expected ErrExchangeFailed, got %v", err)
+		}
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		code, verifier, _ := start("nonce")
+		_, err := p.Exchange(ctx, code, verifier, "another-nonce")
+		if !errors.Is(err, ErrExchangeFailed) {
+			t.Errorf("expected ErrExchangeFailed, got %v", err)
+		}
+	})
+
+	t.Run("code reuse", func(t *testing.T) {
+		code, verifier, nonce := start("reuse")
+		if _, err := p.Exchange(ctx, code, verifier, nonce); err != nil {
+			t.Fatal(err)
+		}
+		_, err := p.Exchange(ctx, code, verifier, nonce)
+		if !errors.Is(err, ErrExchangeFailed) {
+			t.Errorf("expected ErrExchangeFailed, got %v", err)
+		}
+	})
+}
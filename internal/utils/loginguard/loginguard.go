@@ -0,0 +1,133 @@
+package loginguard
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+const (
+	ScopeLogin = "login"
+	ScopeIP    = "ip"
+)
+
+// Policy правила ограничения попыток входа для одного ключа.
+// После каждой неудачи следующая попытка разрешена не раньше чем через
+// BaseDelay * 2^(n-1) (но не больше MaxDelay), а после MaxFailures неудач
+// ключ блокируется на LockoutDuration
+type Policy struct {
+	MaxFailures     int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+	// через сколько после последней неудачи счетчик обнуляется
+	ResetAfter time.Duration
+}
+
+// Config политики для логина и для IP адреса
+type Config struct {
+	Login Policy
+	IP    Policy
+}
+
+func NewConfig() Config {
+	return Config{
+		Login: Policy{
+			MaxFailures:     envInt("AUTH_MAX_FAILURES", 5),
+			BaseDelay:       envDuration("AUTH_BASE_DELAY", time.Second),
+			MaxDelay:        envDuration("AUTH_MAX_DELAY", 30*time.Second),
+			LockoutDuration: envDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+			ResetAfter:      envDuration("AUTH_LOCKOUT_RESET_AFTER", 15*time.Minute),
+		},
+		IP: Policy{
+			MaxFailures:     envInt("AUTH_IP_MAX_FAILURES", 50),
+			LockoutDuration: envDuration("AUTH_LOCKOUT_DURATION", 15*time.Minute),
+			ResetAfter:      envDuration("AUTH_LOCKOUT_RESET_AFTER", 15*time.Minute),
+		},
+	}
+}
+
+// LoginKey ключ счетчика для логина
+func LoginKey(login string) string {
+	return ScopeLogin + ":" + login
+}
+
+// IPKey ключ счетчика для IP адреса
+func IPKey(ip string) string {
+	return ScopeIP + ":" + ip
+}
+
+// RetryAfter сколько еще нужно подождать до следующей попытки
+func (p Policy) RetryAfter(a *model.AuthAttempt, now time.Time) time.Duration {
+	if a == nil || p.expired(a, now) {
+		return 0
+	}
+	if a.LockedUntil != nil {
+		return a.LockedUntil.Sub(now)
+	}
+
+	next := a.LastFailureAt.Add(p.delay(a.Failures))
+	if next.After(now) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// Fail учитывает неудачную попытку. В Postgres то же самое вместе с проверкой
+// задержки выполняет AuthAttemptRepo.Acquire. Возвращает true, если ключ заблокирован
+func (p Policy) Fail(a *model.AuthAttempt, now time.Time) bool {
+	if p.expired(a, now) {
+		a.Failures = 0
+		a.LockedUntil = nil
+	}
+
+	a.Failures++
+	a.LastFailureAt = now
+	if p.MaxFailures > 0 && a.Failures >= p.MaxFailures {
+		lockedUntil := now.Add(p.LockoutDuration)
+		a.LockedUntil = &lockedUntil
+		return true
+	}
+	return false
+}
+
+// expired блокировка закончилась или неудач давно не было
+func (p Policy) expired(a *model.AuthAttempt, now time.Time) bool {
+	if a.LockedUntil != nil {
+		return !a.LockedUntil.After(now)
+	}
+	return p.ResetAfter > 0 && now.Sub(a.LastFailureAt) >= p.ResetAfter
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	if p.BaseDelay <= 0 || failures <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < failures; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
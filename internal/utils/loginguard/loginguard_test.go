@@ -0,0 +1,90 @@
+package loginguard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := Policy{
+		MaxFailures:     3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutDuration: time.Minute,
+		ResetAfter:      time.Minute,
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		failures   int
+		after      time.Duration
+		retryAfter time.Duration
+	}{
+		{
+			name:       "no failures",
+			failures:   0,
+			retryAfter: 0,
+		},
+		{
+			name:       "first failure delays",
+			failures:   1,
+			retryAfter: time.Second,
+		},
+		{
+			name:       "delay doubles",
+			failures:   2,
+			retryAfter: 2 * time.Second,
+		},
+		{
+			name:       "delay passed",
+			failures:   2,
+			after:      3 * time.Second,
+			retryAfter: 0,
+		},
+		{
+			name:       "locked out",
+			failures:   3,
+			after:      10 * time.Second,
+			retryAfter: 50 * time.Second,
+		},
+		{
+			name:       "lockout expired",
+			failures:   3,
+			after:      time.Minute,
+			retryAfter: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempt := &model.AuthAttempt{}
+			for i := 0; i < tt.failures; i++ {
+				policy.Fail(attempt, now)
+			}
+
+			got := policy.RetryAfter(attempt, now.Add(tt.after))
+			if got != tt.retryAfter {
+				t.Errorf("expected retry after %s, got %s", tt.retryAfter, got)
+			}
+		})
+	}
+}
+
+func TestPolicyResetsAfterLockout(t *testing.T) {
+	policy := Policy{MaxFailures: 2, LockoutDuration: time.Minute}
+	now := time.Now()
+	attempt := &model.AuthAttempt{}
+
+	policy.Fail(attempt, now)
+	if locked := policy.Fail(attempt, now); !locked {
+		t.Fatal("expected lockout after max failures")
+	}
+
+	// после окончания блокировки счет начинается заново
+	if locked := policy.Fail(attempt, now.Add(2*time.Minute)); locked || attempt.Failures != 1 {
+		t.Errorf("expected counter reset, got %d failures", attempt.Failures)
+	}
+}
@@ -1,33 +1,51 @@
 package lunavalidate
 
-import (
-	"log"
-	"strconv"
-)
-
-// валидация алгоритмом луна
+// валидация алгоритмом луна в том виде, в каком сервис проверял номера
+// с первой версии: удваиваются цифры на четных позициях слева. Для номеров
+// четной длины результат совпадает с ValidateStandard, номера нечетной
+// длины проверяются иначе
 func Validate(number string) bool {
-	log.Println(number)
 	sum := 0
 	if len(number) == 0 {
 		return false
 	}
 	for i := 0; i < len(number); i++ {
-		n, err := strconv.Atoi(string(number[i]))
-		if err != nil {
+		c := number[i]
+		if c < '0' || c > '9' {
 			return false
 		}
-		if i%2 == 1 {
-			sum += n
-		} else {
-			prom := n * 2
-			if prom > 9 {
-				prom -= 9
+		n := int(c - '0')
+		if i%2 == 0 {
+			n *= 2
+			if n > 9 {
+				n -= 9
 			}
-			sum += prom
 		}
+		sum += n
+	}
+	return sum%10 == 0
+}
 
+// ValidateStandard валидация алгоритмом луна по ISO/IEC 7812: удваивается
+// каждая вторая цифра справа, не считая контрольной
+func ValidateStandard(number string) bool {
+	sum := 0
+	if len(number) == 0 {
+		return false
+	}
+	for i := 0; i < len(number); i++ {
+		c := number[len(number)-1-i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		n := int(c - '0')
+		if i%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
 	}
-	log.Println(sum)
 	return sum%10 == 0
 }
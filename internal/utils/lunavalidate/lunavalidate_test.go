@@ -0,0 +1,35 @@
+package lunavalidate
+
+import "testing"
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		number   string
+		legacy   bool
+		standard bool
+	}{
+		// четная длина: алгоритмы совпадают
+		{"4561261212345467", true, true},
+		{"2377225624", true, true},
+		{"12345674", true, true},
+		{"7992739871", false, false},
+		// нечетная длина: прежняя проверка удваивает контрольную цифру
+		{"79927398713", false, true},
+		{"79927398712", true, false},
+		{"12345678903", false, true},
+		{"0", true, true},
+		{"", false, false},
+		{"12a4", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			if got := Validate(tt.number); got != tt.legacy {
+				t.Errorf("Validate(%q) = %v, want %v", tt.number, got, tt.legacy)
+			}
+			if got := ValidateStandard(tt.number); got != tt.standard {
+				t.Errorf("ValidateStandard(%q) = %v, want %v", tt.number, got, tt.standard)
+			}
+		})
+	}
+}
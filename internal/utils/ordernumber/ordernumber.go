@@ -0,0 +1,290 @@
+package ordernumber
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/utils/lunavalidate"
+)
+
+// DefaultSpec правило по умолчанию, как до появления настроек: проверка
+// Луна в прежнем виде, чтобы номера нечетной длины принимались как раньше
+const DefaultSpec = "luhn:legacy"
+
+// OrderNumberValidator проверка номера заказа. Ошибка обернута в
+// model.ErrBadOrderNumber и называет нарушенное правило
+type OrderNumberValidator interface {
+	Validate(number string) error
+}
+
+func reject(rule, format string, args ...any) error {
+	return fmt.Errorf("%w: %s: %s", model.ErrBadOrderNumber, rule, fmt.Sprintf(format, args...))
+}
+
+// Luhn контрольная цифра по алгоритму Луна. Legacy - проверка в том виде,
+// в каком она работала до появления настроек (см. lunavalidate.Validate)
+type Luhn struct {
+	Legacy bool
+}
+
+func (l Luhn) Validate(number string) error {
+	if !isDigits(number) {
+		return reject("luhn", "must contain only digits")
+	}
+	valid := lunavalidate.ValidateStandard
+	if l.Legacy {
+		valid = lunavalidate.Validate
+	}
+	if !valid(number) {
+		return reject("luhn", "invalid check digit")
+	}
+	return nil
+}
+
+// GTIN контрольная цифра штрихкода EAN-8, UPC-A (GTIN-12), EAN-13 или GTIN-14
+type GTIN struct{}
+
+func (GTIN) Validate(number string) error {
+	if !isDigits(number) {
+		return reject("gtin", "must contain only digits")
+	}
+	switch len(number) {
+	case 8, 12, 13, 14:
+	default:
+		return reject("gtin", "must be 8, 12, 13 or 14 digits long")
+	}
+
+	// веса 3 и 1 чередуются справа налево, начиная с цифры перед контрольной
+	sum := 0
+	for i := len(number) - 2; i >= 0; i-- {
+		n := int(number[i] - '0')
+		if (len(number)-2-i)%2 == 0 {
+			n *= 3
+		}
+		sum += n
+	}
+	if check := (10 - sum%10) % 10; check != int(number[len(number)-1]-'0') {
+		return reject("gtin", "invalid check digit")
+	}
+	return nil
+}
+
+// Length длина номера в символах, Max 0 - без ограничения сверху
+type Length struct {
+	Min int
+	Max int
+}
+
+func (l Length) Validate(number string) error {
+	n := len([]rune(number))
+	switch {
+	case l.Min == l.Max && n != l.Min:
+		return reject("length", "must be exactly %d characters long", l.Min)
+	case n < l.Min:
+		return reject("length", "must be at least %d characters long", l.Min)
+	case l.Max > 0 && n > l.Max:
+		return reject("length", "must be at most %d characters long", l.Max)
+	}
+	return nil
+}
+
+// Prefix номер начинается с одного из префиксов
+type Prefix []string
+
+func (p Prefix) Validate(number string) error {
+	for _, prefix := range p {
+		if strings.HasPrefix(number, prefix) {
+			return nil
+		}
+	}
+	return reject("prefix", "must start with %s", strings.Join(p, " or "))
+}
+
+// Regex номер целиком совпадает с выражением
+type Regex struct {
+	re *regexp.Regexp
+}
+
+// NewRegex компилирует выражение и привязывает его к началу и концу номера
+func NewRegex(pattern string) (Regex, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return Regex{}, fmt.Errorf("compile order number regex: %w", err)
+	}
+	return Regex{re: re}, nil
+}
+
+func (r Regex) Validate(number string) error {
+	if !r.re.MatchString(number) {
+		return reject("regex", "must match %s", r.re.String())
+	}
+	return nil
+}
+
+// All номер должен пройти все правила, ошибка - первое нарушенное
+type All []OrderNumberValidator
+
+func (a All) Validate(number string) error {
+	for _, v := range a {
+		if err := v.Validate(number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parse собирает проверку из описания: правила через "+", например
+// "prefix:77+length:12+luhn". Правила: luhn (luhn:legacy - прежняя
+// проверка), gtin (ean), length:N или length:MIN-MAX, prefix:A|B и regex:PATTERN. regex забирает остаток строки
+// целиком, поэтому он может быть только последним
+func Parse(spec string) (OrderNumberValidator, error) {
+	var rules All
+	rest := strings.TrimSpace(spec)
+	for rest != "" {
+		var rule string
+		if strings.HasPrefix(rest, "regex:") {
+			rule, rest = rest, ""
+		} else {
+			rule, rest, _ = strings.Cut(rest, "+")
+		}
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), ":")
+
+		switch strings.ToLower(name) {
+		case "luhn":
+			switch strings.ToLower(arg) {
+			case "":
+				rules = append(rules, Luhn{})
+			case "legacy":
+				rules = append(rules, Luhn{Legacy: true})
+			default:
+				return nil, fmt.Errorf("invalid luhn rule %q", arg)
+			}
+		case "gtin", "ean":
+			rules = append(rules, GTIN{})
+		case "length":
+			l, err := parseLength(arg)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, l)
+		case "prefix":
+			if arg == "" {
+				return nil, fmt.Errorf("prefix rule needs at least one prefix")
+			}
+			rules = append(rules, Prefix(strings.Split(arg, "|")))
+		case "regex":
+			r, err := NewRegex(arg)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, r)
+		default:
+			return nil, fmt.Errorf("unknown order number rule %q", name)
+		}
+		rest = strings.TrimSpace(rest)
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("empty order number validator spec")
+	}
+	if len(rules) == 1 {
+		return rules[0], nil
+	}
+	return rules, nil
+}
+
+func parseLength(arg string) (Length, error) {
+	from, to, isRange := strings.Cut(arg, "-")
+	lo, err := strconv.Atoi(from)
+	if err != nil || lo < 0 {
+		return Length{}, fmt.Errorf("invalid length rule %q", arg)
+	}
+	if !isRange {
+		return Length{Min: lo, Max: lo}, nil
+	}
+	hi, err := strconv.Atoi(to)
+	if err != nil || hi < lo {
+		return Length{}, fmt.Errorf("invalid length rule %q", arg)
+	}
+	return Length{Min: lo, Max: hi}, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Registry выбирает проверку по мерчанту, которому принадлежит заказ
+type Registry struct {
+	def       OrderNumberValidator
+	merchants map[uuid.UUID]OrderNumberValidator
+}
+
+func NewRegistry(def OrderNumberValidator, merchants map[uuid.UUID]OrderNumberValidator) *Registry {
+	if merchants == nil {
+		merchants = make(map[uuid.UUID]OrderNumberValidator)
+	}
+	return &Registry{def: def, merchants: merchants}
+}
+
+// For проверка для мерчанта или проверка по умолчанию, если мерчант не задан
+// или для него нет отдельных правил
+func (r *Registry) For(merchantID *uuid.UUID) OrderNumberValidator {
+	if merchantID != nil {
+		if v, ok := r.merchants[*merchantID]; ok {
+			return v
+		}
+	}
+	return r.def
+}
+
+// Lookup отдельная проверка, заданная для id. ok - правила для id есть
+func (r *Registry) Lookup(id uuid.UUID) (v OrderNumberValidator, ok bool) {
+	v, ok = r.merchants[id]
+	return v, ok
+}
+
+// NewFromEnv проверка по умолчанию из ORDER_NUMBER_VALIDATOR (DefaultSpec,
+// если не задана) и проверки мерчантов из ORDER_NUMBER_MERCHANT_VALIDATORS в виде
+// "<id мерчанта>=<описание>" через ";". Вместо id мерчанта можно указать id
+// пользователя-владельца, как до появления карточек мерчантов
+func NewFromEnv() (*Registry, error) {
+	spec := os.Getenv("ORDER_NUMBER_VALIDATOR")
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultSpec
+	}
+	def, err := Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ORDER_NUMBER_VALIDATOR: %w", err)
+	}
+
+	merchants := make(map[uuid.UUID]OrderNumberValidator)
+	for _, entry := range strings.Split(os.Getenv("ORDER_NUMBER_MERCHANT_VALIDATORS"), ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		id, spec, ok := strings.Cut(entry, "=")
+		merchantID, err := uuid.Parse(strings.TrimSpace(id))
+		if !ok || err != nil {
+			return nil, fmt.Errorf("ORDER_NUMBER_MERCHANT_VALIDATORS: invalid entry %q", entry)
+		}
+		v, err := Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("ORDER_NUMBER_MERCHANT_VALIDATORS: merchant %s: %w", merchantID, err)
+		}
+		merchants[merchantID] = v
+	}
+	return NewRegistry(def, merchants), nil
+}
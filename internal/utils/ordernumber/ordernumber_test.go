@@ -0,0 +1,108 @@
+package ordernumber
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestParseAndValidate(t *testing.T) {
+	tests := []struct {
+		spec   string
+		number string
+		// пусто - номер проходит, иначе подстрока ошибки
+		wantErr string
+	}{
+		{"luhn", "79927398713", ""},
+		{"luhn", "12345678903", ""},
+		{"luhn", "79927398710", "luhn: invalid check digit"},
+		{"luhn", "7992739871a", "luhn: must contain only digits"},
+		// прежняя проверка расходится со стандартной на нечетной длине
+		{"luhn:legacy", "79927398712", ""},
+		{"luhn:legacy", "79927398713", "luhn: invalid check digit"},
+		{"luhn:legacy", "4561261212345467", ""},
+		{"LUHN:Legacy", "2377225624", ""},
+		{"gtin", "4006381333931", ""},
+		{"ean", "96385074", ""},
+		{"gtin", "036000291452", ""},
+		{"gtin", "4006381333932", "gtin: invalid check digit"},
+		{"gtin", "400638133393", "gtin: invalid check digit"},
+		{"gtin", "40063813339", "gtin: must be 8, 12, 13 or 14 digits long"},
+		{"length:12", "123456789012", ""},
+		{"length:12", "12345678901", "length: must be exactly 12 characters long"},
+		{"length:4-6", "123", "length: must be at least 4 characters long"},
+		{"length:4-6", "1234567", "length: must be at most 6 characters long"},
+		{"prefix:77|78+length:6", "781234", ""},
+		{"prefix:77|78+length:6", "791234", "prefix: must start with 77 or 78"},
+		{"prefix:77|78+length:6", "7712345", "length: must be exactly 6 characters long"},
+		{"length:8-20+regex:R-[0-9A-Z]+", "R-AB12CD34", ""},
+		{"length:8-20+regex:R-[0-9A-Z]+", "R-ab12cd34", "regex: must match"},
+		// выражение привязано к началу и концу номера
+		{"regex:[0-9]{3}|X", "1234", "regex: must match"},
+		{"regex:[0-9]{3}|X", "X", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+"/"+tt.number, func(t *testing.T) {
+			v, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = v.Validate(tt.number)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected %s to pass, got %v", tt.number, err)
+				}
+				return
+			}
+			if !errors.Is(err, model.ErrBadOrderNumber) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{"", "crc32", "luhn:strict", "length:x", "length:6-4", "prefix:", "regex:("} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestRegistryFromEnv(t *testing.T) {
+	merchant := uuid.New()
+	t.Setenv("ORDER_NUMBER_VALIDATOR", "")
+	t.Setenv("ORDER_NUMBER_MERCHANT_VALIDATORS", merchant.String()+"=gtin; ")
+
+	r, err := NewFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// по умолчанию остается прежняя проверка Луна
+	if err := r.For(nil).Validate("79927398712"); err != nil {
+		t.Errorf("default validator: %v", err)
+	}
+	other := uuid.New()
+	if err := r.For(&other).Validate("4006381333931"); err == nil {
+		t.Error("expected merchant without rules to use luhn")
+	}
+	if err := r.For(&merchant).Validate("4006381333931"); err != nil {
+		t.Errorf("merchant validator: %v", err)
+	}
+	if _, ok := r.Lookup(merchant); !ok {
+		t.Error("expected lookup to find merchant rules")
+	}
+	if _, ok := r.Lookup(other); ok {
+		t.Error("expected lookup to miss merchant without rules")
+	}
+
+	t.Setenv("ORDER_NUMBER_MERCHANT_VALIDATORS", "not-a-uuid=luhn")
+	if _, err := NewFromEnv(); err == nil {
+		t.Error("expected invalid merchant id to be rejected")
+	}
+}
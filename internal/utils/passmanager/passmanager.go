@@ -1,13 +1,101 @@
 package passmanager
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// параметры argon2id для новых хешей
+const (
+	argonTime    uint32 = 2
+	argonMemory  uint32 = 64 * 1024
+	argonThreads uint8  = 2
+	argonKeyLen  uint32 = 32
+	argonSaltLen        = 16
+)
+
+var errBadHash = errors.New("malformed argon2id hash")
+
+type argonParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// HashPassword хеширует пароль argon2id. Хеш самоописываемый (формат PHC):
+// $argon2id$v=19$m=65536,t=2,p=2$<salt>$<hash>
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
+// CheckPass сверяет пароль с хешем argon2id или с устаревшим bcrypt хешем
 func CheckPass(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		return err == nil
+	}
+
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+	return subtle.ConstantTimeCompare(key, p.key) == 1
+}
+
+// NeedsRehash сообщает, что хеш сделан не argon2id или с устаревшими
+// параметрами и его стоит пересчитать при следующем успешном входе
+func NeedsRehash(hash string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return p.time != argonTime || p.memory != argonMemory ||
+		p.threads != argonThreads || uint32(len(p.key)) != argonKeyLen
+}
+
+func parseArgon2id(hash string) (*argonParams, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, errBadHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, errBadHash
+	}
+
+	var p argonParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, errBadHash
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errBadHash
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(p.key) == 0 {
+		return nil, errBadHash
+	}
+	return &p, nil
 }
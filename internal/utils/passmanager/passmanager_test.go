@@ -0,0 +1,84 @@
+package passmanager
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndCheck(t *testing.T) {
+	hash, err := HashPassword("superpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$") {
+		t.Fatalf("expected argon2id hash, got %s", hash)
+	}
+
+	other, err := HashPassword("superpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == other {
+		t.Error("expected different salts for equal passwords")
+	}
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("superpass"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		hash        string
+		valid       bool
+		needsRehash bool
+	}{
+		{
+			name:     "argon2id valid",
+			password: "superpass",
+			hash:     hash,
+			valid:    true,
+		},
+		{
+			name:     "argon2id wrong password",
+			password: "wrongpass",
+			hash:     hash,
+			valid:    false,
+		},
+		{
+			name:        "legacy bcrypt",
+			password:    "superpass",
+			hash:        string(bcryptHash),
+			valid:       true,
+			needsRehash: true,
+		},
+		{
+			name:        "outdated argon2id params",
+			password:    "superpass",
+			hash:        strings.Replace(hash, "t=2", "t=1", 1),
+			valid:       false,
+			needsRehash: true,
+		},
+		{
+			name:        "malformed hash",
+			password:    "superpass",
+			hash:        "$argon2id$garbage",
+			valid:       false,
+			needsRehash: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPass(tt.password, tt.hash); got != tt.valid {
+				t.Errorf("CheckPass: expected %v, got %v", tt.valid, got)
+			}
+			if got := NeedsRehash(tt.hash); got != tt.needsRehash {
+				t.Errorf("NeedsRehash: expected %v, got %v", tt.needsRehash, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package passpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+const (
+	defaultMinLength = 8
+	defaultMaxLength = 128
+)
+
+// Policy требования к паролю: длина и отсутствие в списке утекших паролей
+type Policy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+// New создает политику. breachedFile - локальный файл со списком утекших
+// паролей, по одному на строку; пустой путь отключает проверку
+func New(minLength, maxLength int, breachedFile string) (*Policy, error) {
+	p := &Policy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  make(map[string]struct{}),
+	}
+	if breachedFile == "" {
+		return p, nil
+	}
+
+	file, err := os.Open(breachedFile)
+	if err != nil {
+		return nil, fmt.Errorf("open breached passwords list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached passwords list: %w", err)
+	}
+	return p, nil
+}
+
+// NewFromEnv политика из PASSWORD_MIN_LENGTH, PASSWORD_MAX_LENGTH и PASSWORD_BREACHED_LIST
+func NewFromEnv() (*Policy, error) {
+	return New(
+		envInt("PASSWORD_MIN_LENGTH", defaultMinLength),
+		envInt("PASSWORD_MAX_LENGTH", defaultMaxLength),
+		os.Getenv("PASSWORD_BREACHED_LIST"),
+	)
+}
+
+// Validate возвращает ошибку, обернутую в model.ErrWeakPassword, с описанием нарушенного правила
+func (p *Policy) Validate(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", model.ErrWeakPassword, p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("%w: must be at most %d characters", model.ErrWeakPassword, p.MaxLength)
+	}
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: password is known to be breached", model.ErrWeakPassword)
+	}
+	return nil
+}
+
+func envInt(name string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
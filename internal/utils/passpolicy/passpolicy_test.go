@@ -0,0 +1,71 @@
+package passpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vvjke314/itk-courses/loyalityhub/internal/model"
+)
+
+func TestPolicyValidate(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(list, []byte("# top passwords\npassword123\nQwerty2024\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := New(8, 16, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		isError  bool
+	}{
+		{
+			name:     "valid password",
+			password: "correct horse",
+			isError:  false,
+		},
+		{
+			name:     "too short",
+			password: "short",
+			isError:  true,
+		},
+		{
+			name:     "too long",
+			password: "this password is way too long",
+			isError:  true,
+		},
+		{
+			name:     "breached password",
+			password: "password123",
+			isError:  true,
+		},
+		{
+			name:     "breached password in other case",
+			password: "qwerty2024",
+			isError:  true,
+		},
+		{
+			name:     "length counted in characters",
+			password: "пароль12",
+			isError:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.isError != (err != nil) {
+				t.Errorf("expected error %v, got %v", tt.isError, err)
+			}
+			if err != nil && !errors.Is(err, model.ErrWeakPassword) {
+				t.Errorf("expected ErrWeakPassword, got %v", err)
+			}
+		})
+	}
+}
@@ -6,8 +6,10 @@ import (
 )
 
 const (
-	defaultAccessTokenTTL  time.Duration = 20 * time.Minute
-	defaultRefreshTokenTTL time.Duration = 30 * 24 * time.Hour
+	defaultAccessTokenTTL     time.Duration = 20 * time.Minute
+	defaultRefreshTokenTTL    time.Duration = 30 * 24 * time.Hour
+	defaultKeysReloadInterval time.Duration = time.Minute
+	defaultMFATokenTTL        time.Duration = 5 * time.Minute
 )
 
 type TokenManagerConfigOption interface {
@@ -42,17 +44,85 @@ func (o RefreshTokenTTLOption) apply(cfg *TokenManagerConfig) {
 	cfg.refreshTokenTTL = o.refreshTokenTTL
 }
 
+type MFATokenTTLOption struct {
+	mfaTokenTTL time.Duration
+}
+
+// WithMFATokenTTL время жизни токена второго шага входа
+func WithMFATokenTTL(ttl time.Duration) TokenManagerConfigOption {
+	return MFATokenTTLOption{
+		mfaTokenTTL: ttl,
+	}
+}
+
+func (o MFATokenTTLOption) apply(cfg *TokenManagerConfig) {
+	cfg.mfaTokenTTL = o.mfaTokenTTL
+}
+
+type KeysDirOption struct {
+	keysDir string
+}
+
+// WithKeysDir каталог с PEM ключами (RS256/EdDSA). Если не задан,
+// токены подписываются HS256 секретом JWT_SECRET
+func WithKeysDir(dir string) TokenManagerConfigOption {
+	return KeysDirOption{
+		keysDir: dir,
+	}
+}
+
+func (o KeysDirOption) apply(cfg *TokenManagerConfig) {
+	cfg.keysDir = o.keysDir
+}
+
+type ActiveKeyIDOption struct {
+	activeKeyID string
+}
+
+// WithActiveKeyID kid ключа, которым подписываются новые токены
+func WithActiveKeyID(kid string) TokenManagerConfigOption {
+	return ActiveKeyIDOption{
+		activeKeyID: kid,
+	}
+}
+
+func (o ActiveKeyIDOption) apply(cfg *TokenManagerConfig) {
+	cfg.activeKeyID = o.activeKeyID
+}
+
+type KeysReloadIntervalOption struct {
+	keysReloadInterval time.Duration
+}
+
+func WithKeysReloadInterval(interval time.Duration) TokenManagerConfigOption {
+	return KeysReloadIntervalOption{
+		keysReloadInterval: interval,
+	}
+}
+
+func (o KeysReloadIntervalOption) apply(cfg *TokenManagerConfig) {
+	cfg.keysReloadInterval = o.keysReloadInterval
+}
+
 type TokenManagerConfig struct {
-	jwtSecret       []byte
-	accessTokenTTL  time.Duration
-	refreshTokenTTL time.Duration
+	jwtSecret          []byte
+	keysDir            string
+	activeKeyID        string
+	keysReloadInterval time.Duration
+	accessTokenTTL     time.Duration
+	refreshTokenTTL    time.Duration
+	mfaTokenTTL        time.Duration
 }
 
 func NewTokenManagerConfig(opts ...TokenManagerConfigOption) TokenManagerConfig {
 	cfg := &TokenManagerConfig{
-		jwtSecret:       []byte(os.Getenv("JWT_SECRET")),
-		accessTokenTTL:  defaultAccessTokenTTL,
-		refreshTokenTTL: defaultRefreshTokenTTL,
+		jwtSecret:          []byte(os.Getenv("JWT_SECRET")),
+		keysDir:            os.Getenv("JWT_KEYS_DIR"),
+		activeKeyID:        os.Getenv("JWT_ACTIVE_KID"),
+		keysReloadInterval: defaultKeysReloadInterval,
+		accessTokenTTL:     defaultAccessTokenTTL,
+		refreshTokenTTL:    defaultRefreshTokenTTL,
+		mfaTokenTTL:        defaultMFATokenTTL,
 	}
 
 	for _, o := range opts {
@@ -0,0 +1,260 @@
+package tokenmanager
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNoSigningKey нет ключа, которым можно подписать токен
+var ErrNoSigningKey = errors.New("no signing key")
+
+// signingKey ключ из PEM файла. Для ключей, у которых в каталоге лежит только
+// публичная часть, private == nil - ими можно только проверять подпись
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	retiredAt time.Time
+}
+
+// keySet набор ключей, загруженных из каталога. Имя файла без расширения - kid
+type keySet struct {
+	mu        sync.RWMutex
+	dir       string
+	activeKID string
+	keys      map[string]*signingKey
+	// сколько удаленный из каталога ключ продолжает проверять подписи
+	retireTTL time.Duration
+}
+
+func newKeySet(dir, activeKID string, retireTTL time.Duration) (*keySet, error) {
+	ks := &keySet{
+		dir:       dir,
+		activeKID: activeKID,
+		keys:      make(map[string]*signingKey),
+		retireTTL: retireTTL,
+	}
+	if err := ks.reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// reload перечитывает каталог с ключами. Ключи, которые пропали из каталога,
+// не удаляются сразу, а продолжают проверять подписи еще retireTTL, чтобы
+// выданные ими токены дожили до своего exp
+func (ks *keySet) reload() error {
+	loaded, err := loadKeysDir(ks.dir)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	for kid, old := range ks.keys {
+		if _, ok := loaded[kid]; ok {
+			continue
+		}
+		if old.retiredAt.IsZero() {
+			old.retiredAt = now
+		}
+		if now.Sub(old.retiredAt) < ks.retireTTL {
+			loaded[kid] = old
+		}
+	}
+
+	if _, err := pickActive(loaded, ks.activeKID); err != nil {
+		return err
+	}
+
+	ks.keys = loaded
+	return nil
+}
+
+// active возвращает ключ для подписи новых токенов
+func (ks *keySet) active() (*signingKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return pickActive(ks.keys, ks.activeKID)
+}
+
+func (ks *keySet) get(kid string) (*signingKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+func (ks *keySet) methods() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	uniq := make(map[string]struct{})
+	for _, key := range ks.keys {
+		uniq[key.method.Alg()] = struct{}{}
+	}
+	res := make([]string, 0, len(uniq))
+	for alg := range uniq {
+		res = append(res, alg)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (ks *keySet) jwks() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	res := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		res.Keys = append(res.Keys, toJWK(ks.keys[kid]))
+	}
+	return res
+}
+
+// pickActive выбирает ключ подписи: явно заданный kid либо
+// лексикографически последний kid среди ключей с приватной частью
+func pickActive(keys map[string]*signingKey, activeKID string) (*signingKey, error) {
+	if activeKID != "" {
+		key, ok := keys[activeKID]
+		if !ok || key.private == nil || !key.retiredAt.IsZero() {
+			return nil, fmt.Errorf("%w: key %q not found", ErrNoSigningKey, activeKID)
+		}
+		return key, nil
+	}
+
+	var active *signingKey
+	for _, key := range keys {
+		if key.private == nil || !key.retiredAt.IsZero() {
+			continue
+		}
+		if active == nil || key.kid > active.kid {
+			active = key
+		}
+	}
+	if active == nil {
+		return nil, ErrNoSigningKey
+	}
+	return active, nil
+}
+
+func loadKeysDir(dir string) (map[string]*signingKey, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("list keys dir: %w", err)
+	}
+
+	keys := make(map[string]*signingKey, len(files))
+	for _, file := range files {
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read key %s: %w", file, err)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+		key, err := parsePEMKey(kid, raw)
+		if err != nil {
+			return nil, fmt.Errorf("parse key %s: %w", file, err)
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+func parsePEMKey(kid string, raw []byte) (*signingKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &signingKey{kid: kid}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, nil
+}
+
+// JWK публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func toJWK(key *signingKey) JWK {
+	jwk := JWK{
+		Use: "sig",
+		Alg: key.method.Alg(),
+		Kid: key.kid,
+	}
+	switch pub := key.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
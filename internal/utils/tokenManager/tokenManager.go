@@ -1,11 +1,13 @@
 package tokenmanager
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -14,24 +16,150 @@ import (
 
 type TokenManager struct {
 	config TokenManagerConfig
+	// nil, если ключи не настроены и используется HS256
+	keys *keySet
 }
 
-func NewTokenManager(config TokenManagerConfig) *TokenManager {
-	return &TokenManager{
+func NewTokenManager(config TokenManagerConfig) (*TokenManager, error) {
+	tm := &TokenManager{
 		config: config,
 	}
+	if config.keysDir == "" {
+		return tm, nil
+	}
+
+	keys, err := newKeySet(config.keysDir, config.activeKeyID, config.accessTokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("load signing keys: %w", err)
+	}
+	tm.keys = keys
+	return tm, nil
+}
+
+// Claims данные, которые кладутся в токены
+type Claims struct {
+	// роль пользователя, только в access токенах
+	Role string `json:"role,omitempty"`
+	// тип токена. У access токенов claim нет
+	Type string `json:"typ,omitempty"`
+	// id сессии, к которой привязан access токен
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+
+	// разобранный sub, заполняется при парсинге
+	UserID uuid.UUID `json:"-"`
+}
+
+const mfaTokenType = "mfa"
+
+func newClaims(userID uuid.UUID, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+}
+
+// GenerateAccessToken генерируем access-token. Токен получает уникальный jti
+// и id сессии, чтобы его можно было отозвать до истечения срока
+func (tm *TokenManager) GenerateAccessToken(userID uuid.UUID, role string, sessionID uuid.UUID) (string, error) {
+	claims := newClaims(userID, tm.config.accessTokenTTL)
+	claims.ID = uuid.NewString()
+	claims.Role = role
+	claims.SessionID = sessionID.String()
+	return tm.sign(claims)
+}
+
+// GenerateMFAToken короткоживущий токен второго шага входа: подтверждает,
+// что пароль уже проверен, и обменивается на access/refresh после ввода кода
+func (tm *TokenManager) GenerateMFAToken(userID uuid.UUID) (string, error) {
+	claims := newClaims(userID, tm.config.mfaTokenTTL)
+	claims.Type = mfaTokenType
+	return tm.sign(claims)
+}
+
+// sign подписывает claims активным ключом и проставляет его kid в заголовок
+func (tm *TokenManager) sign(claims jwt.Claims) (string, error) {
+	if tm.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(tm.config.jwtSecret)
+	}
+
+	key, err := tm.keys.active()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// keyFunc подбирает ключ проверки подписи по kid из заголовка токена
+func (tm *TokenManager) keyFunc(token *jwt.Token) (any, error) {
+	if tm.keys == nil {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, ErrInvalidToken
+		}
+		return tm.config.jwtSecret, nil
+	}
+
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	key, ok := tm.keys.get(kid)
+	if !ok || token.Method.Alg() != key.method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key.public, nil
+}
+
+func (tm *TokenManager) validMethods() []string {
+	if tm.keys == nil {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	return tm.keys.methods()
 }
 
-// GenerateAccessToken генерируем access-token
-func (tm *TokenManager) GenerateAccessToken(userID uuid.UUID) (string, error) {
-	claims := jwt.MapClaims{
-		"sub": userID.String(),
-		"exp": time.Now().Add(tm.config.accessTokenTTL).Unix(),
-		"iat": time.Now().Unix(),
-		"nbf": time.Now().Unix(),
+// ReloadKeys перечитывает каталог с ключами
+func (tm *TokenManager) ReloadKeys() error {
+	if tm.keys == nil {
+		return nil
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(tm.config.jwtSecret)
+	return tm.keys.reload()
+}
+
+// WatchKeys периодически перечитывает каталог с ключами, чтобы ротация
+// происходила без перезапуска: новый ключ кладется в каталог, старый удаляется
+func (tm *TokenManager) WatchKeys(ctx context.Context, onError func(error)) {
+	if tm.keys == nil {
+		return
+	}
+
+	ticker := time.NewTicker(tm.config.keysReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := tm.keys.reload(); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// JWKS возвращает публичные ключи для проверки токенов сторонними сервисами
+func (tm *TokenManager) JWKS() JWKS {
+	if tm.keys == nil {
+		return JWKS{Keys: []JWK{}}
+	}
+	return tm.keys.jwks()
 }
 
 func (tm *TokenManager) GetRefreshTokenTTL() time.Duration {
@@ -62,36 +190,44 @@ func (tm *TokenManager) HashToken(token string) string {
 // ErrValidToken ошибка при валидации токена
 var ErrInvalidToken = errors.New("invalid token")
 
-// ParseToken парсит access-токен и возвращаеи uuid пользователя при успешной валидации
-// TO-DO: если access-token насытится большими данными,
-// возвращать кастомную структуру claims
-func (tm *TokenManager) ParseToken(tokenString string) (uuid.UUID, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
-		if token.Method != jwt.SigningMethodHS256 {
-			return nil, ErrInvalidToken
-		}
-		return tm.config.jwtSecret, nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+// ParseToken парсит access-токен и возвращает его claims при успешной валидации
+func (tm *TokenManager) ParseToken(tokenString string) (*Claims, error) {
+	return tm.parse(tokenString, "")
+}
+
+// ParseMFAToken парсит токен второго шага входа
+func (tm *TokenManager) ParseMFAToken(tokenString string) (uuid.UUID, error) {
+	claims, err := tm.parse(tokenString, mfaTokenType)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return claims.UserID, nil
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return uuid.Nil, ErrInvalidToken
+// parse проверяет подпись и тип токена
+func (tm *TokenManager) parse(tokenString, typ string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, tm.keyFunc,
+		jwt.WithValidMethods(tm.validMethods()), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, ErrInvalidToken
 	}
 
-	sub, ok := claims["sub"].(string)
-	if !ok {
-		return uuid.Nil, ErrInvalidToken
+	// токен второго шага не должен приниматься как access и наоборот
+	if claims.Type != typ {
+		return nil, ErrInvalidToken
 	}
 
-	userID, err := uuid.Parse(sub)
+	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, ErrInvalidToken
 	}
+	claims.UserID = userID
 
-	return userID, nil
+	return claims, nil
 }
 
 // ValidateToken просто проверяет валидность токена
@@ -0,0 +1,227 @@
+package tokenmanager
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writeRSAKey(t *testing.T, dir, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeEd25519Key(t *testing.T, dir, kid string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), raw, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tokenKID(t *testing.T, token string) string {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func TestTokenManagerSignAndParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, dir string)
+		alg     string
+	}{
+		{
+			name:    "hs256 without keys dir",
+			prepare: func(t *testing.T, dir string) {},
+			alg:     "HS256",
+		},
+		{
+			name:    "rs256",
+			prepare: func(t *testing.T, dir string) { writeRSAKey(t, dir, "2025-08-01") },
+			alg:     "RS256",
+		},
+		{
+			name:    "eddsa",
+			prepare: func(t *testing.T, dir string) { writeEd25519Key(t, dir, "2025-08-01") },
+			alg:     "EdDSA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.prepare(t, dir)
+			keysDir := dir
+			if tt.alg == "HS256" {
+				keysDir = ""
+			}
+
+			tm, err := NewTokenManager(NewTokenManagerConfig(WithKeysDir(keysDir)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			userID, sessionID := uuid.New(), uuid.New()
+			token, err := tm.GenerateAccessToken(userID, "admin", sessionID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			if err != nil || parsed.Method.Alg() != tt.alg {
+				t.Fatalf("expected alg %s, got %v (%v)", tt.alg, parsed, err)
+			}
+
+			got, err := tm.ParseToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.UserID != userID || got.Role != "admin" {
+				t.Errorf("expected %s/admin, got %s/%s", userID, got.UserID, got.Role)
+			}
+			if got.SessionID != sessionID.String() || got.ID == "" {
+				t.Errorf("expected sid %s and jti, got %q/%q", sessionID, got.SessionID, got.ID)
+			}
+		})
+	}
+}
+
+func TestTokenManagerKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "2025-08-01")
+
+	tm, err := NewTokenManager(NewTokenManagerConfig(WithKeysDir(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := uuid.New()
+	oldToken, err := tm.GenerateAccessToken(userID, "customer", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// кладем новый ключ - он становится активным
+	writeEd25519Key(t, dir, "2025-09-01")
+	if err := tm.ReloadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, err := tm.GenerateAccessToken(userID, "customer", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kid := tokenKID(t, newToken); kid != "2025-09-01" {
+		t.Errorf("expected new key to sign tokens, got kid %q", kid)
+	}
+	if len(tm.JWKS().Keys) != 2 {
+		t.Errorf("expected both keys in jwks, got %d", len(tm.JWKS().Keys))
+	}
+
+	// удаляем старый ключ - выданные им токены продолжают проверяться до exp
+	if err := os.Remove(filepath.Join(dir, "2025-08-01.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.ReloadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{oldToken, newToken} {
+		if got, err := tm.ParseToken(token); err != nil || got.UserID != userID {
+			t.Errorf("expected token to stay valid, got %v (%v)", got, err)
+		}
+	}
+}
+
+func TestTokenManagerRejectsForeignKey(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, dir, "main")
+
+	tm, err := NewTokenManager(NewTokenManagerConfig(WithKeysDir(dir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// токен с тем же kid, но подписанный чужим ключом
+	foreign, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": uuid.NewString(), "exp": time.Now().Add(time.Minute).Unix()})
+	token.Header["kid"] = "main"
+	signed, err := token.SignedString(foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tm.ParseToken(signed); err == nil {
+		t.Error("expected error for token signed with foreign key")
+	}
+
+	// HS256 токен с kid асимметричного ключа не должен приниматься
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": uuid.NewString(), "exp": time.Now().Add(time.Minute).Unix()})
+	hs.Header["kid"] = "main"
+	signed, err = hs.SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.ParseToken(signed); err == nil {
+		t.Error("expected error for hs256 token in asymmetric mode")
+	}
+}
+
+func TestTokenManagerMFAToken(t *testing.T) {
+	tm, err := NewTokenManager(NewTokenManagerConfig(WithKeysDir("")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID := uuid.New()
+	mfaToken, err := tm.GenerateMFAToken(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, err := tm.GenerateAccessToken(userID, "customer", uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := tm.ParseMFAToken(mfaToken); err != nil || got != userID {
+		t.Errorf("expected %s, got %s (%v)", userID, got, err)
+	}
+	if _, err := tm.ParseToken(mfaToken); err == nil {
+		t.Error("expected mfa token to be rejected as access token")
+	}
+	if _, err := tm.ParseMFAToken(accessToken); err == nil {
+		t.Error("expected access token to be rejected as mfa token")
+	}
+}
@@ -0,0 +1,70 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// тестовые векторы RFC 6238 (SHA1), последние 6 цифр
+func TestCodeRFC6238(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(secret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.code {
+			t.Errorf("at %d expected %s, got %s", tt.unix, tt.code, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	prev, err := Code(secret, Step(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step, ok := Validate(secret, prev, now, 1); !ok || step != Step(now)-1 {
+		t.Errorf("expected previous step code to be accepted")
+	}
+
+	old, err := Code(secret, Step(now)-3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Validate(secret, old, now, 1); ok {
+		t.Errorf("expected code outside of skew to be rejected")
+	}
+
+	if _, ok := Validate(secret, "12345", now, 1); ok {
+		t.Errorf("expected short code to be rejected")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("LoyaltyHub", "user@example.com", "JBSWY3DPEHPK3PXP")
+	if !strings.HasPrefix(uri, "otpauth://totp/LoyaltyHub:user@example.com?") ||
+		!strings.Contains(uri, "secret=JBSWY3DPEHPK3PXP") {
+		t.Errorf("unexpected uri %s", uri)
+	}
+}
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_token_history(
+    hashed_refresh_token TEXT PRIMARY KEY,
+    session_id uuid NOT NULL,
+    rotated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_session_id
+        FOREIGN KEY (session_id)
+        REFERENCES sessions(id)
+        ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_history_session_id ON refresh_token_history(session_id);
+
+CREATE TABLE IF NOT EXISTS audit_events(
+    id uuid PRIMARY KEY,
+    user_id uuid,
+    event_type TEXT NOT NULL,
+    session_id uuid,
+    ip TEXT NOT NULL DEFAULT '',
+    details JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_user_id
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_events_user_id ON audit_events(user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS refresh_token_history;
+-- +goose StatementEnd
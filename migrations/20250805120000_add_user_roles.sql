@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE user_role AS ENUM ('customer', 'support', 'admin', 'merchant');
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role user_role NOT NULL DEFAULT 'customer';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+DROP TYPE IF EXISTS user_role;
+-- +goose StatementEnd
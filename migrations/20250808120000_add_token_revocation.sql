@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMPTZ;
+
+-- отозванные access токены: по jti или целиком по сессии (sid).
+-- Записи старше времени жизни access токена больше не нужны и удаляются
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    kind TEXT NOT NULL CHECK (kind IN ('jti', 'sid')),
+    value TEXT NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, value)
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_tokens_revoked_at ON revoked_tokens(revoked_at);
+
+-- любое удаление сессии (выход, смена пароля, бан) отзывает ее access токены
+CREATE OR REPLACE FUNCTION revoke_session_tokens() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO revoked_tokens (kind, value) VALUES ('sid', OLD.id::text)
+    ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sessions_revoke_tokens
+    AFTER DELETE ON sessions
+    FOR EACH ROW EXECUTE FUNCTION revoke_session_tokens();
+
+-- реплики держат denylist в памяти и узнают о новых записях через LISTEN
+CREATE OR REPLACE FUNCTION notify_revoked_token() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('revoked_tokens', NEW.kind || ':' || NEW.value);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER revoked_tokens_notify
+    AFTER INSERT ON revoked_tokens
+    FOR EACH ROW EXECUTE FUNCTION notify_revoked_token();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS sessions_revoke_tokens ON sessions;
+DROP FUNCTION IF EXISTS revoke_session_tokens();
+DROP TABLE IF EXISTS revoked_tokens;
+DROP FUNCTION IF EXISTS notify_revoked_token();
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_profiles(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    display_name TEXT,
+    email TEXT,
+    phone TEXT,
+    birthday DATE,
+    marketing_email BOOLEAN NOT NULL DEFAULT FALSE,
+    marketing_sms BOOLEAN NOT NULL DEFAULT FALSE,
+    -- когда пользователь последний раз менял согласия на рассылки
+    consents_updated_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_profiles_email ON user_profiles(LOWER(email));
+-- выборка именинников для бонусов
+CREATE INDEX IF NOT EXISTS idx_user_profiles_birthday
+    ON user_profiles(EXTRACT(MONTH FROM birthday), EXTRACT(DAY FROM birthday))
+    WHERE birthday IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_profiles;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_requested_at ON users(deletion_requested_at)
+    WHERE deletion_requested_at IS NOT NULL AND anonymized_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_deletion_requested_at;
+ALTER TABLE users
+    DROP COLUMN IF EXISTS deletion_requested_at,
+    DROP COLUMN IF EXISTS anonymized_at;
+-- +goose StatementEnd
@@ -0,0 +1,8 @@
+-- +goose NO TRANSACTION
+-- индекс строится без блокировки записи в orders, поэтому вне транзакции
+
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_orders_user_id_uploaded_at ON orders(user_id, uploaded_at);
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS idx_orders_user_id_uploaded_at;
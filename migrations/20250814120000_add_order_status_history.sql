@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- история смены статуса и начисления по заказу, пишется вместе с изменением orders
+CREATE TABLE IF NOT EXISTS order_status_history(
+    id BIGSERIAL PRIMARY KEY,
+    order_number TEXT NOT NULL REFERENCES orders(number) ON DELETE CASCADE,
+    status order_status NOT NULL,
+    accrual NUMERIC(12,2),
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_number ON order_status_history(order_number, changed_at);
+
+-- для уже загруженных заказов известно только текущее состояние
+INSERT INTO order_status_history (order_number, status, accrual, changed_at)
+SELECT number, status, accrual, COALESCE(uploaded_at, NOW()) FROM orders;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS order_status_history;
+-- +goose StatementEnd
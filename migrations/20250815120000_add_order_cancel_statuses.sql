@@ -0,0 +1,10 @@
+-- +goose NO TRANSACTION
+-- новые значения enum нельзя использовать в той же транзакции, где они добавлены
+
+-- +goose Up
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'CANCELLED';
+ALTER TYPE order_status ADD VALUE IF NOT EXISTS 'RETURNED';
+
+-- +goose Down
+-- значения из enum не удаляются, отмененные и возвращенные заказы
+-- после отката просто перестанут меняться
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- корректировки баланса помимо начислений и списаний, например возврат
+-- баллов за возвращенный заказ. Отрицательная сумма уменьшает баланс
+CREATE TABLE IF NOT EXISTS balance_adjustments(
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    order_number TEXT REFERENCES orders(number) ON DELETE CASCADE,
+    amount NUMERIC(12,2) NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_adjustments_user_id ON balance_adjustments(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS balance_adjustments;
+-- +goose StatementEnd
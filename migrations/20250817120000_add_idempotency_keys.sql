@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ключи идемпотентности POST запросов: отпечаток запроса и сохраненный ответ.
+-- scope - владелец ключа (пользователь или API ключ), чтобы ключи разных
+-- клиентов не пересекались. Пока status_code пуст, запрос еще выполняется
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+    scope TEXT NOT NULL,
+    key TEXT NOT NULL,
+    fingerprint TEXT NOT NULL,
+    status_code INTEGER,
+    content_type TEXT,
+    response_body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS merchants(
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    -- пользователь с ролью merchant, API ключи которого работают от имени мерчанта
+    owner_id UUID UNIQUE REFERENCES users(id) ON DELETE SET NULL,
+    -- правила проверки номеров заказов, пусто - из настроек сервиса
+    order_number_validator TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- у каждого существующего пользователя с ролью merchant появляется мерчант
+INSERT INTO merchants (id, name, owner_id)
+SELECT gen_random_uuid(), login, id FROM users WHERE role = 'merchant'
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS merchant_id UUID REFERENCES merchants(id) ON DELETE SET NULL;
+ALTER TABLE withdrawals ADD COLUMN IF NOT EXISTS merchant_id UUID REFERENCES merchants(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE withdrawals DROP COLUMN IF EXISTS merchant_id;
+ALTER TABLE orders DROP COLUMN IF EXISTS merchant_id;
+DROP TABLE IF EXISTS merchants;
+-- +goose StatementEnd
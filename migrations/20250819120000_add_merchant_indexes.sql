@@ -0,0 +1,10 @@
+-- +goose NO TRANSACTION
+-- индексы строятся без блокировки записи в orders и withdrawals, поэтому вне транзакции
+
+-- +goose Up
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_orders_merchant_id ON orders(merchant_id);
+CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_withdrawals_merchant_id ON withdrawals(merchant_id);
+
+-- +goose Down
+DROP INDEX CONCURRENTLY IF EXISTS idx_withdrawals_merchant_id;
+DROP INDEX CONCURRENTLY IF EXISTS idx_orders_merchant_id;
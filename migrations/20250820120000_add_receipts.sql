@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- чек покупки, по которому считается начисление. Позиции хранятся отдельно
+CREATE TABLE IF NOT EXISTS receipts(
+    order_number TEXT PRIMARY KEY REFERENCES orders(number) ON DELETE CASCADE,
+    merchant_id UUID REFERENCES merchants(id) ON DELETE SET NULL,
+    total NUMERIC(12,2) NOT NULL CHECK (total >= 0),
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS receipt_items(
+    order_number TEXT NOT NULL REFERENCES receipts(order_number) ON DELETE CASCADE,
+    -- порядок позиции в чеке
+    line_no INT NOT NULL,
+    sku TEXT NOT NULL,
+    category TEXT,
+    quantity NUMERIC(12,3) NOT NULL CHECK (quantity > 0),
+    price NUMERIC(12,2) NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (order_number, line_no)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS receipt_items;
+DROP TABLE IF EXISTS receipts;
+-- +goose StatementEnd
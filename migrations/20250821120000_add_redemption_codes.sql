@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- одноразовые коды, которыми покупатель подтверждает списание баллов на кассе партнера
+CREATE TABLE IF NOT EXISTS redemption_codes(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    -- неверные коды, присланные кассой, пока код действовал
+    failures INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, code_hash)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS redemption_codes;
+-- +goose StatementEnd